@@ -0,0 +1,188 @@
+// Package analytics Учёт просмотров публикаций: классификация посетителя, хэш посетителя
+// с суточной солью и фоновая запись событий пачками (models.RecordShareViews)
+package analytics
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Классы User-Agent
+const (
+	ClassDesktop  = "desktop"
+	ClassMobile   = "mobile"
+	ClassTablet   = "tablet"
+	ClassBot      = "bot"
+	ClassPrefetch = "prefetch" // Упреждающая загрузка браузером или предпросмотр, не просмотр человеком
+	ClassOther    = "other"    // Пустой или нераспознанный User-Agent (curl, библиотеки HTTP)
+)
+
+// BotOther Имя автоматического клиента, опознанного только по общим признакам ("bot", "crawler")
+const BotOther = "other"
+
+// botAgent Признак известного автоматического клиента в User-Agent (в нижнем регистре) и его имя
+type botAgent struct {
+	marker string
+	name   string
+}
+
+// knownBots Сервисы предпросмотра ссылок и поисковые роботы. Проверяются до общих признаков,
+// порядок важен: более точные признаки раньше
+var knownBots = []botAgent{
+	{"slackbot", "slack"},
+	{"slack-imgproxy", "slack"},
+	{"telegrambot", "telegram"},
+	{"discordbot", "discord"},
+	{"twitterbot", "twitter"},
+	{"facebookexternalhit", "facebook"},
+	{"facebookcatalog", "facebook"},
+	{"linkedinbot", "linkedin"},
+	{"whatsapp", "whatsapp"},
+	{"skypeuripreview", "skype"},
+	{"vkshare", "vk"},
+	{"mattermost", "mattermost"},
+	{"redditbot", "reddit"},
+	{"pinterest", "pinterest"},
+	{"embedly", "embedly"},
+	{"iframely", "iframely"},
+	{"googlebot", "google"},
+	{"google-inspectiontool", "google"},
+	{"adsbot-google", "google"},
+	{"bingbot", "bing"},
+	{"bingpreview", "bing"},
+	{"yandex", "yandex"},
+	{"baiduspider", "baidu"},
+	{"duckduckbot", "duckduckgo"},
+	{"applebot", "apple"},
+	{"petalbot", "petal"},
+	{"ahrefsbot", "ahrefs"},
+	{"semrushbot", "semrush"},
+	{"headlesschrome", "headless"},
+}
+
+// botMarkers Общие признаки автоматических клиентов в User-Agent (в нижнем регистре)
+var botMarkers = []string{"bot", "crawler", "spider", "slurp", "preview", "fetcher", "scraper"}
+
+// Visitor Класс посетителя и, для автоматических клиентов, имя сервиса
+type Visitor struct {
+	Class string
+	Bot   string // Имя бота (slack, google, ...), prefetch или other; пусто - человек
+}
+
+// Automated Запрос выполнен не человеком: бот, предпросмотр ссылки или упреждающая загрузка
+func (v Visitor) Automated() bool { return v.Bot != "" }
+
+// Classifier Классификатор посетителей с дополнительными признаками ботов экземпляра
+type Classifier struct {
+	extra []string
+}
+
+// NewClassifier Классификатор; extraMarkers - дополнительные признаки ботов в User-Agent
+// (BOT_USER_AGENTS), сравниваются без учёта регистра
+func NewClassifier(extraMarkers []string) *Classifier {
+	c := &Classifier{}
+	for _, m := range extraMarkers {
+		if m = strings.ToLower(strings.TrimSpace(m)); m != "" {
+			c.extra = append(c.extra, m)
+		}
+	}
+	return c
+}
+
+// Classify Класс посетителя по User-Agent и заголовкам упреждающей загрузки
+func (c *Classifier) Classify(ua string, h http.Header) Visitor {
+	if name := c.botName(ua); name != "" {
+		return Visitor{Class: ClassBot, Bot: name}
+	}
+	if IsPrefetch(h) {
+		return Visitor{Class: ClassPrefetch, Bot: ClassPrefetch}
+	}
+	return Visitor{Class: ClassifyUserAgent(ua)}
+}
+
+// botName Имя автоматического клиента по User-Agent, пусто - не бот
+func (c *Classifier) botName(ua string) string {
+	s := strings.ToLower(ua)
+	if s == "" {
+		return ""
+	}
+	for _, b := range knownBots {
+		if strings.Contains(s, b.marker) {
+			return b.name
+		}
+	}
+	if containsAny(s, botMarkers) || containsAny(s, c.extra) {
+		return BotOther
+	}
+	return ""
+}
+
+// IsPrefetch Запрос упреждающей загрузки или предпросмотра страницы браузером:
+// Purpose/Sec-Purpose: prefetch (Chrome, prerender), X-Moz: prefetch (Firefox),
+// X-Purpose: preview (Safari)
+func IsPrefetch(h http.Header) bool {
+	if h == nil {
+		return false
+	}
+	for _, name := range []string{"Sec-Purpose", "Purpose", "X-Moz", "X-Purpose"} {
+		v := strings.ToLower(h.Get(name))
+		if strings.Contains(v, "prefetch") || strings.Contains(v, "prerender") || strings.Contains(v, "preview") {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassifyUserAgent Класс User-Agent: bot, tablet, mobile, desktop или other
+func ClassifyUserAgent(ua string) string {
+	s := strings.ToLower(ua)
+	switch {
+	case s == "":
+		return ClassOther
+	case containsAny(s, botMarkers):
+		return ClassBot
+	case strings.Contains(s, "ipad") || strings.Contains(s, "tablet") ||
+		(strings.Contains(s, "android") && !strings.Contains(s, "mobile")):
+		return ClassTablet
+	case strings.Contains(s, "mobi") || strings.Contains(s, "iphone") || strings.Contains(s, "android"):
+		return ClassMobile
+	case strings.HasPrefix(s, "mozilla/") || strings.Contains(s, "windows") || strings.Contains(s, "macintosh") || strings.Contains(s, "linux"):
+		return ClassDesktop
+	}
+	return ClassOther
+}
+
+func containsAny(s string, markers []string) bool {
+	for _, m := range markers {
+		if strings.Contains(s, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReferrerHost Хост источника перехода без www. и порта; пусто - прямой переход, внутренняя
+// навигация (selfHost) или некорректный адрес
+func ReferrerHost(referrer, selfHost string) string {
+	if referrer == "" {
+		return ""
+	}
+	u, err := url.Parse(referrer)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	host := stripPort(strings.ToLower(u.Host))
+	if host == "" || host == stripPort(strings.ToLower(selfHost)) {
+		return ""
+	}
+	return strings.TrimPrefix(host, "www.")
+}
+
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
@@ -0,0 +1,199 @@
+package analytics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+const (
+	queueSize     = 4096            // Событий в очереди записи; при переполнении просмотры не учитываются
+	batchSize     = 200             // Событий в одной транзакции
+	flushInterval = 2 * time.Second // Период записи неполной пачки
+)
+
+// Учёт просмотров автоматических клиентов (BOT_VIEWS)
+const (
+	BotViewsSeparate = "separate" // Отдельный счётчик, не входят в ViewCount и число просмотров
+	BotViewsCount    = "count"    // Считаются как обычные просмотры
+	BotViewsIgnore   = "ignore"   // Не записываются
+)
+
+// Config Параметры учёта просмотров
+type Config struct {
+	BotViews      string   // separate, count или ignore
+	BotUserAgents []string // Дополнительные признаки ботов в User-Agent
+}
+
+// ConfigFromEnv Настройки из окружения: BOT_VIEWS (по умолчанию separate),
+// BOT_USER_AGENTS - дополнительные признаки ботов через запятую
+func ConfigFromEnv() Config {
+	cfg := Config{BotViews: strings.ToLower(strings.TrimSpace(os.Getenv("BOT_VIEWS")))}
+	switch cfg.BotViews {
+	case BotViewsSeparate, BotViewsCount, BotViewsIgnore:
+	case "":
+		cfg.BotViews = BotViewsSeparate
+	default:
+		log.Printf("Invalid BOT_VIEWS=%q, using %s", cfg.BotViews, BotViewsSeparate)
+		cfg.BotViews = BotViewsSeparate
+	}
+	if v := os.Getenv("BOT_USER_AGENTS"); v != "" {
+		cfg.BotUserAgents = strings.Split(v, ",")
+	}
+	return cfg
+}
+
+// View Просмотр публикации: данные запроса до классификации и хэширования
+type View struct {
+	ShareID   string
+	IP        string
+	UserAgent string
+	Header    http.Header // Заголовки запроса (признаки упреждающей загрузки)
+	Referrer  string      // Источник перехода (Referer или X-Referrer от SPA)
+	Host      string      // Хост сервиса, переходы с него не считаются источником
+}
+
+var (
+	botViews   = BotViewsSeparate
+	classifier = NewClassifier(nil)
+
+	queue   chan models.ShareViewEvent
+	done    chan struct{}
+	dropped atomic.Int64
+
+	saltMu  sync.Mutex
+	saltDay string
+	salt    string
+)
+
+// Start Запуск фоновой записи просмотров. До запуска (например, в командах CLI) Record
+// записывает события сразу с настройками по умолчанию
+func Start(cfg Config) {
+	botViews = cfg.BotViews
+	classifier = NewClassifier(cfg.BotUserAgents)
+	log.Printf("Share view recorder started: bot views %s", botViews)
+	queue = make(chan models.ShareViewEvent, queueSize)
+	done = make(chan struct{})
+	go run(queue, done, botViews == BotViewsCount)
+}
+
+// Stop Запись оставшихся событий и остановка; вызывается после остановки HTTP-сервера,
+// когда новых вызовов Record уже не будет
+func Stop() {
+	if queue == nil {
+		return
+	}
+	close(queue)
+	<-done
+	queue = nil
+}
+
+// Record Учёт просмотра: событие ставится в очередь записи и не задерживает ответ.
+// Возвращает, входит ли просмотр в ViewCount (false - бот при BOT_VIEWS separate или ignore)
+func Record(v View) bool {
+	visitor := classifier.Classify(v.UserAgent, v.Header)
+	if visitor.Automated() && botViews == BotViewsIgnore {
+		return false
+	}
+	counted := !visitor.Automated() || botViews == BotViewsCount
+
+	e, err := newEvent(v, visitor, time.Now().UTC())
+	if err != nil {
+		log.Printf("Failed to record share view: %v", err)
+		return counted
+	}
+	if queue == nil {
+		if err := models.RecordShareViews([]models.ShareViewEvent{e}, botViews == BotViewsCount); err != nil {
+			log.Printf("Failed to record share view: %v", err)
+		}
+		return counted
+	}
+	select {
+	case queue <- e:
+	default:
+		if n := dropped.Add(1); n == 1 || n%1000 == 0 {
+			log.Printf("Share view queue is full, %d views dropped", n)
+		}
+	}
+	return counted
+}
+
+// newEvent Событие просмотра: сутки (UTC), класс посетителя, хост источника и хэш посетителя
+func newEvent(v View, visitor Visitor, now time.Time) (models.ShareViewEvent, error) {
+	day := now.Format(models.DayLayout)
+	s, err := daySalt(day)
+	if err != nil {
+		return models.ShareViewEvent{}, err
+	}
+	return models.ShareViewEvent{
+		ShareID:      v.ShareID,
+		Day:          day,
+		VisitorHash:  visitorHash(s, v.ShareID, v.IP, v.UserAgent),
+		ReferrerHost: ReferrerHost(v.Referrer, v.Host),
+		UAClass:      visitor.Class,
+		Bot:          visitor.Bot,
+		CreatedAt:    now,
+	}, nil
+}
+
+// visitorHash Хэш посетителя публикации: IP и User-Agent с суточной солью. Один и тот же
+// посетитель в разные сутки и на разных публикациях даёт разные хэши
+func visitorHash(salt, shareID, ip, ua string) string {
+	sum := sha256.Sum256([]byte(salt + "\x00" + shareID + "\x00" + ip + "\x00" + ua))
+	return hex.EncodeToString(sum[:16])
+}
+
+// daySalt Соль суток (кэшируется до смены суток)
+func daySalt(day string) (string, error) {
+	saltMu.Lock()
+	defer saltMu.Unlock()
+	if saltDay == day {
+		return salt, nil
+	}
+	s, err := models.ViewSaltFor(day)
+	if err != nil {
+		return "", err
+	}
+	saltDay, salt = day, s
+	return salt, nil
+}
+
+// run Запись событий пачками: по заполнении пачки, по таймеру и при остановке
+func run(queue <-chan models.ShareViewEvent, done chan<- struct{}, countBots bool) {
+	defer close(done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]models.ShareViewEvent, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := models.RecordShareViews(batch, countBots); err != nil {
+			log.Printf("Failed to write %d share views: %v", len(batch), err)
+		}
+		batch = make([]models.ShareViewEvent, 0, batchSize)
+	}
+	for {
+		select {
+		case e, ok := <-queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+var auditCommands = map[string]command{
+	"list": {auditListArgs, "Журнал аудита, новые события первыми", auditList},
+}
+
+const auditListArgs = "[-user <пользователь>] [-action <действие>] [-result <результат>] [-since 24h] [-limit N]"
+
+// auditList Журнал аудита экземпляра или одного аккаунта
+func auditList(args []string) error {
+	fs := newFlags("audit list", auditListArgs)
+	username := fs.String("user", "", "Аккаунт (имя, email или ID): события, относящиеся к нему или выполненные им")
+	action := fs.String("action", "", "Действие или группа действий с точкой в конце, например token.")
+	result := fs.String("result", "", "Результат: success, failure или denied")
+	since := fs.Duration("since", 0, "Только события за последний период, например 24h")
+	limit := fs.Int("limit", 100, "Максимальное число строк")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	filter := models.AuditFilter{Action: *action, Result: *result}
+	if *username != "" {
+		user, err := models.FindUser(*username)
+		if err != nil {
+			return err
+		}
+		filter.UserID = user.ID
+	}
+	if *since > 0 {
+		t := time.Now().Add(-*since)
+		filter.Since = &t
+	}
+	list, total, err := models.ListAuditEvents(filter, 0, *limit)
+	if err != nil {
+		return err
+	}
+	tw := newTable()
+	fmt.Fprintln(tw, "ВРЕМЯ\tДЕЙСТВИЕ\tРЕЗУЛЬТАТ\tИСПОЛНИТЕЛЬ\tАККАУНТ\tОБЪЕКТ\tIP\tПОДРОБНОСТИ")
+	for _, e := range list {
+		actor := e.ActorName
+		if actor == "" {
+			actor = e.ActorID
+		}
+		if e.Via != "" {
+			actor = dash(actor) + " (" + e.Via + ")"
+		}
+		target := ""
+		if e.TargetType != "" {
+			target = e.TargetType + ":" + e.TargetID
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.CreatedAt.Format("2006-01-02 15:04:05"), e.Action, e.Result,
+			dash(actor), dash(e.UserID), dash(target), dash(e.IP), e.Detail)
+	}
+	tw.Flush()
+	fmt.Fprintf(stdout, "Всего: %d\n", total)
+	return nil
+}
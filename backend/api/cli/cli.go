@@ -0,0 +1,195 @@
+// Package cli Командная строка администрирования экземпляра: siyuan-share <группа> <команда> [флаги].
+// Команды используют те же функции models, что и HTTP-обработчики
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	osuser "os/user"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+// command Команда группы
+type command struct {
+	args string // Аргументы и флаги
+	help string // Краткое описание
+	run  func(args []string) error
+}
+
+// groups Группы команд: user, token, share, audit, db, keys
+var groups = map[string]map[string]command{
+	"user":  userCommands,
+	"token": tokenCommands,
+	"share": shareCommands,
+	"audit": auditCommands,
+	"db":    dbCommands,
+	"keys":  keysCommands,
+}
+
+// errUsage Неверные аргументы команды (справка уже выведена)
+var errUsage = errors.New("usage")
+
+// Потоки вывода команд
+var (
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+// Run Выполнение команды; без аргументов или с serve запускается сервер. Возвращает код завершения
+func Run(args []string, serve func()) int {
+	if len(args) == 0 || args[0] == "serve" {
+		serve()
+		return 0
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stdout)
+		return 0
+	}
+	group, ok := groups[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "Неизвестная команда %q\n\n", args[0])
+		printUsage(stderr)
+		return 2
+	}
+	if len(args) < 2 {
+		printGroupUsage(stderr, args[0])
+		return 2
+	}
+	cmd, ok := group[args[1]]
+	if !ok {
+		fmt.Fprintf(stderr, "Неизвестная команда %q\n\n", args[0]+" "+args[1])
+		printGroupUsage(stderr, args[0])
+		return 2
+	}
+
+	if err := models.InitDB(); err != nil {
+		fmt.Fprintf(stderr, "Ошибка инициализации базы данных: %v\n", err)
+		return 1
+	}
+	if err := cmd.run(args[2:]); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+		fmt.Fprintf(stderr, "Ошибка: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// printUsage Справка по всем командам
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Использование: siyuan-share [команда]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  serve                      Запуск сервера (по умолчанию)")
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeGroup(w, name)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Флаги команды: siyuan-share <группа> <команда> -h")
+}
+
+// printGroupUsage Справка по командам группы
+func printGroupUsage(w io.Writer, group string) {
+	fmt.Fprintln(w, "Использование:")
+	writeGroup(w, group)
+}
+
+func writeGroup(w io.Writer, group string) {
+	names := make([]string, 0, len(groups[group]))
+	for name := range groups[group] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := groups[group][name]
+		fmt.Fprintf(w, "  %-26s %s\n", group+" "+name, cmd.help)
+		if cmd.args != "" {
+			fmt.Fprintf(w, "  %-26s   %s\n", "", cmd.args)
+		}
+	}
+}
+
+// newFlags Набор флагов команды; usage - аргументы для справки
+func newFlags(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Использование: siyuan-share %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs Разбор флагов, которые могут стоять и до, и после позиционных аргументов;
+// want - число обязательных позиционных аргументов
+func parseArgs(fs *flag.FlagSet, args []string, want int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) != want {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return positional, nil
+}
+
+// readPassword Пароль из флага или, если он не указан, первая строка стандартного ввода
+func readPassword(flagValue string) (string, error) {
+	password := flagValue
+	if password == "" {
+		fmt.Fprint(stderr, "Пароль: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if len(password) < models.MinPasswordLength {
+		return "", fmt.Errorf("длина пароля минимум %d символов", models.MinPasswordLength)
+	}
+	return password, nil
+}
+
+// newTable Вывод таблицы с выравниванием столбцов
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+}
+
+// audit Запись действия в журнал аудита; исполнитель - пользователь ОС, запустивший команду
+func audit(e models.AuditEvent) {
+	e.Via = models.AuditViaCLI
+	if u, err := osuser.Current(); err == nil {
+		e.ActorName = u.Username
+	}
+	models.RecordAudit(e)
+}
+
+// yesNo Логическое значение для таблиц
+func yesNo(v bool) string {
+	if v {
+		return "да"
+	}
+	return "нет"
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+var dbCommands = map[string]command{
+	"migrate": {"", "Применение миграций схемы без запуска сервера", dbMigrate},
+	"backup":  {"<файл>", "Резервная копия базы данных (можно выполнять при работающем сервере)", dbBackup},
+	"vacuum":  {"", "Перенос WAL в основной файл и сжатие базы данных", dbVacuum},
+}
+
+// dbMigrate Миграции выполняются при открытии базы данных (models.InitDB) перед любой командой
+func dbMigrate(args []string) error {
+	fs := newFlags("db migrate", "")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "Схема базы данных актуальна")
+	return nil
+}
+
+// dbBackup Резервная копия базы данных в новый файл
+func dbBackup(args []string) error {
+	fs := newFlags("db backup", "<файл>")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(pos[0]); err == nil {
+		return fmt.Errorf("файл %s уже существует", pos[0])
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := models.BackupDB(pos[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Резервная копия сохранена в %s\n", pos[0])
+	return nil
+}
+
+// dbVacuum Сжатие базы данных
+func dbVacuum(args []string) error {
+	fs := newFlags("db vacuum", "")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if err := models.VacuumDB(); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "Готово")
+	return nil
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/mihazzz123/siyuan-share/keys"
+)
+
+var keysCommands = map[string]command{
+	"rotate": {"", "Новый ключ подписи JWT (прежний проверяет выданные токены ещё JWT_KEY_RETAIN)", keysRotate},
+}
+
+// keysRotate Внеплановая ротация ключа подписи в файле ключей DATA_DIR; работающий сервер
+// читает файл при запуске, поэтому новый ключ начинает подписывать токены после перезапуска
+func keysRotate(args []string) error {
+	fs := newFlags("keys rotate", "")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	m, err := keys.NewManager(keys.ConfigFromEnv(false))
+	if err != nil {
+		return err
+	}
+	kid, err := m.Rotate()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Новый ключ подписи: %s; перезапустите сервер, чтобы он начал подписывать токены этим ключом\n", kid)
+	return nil
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mihazzz123/siyuan-share/jobs"
+	"github.com/mihazzz123/siyuan-share/models"
+	"github.com/mihazzz123/siyuan-share/userdata"
+)
+
+var shareCommands = map[string]command{
+	"list":   {"[-user <пользователь>] [-q <строка>] [-limit N]", "Публикации всех пользователей", shareList},
+	"purge":  {"[-grace 168h]", "Окончательное удаление истёкших и удалённых публикаций", sharePurge},
+	"export": {"<ID публикации> [-o <файл>] [-format md|json]", "Выгрузка публикации", shareExport},
+	"import": {"<архив.zip> -user <пользователь> [-dry-run]", "Импорт публикаций из архива экспорта с сохранением ID", shareImport},
+}
+
+// shareList Публикации всех пользователей, новые первыми
+func shareList(args []string) error {
+	fs := newFlags("share list", "[-user <пользователь>] [-q <строка>] [-limit N]")
+	username := fs.String("user", "", "Владелец (имя, email или ID)")
+	query := fs.String("q", "", "Подстрока заголовка, ID публикации или документа")
+	limit := fs.Int("limit", 100, "Максимальное число строк")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	filter := models.ShareFilter{Query: *query}
+	if *username != "" {
+		user, err := models.FindUser(*username)
+		if err != nil {
+			return err
+		}
+		filter.UserID = user.ID
+	}
+	list, total, err := models.ListAllShares(filter, 0, *limit)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tw := newTable()
+	fmt.Fprintln(tw, "ID\tВЛАДЕЛЕЦ\tЗАГОЛОВОК\tПАРОЛЬ\tИСТЕКАЕТ\tПРОСМОТРЫ\tСОЗДАНА")
+	for _, s := range list {
+		expire := s.ExpireAt.Format("2006-01-02 15:04")
+		if now.After(s.ExpireAt) {
+			expire += " (истекла)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", s.ID, s.Username, s.DocTitle, yesNo(s.RequirePassword), expire,
+			s.ViewCount, s.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	tw.Flush()
+	fmt.Fprintf(stdout, "Всего: %d\n", total)
+	return nil
+}
+
+// sharePurge Однократный проход очистки, как у фоновой задачи
+func sharePurge(args []string) error {
+	fs := newFlags("share purge", "[-grace 168h]")
+	grace := fs.Duration("grace", jobs.SweeperConfigFromEnv().Grace, "Срок хранения после истечения или удаления (по умолчанию SWEEP_GRACE)")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	report, err := models.PurgeShares(time.Now().Add(-*grace))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Удалено публикаций: %d (истёкших %d, удалённых %d, ссылаемых блоков %d), ревизий: %d\n",
+		report.Total(), report.Expired, report.Deleted, report.ChildShares, report.Revisions)
+	return nil
+}
+
+// shareExport Выгрузка публикации в Markdown (с заголовком) или JSON
+func shareExport(args []string) error {
+	fs := newFlags("share export", "<ID публикации> [-o <файл>] [-format md|json]")
+	output := fs.String("o", "", "Файл (по умолчанию стандартный вывод)")
+	format := fs.String("format", "md", "Формат: md или json")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *format != "md" && *format != "json" {
+		fs.Usage()
+		return errUsage
+	}
+	share, err := models.FindShare(pos[0])
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if *format == "json" {
+		if data, err = json.MarshalIndent(share, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	} else {
+		data = []byte("# " + share.DocTitle + "\n\n" + share.Content + "\n")
+	}
+
+	if *output == "" {
+		_, err = stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "Публикация %s сохранена в %s\n", share.ID, *output)
+	return nil
+}
+
+// shareImport Импорт публикаций из архива экспорта (GET /api/user/export) в аккаунт пользователя
+func shareImport(args []string) error {
+	fs := newFlags("share import", "<архив.zip> -user <пользователь> [-dry-run]")
+	username := fs.String("user", "", "Владелец импортируемых публикаций (имя, email или ID)")
+	dryRun := fs.Bool("dry-run", false, "Только проверить конфликты, ничего не записывая")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *username == "" {
+		fs.Usage()
+		return errUsage
+	}
+	user, err := models.FindUser(*username)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(pos[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	items, err := userdata.ReadShares(f, info.Size())
+	if err != nil {
+		return err
+	}
+	report, err := models.ImportShares(user.ID, items, *dryRun)
+	if err != nil {
+		return err
+	}
+	if !report.DryRun {
+		audit(models.AuditEvent{
+			UserID: user.ID, Action: models.AuditShareImport, TargetType: "user", TargetID: user.ID,
+			Detail: fmt.Sprintf("%s: imported %d (remapped %d), skipped %d", filepath.Base(pos[0]), report.Imported, report.Remapped, report.Skipped),
+		})
+	}
+
+	if len(report.Conflicts) > 0 {
+		tw := newTable()
+		fmt.Fprintln(tw, "ID\tДОКУМЕНТ\tКОНФЛИКТ\tНОВЫЙ ID\tСУЩЕСТВУЮЩАЯ")
+		for _, c := range report.Conflicts {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.ShareID, c.DocID, c.Reason, dash(c.NewID), dash(c.ExistingID))
+		}
+		tw.Flush()
+	}
+	prefix := "Импортировано"
+	if report.DryRun {
+		prefix = "Пробный импорт, будет импортировано"
+	}
+	fmt.Fprintf(stdout, "%s публикаций для %s: %d (с новым ID %d), пропущено: %d, версий: %d\n",
+		prefix, user.Username, report.Imported, report.Remapped, report.Skipped, report.Revisions)
+	return nil
+}
+
+// dash Пустое значение для таблиц
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
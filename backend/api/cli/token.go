@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+var tokenCommands = map[string]command{
+	"create": {tokenCreateArgs, "Создание API токена пользователя", tokenCreate},
+	"list":   {"<пользователь>", "API токены пользователя", tokenList},
+	"revoke": {"<ID токена>", "Отзыв API токена", tokenRevoke},
+}
+
+const tokenCreateArgs = "<пользователь> -name <имя> [-scopes share:read,share:write] [-expires 720h] [-cidrs 10.0.0.0/8] [-rotation-days N]"
+
+// tokenCreate Создание API токена по тем же правилам, что и в Web-интерфейсе
+func tokenCreate(args []string) error {
+	fs := newFlags("token create", tokenCreateArgs)
+	name := fs.String("name", "", "Имя токена")
+	scopes := fs.String("scopes", "", "Области действия через запятую (по умолчанию: "+strings.Join(models.DefaultTokenScopes, ",")+")")
+	expires := fs.Duration("expires", 0, "Срок действия, например 720h (по умолчанию бессрочный с учётом TOKEN_MAX_LIFETIME)")
+	cidrs := fs.String("cidrs", "", "Разрешённые диапазоны IP через запятую")
+	rotationDays := fs.Int("rotation-days", 0, "Период автоматической ротации в днях")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *name == "" {
+		fs.Usage()
+		return errUsage
+	}
+	user, err := models.FindUser(pos[0])
+	if err != nil {
+		return err
+	}
+
+	in := models.TokenInput{
+		UserID:       user.ID,
+		Name:         *name,
+		MaxScopes:    models.UserScopes(user),
+		AllowedCIDRs: splitList(*cidrs),
+		RotationDays: *rotationDays,
+	}
+	if *scopes != "" {
+		in.Scopes = splitList(*scopes)
+	}
+	if *expires > 0 {
+		t := time.Now().Add(*expires)
+		in.ExpiresAt = &t
+	}
+	ut, err := models.CreateUserToken(in)
+	if errors.Is(err, models.ErrScopesNotGrantable) {
+		return errors.New("области действия недоступны пользователю (admin - только администраторам)")
+	}
+	if err != nil {
+		return err
+	}
+	audit(models.AuditEvent{
+		UserID: user.ID, Action: models.AuditTokenCreate, TargetType: "token", TargetID: ut.ID,
+		Detail: ut.Name + "; scopes: " + strings.Join(ut.ScopeList(), " "),
+	})
+	fmt.Fprintf(stdout, "API токен %s (%s) для %s: %s\n", ut.Name, ut.ID, user.Username, ut.PlainToken)
+	fmt.Fprintf(stdout, "Области действия: %s\n", strings.Join(ut.ScopeList(), " "))
+	if ut.ExpiresAt != nil {
+		fmt.Fprintf(stdout, "Действует до: %s\n", ut.ExpiresAt.Format(time.RFC3339))
+	}
+	fmt.Fprintln(stdout, "Токен показывается один раз.")
+	return nil
+}
+
+// tokenList API токены пользователя (без текста токенов)
+func tokenList(args []string) error {
+	fs := newFlags("token list", "<пользователь>")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	user, err := models.FindUser(pos[0])
+	if err != nil {
+		return err
+	}
+	tokens, err := models.ListUserTokens(user.ID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tw := newTable()
+	fmt.Fprintln(tw, "ID\tИМЯ\tОБЛАСТИ\tСТАТУС\tИСПОЛЬЗОВАН\tСОЗДАН")
+	for _, t := range tokens {
+		status := "активен"
+		switch {
+		case t.Revoked:
+			status = "отозван"
+		case t.IsExpired(now):
+			status = "истёк"
+		}
+		lastUsed := "-"
+		if t.LastUsedAt != nil {
+			lastUsed = t.LastUsedAt.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", t.ID, t.Name, strings.Join(t.ScopeList(), ","), status, lastUsed, t.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	return tw.Flush()
+}
+
+// tokenRevoke Отзыв API токена любого пользователя
+func tokenRevoke(args []string) error {
+	fs := newFlags("token revoke", "<ID токена>")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	var owner string
+	models.DB.Model(&models.UserToken{}).Where("id = ?", pos[0]).Limit(1).Pluck("user_id", &owner)
+	ok, err := models.RevokeUserToken("", pos[0])
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("токен не найден или уже отозван")
+	}
+	audit(models.AuditEvent{UserID: owner, Action: models.AuditAdminToken, TargetType: "token", TargetID: pos[0]})
+	fmt.Fprintf(stdout, "Токен %s отозван\n", pos[0])
+	return nil
+}
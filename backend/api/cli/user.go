@@ -0,0 +1,209 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+var userCommands = map[string]command{
+	"create":  {userCreateArgs, "Создание пользователя (в обход правил регистрации)", userCreate},
+	"list":    {"[-q <строка>] [-inactive] [-limit N]", "Список пользователей", userList},
+	"disable": {"<пользователь>", "Отключение: сессии завершаются, API токены перестают действовать", userSetActive(false)},
+	"enable":  {"<пользователь>", "Включение", userSetActive(true)},
+	"passwd":  {"<пользователь> [-password <пароль>]", "Новый пароль, сессии завершаются", userPasswd},
+	"admin":   {"<пользователь> [-revoke]", "Назначение или снятие роли администратора", userAdmin},
+}
+
+const userCreateArgs = "-username <имя> -email <почта> [-password <пароль>] [-admin] [-token-name <имя>]"
+
+// userCreate Создание пользователя в обход правил регистрации; первый пользователь - администратор
+func userCreate(args []string) error {
+	fs := newFlags("user create", userCreateArgs)
+	username := fs.String("username", "", "Имя пользователя")
+	email := fs.String("email", "", "Email")
+	password := fs.String("password", "", "Пароль (если не указан - читается из стандартного ввода)")
+	admin := fs.Bool("admin", false, "Назначить администратором")
+	tokenName := fs.String("token-name", "", "Создать API токен с этим именем (области действия по умолчанию)")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *username == "" || *email == "" {
+		fs.Usage()
+		return errUsage
+	}
+	pw, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+	hash, err := models.HashPassword(pw)
+	if err != nil {
+		return err
+	}
+
+	user := &models.User{
+		ID:           models.NewUserID(),
+		Username:     *username,
+		Email:        *email,
+		PasswordHash: hash,
+		IsActive:     true,
+	}
+	if err := models.CreateUser(user); err != nil {
+		if errors.Is(err, models.ErrUserExists) {
+			return errors.New("пользователь с таким именем или email уже существует")
+		}
+		return err
+	}
+	if *admin && !user.IsAdmin {
+		if err := models.SetUserAdmin(user.ID, true); err != nil {
+			return err
+		}
+		user.IsAdmin = true
+	}
+
+	audit(models.AuditEvent{
+		UserID: user.ID, Action: models.AuditRegister, TargetType: "user", TargetID: user.ID, Detail: "admin: " + yesNo(user.IsAdmin),
+	})
+	fmt.Fprintf(stdout, "Пользователь создан: %s (%s)\n", user.Username, user.ID)
+	fmt.Fprintf(stdout, "Email: %s\n", user.Email)
+	fmt.Fprintf(stdout, "Администратор: %s\n", yesNo(user.IsAdmin))
+
+	if *tokenName != "" {
+		ut, err := models.CreateUserToken(models.TokenInput{UserID: user.ID, Name: *tokenName, MaxScopes: models.UserScopes(user)})
+		if err != nil {
+			return fmt.Errorf("ошибка создания API токена: %w", err)
+		}
+		audit(models.AuditEvent{UserID: user.ID, Action: models.AuditTokenCreate, TargetType: "token", TargetID: ut.ID, Detail: ut.Name})
+		fmt.Fprintf(stdout, "API токен (%s): %s\n", ut.Name, ut.PlainToken)
+		fmt.Fprintln(stdout, "Токен показывается один раз, сохраните его для настройки плагина.")
+	}
+	return nil
+}
+
+// userList Список пользователей с числом публикаций и API токенов
+func userList(args []string) error {
+	fs := newFlags("user list", "[-q <строка>] [-inactive] [-limit N]")
+	query := fs.String("q", "", "Подстрока имени пользователя или email")
+	inactive := fs.Bool("inactive", false, "Только отключённые")
+	limit := fs.Int("limit", 100, "Максимальное число строк")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	filter := models.UserFilter{Query: *query}
+	if *inactive {
+		active := false
+		filter.Active = &active
+	}
+	list, total, err := models.ListUsers(filter, 0, *limit)
+	if err != nil {
+		return err
+	}
+	tw := newTable()
+	fmt.Fprintln(tw, "ID\tИМЯ\tEMAIL\tАКТИВЕН\tАДМИН\tПУБЛИКАЦИИ\tТОКЕНЫ\tСОЗДАН")
+	for _, u := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n", u.ID, u.Username, u.Email, yesNo(u.IsActive), yesNo(u.IsAdmin),
+			u.ShareCount, u.TokenCount, u.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	tw.Flush()
+	fmt.Fprintf(stdout, "Всего: %d\n", total)
+	return nil
+}
+
+// userSetActive Отключение или включение пользователя
+func userSetActive(active bool) func(args []string) error {
+	name := map[bool]string{true: "enable", false: "disable"}[active]
+	return func(args []string) error {
+		fs := newFlags("user "+name, "<пользователь>")
+		pos, err := parseArgs(fs, args, 1)
+		if err != nil {
+			return err
+		}
+		user, err := models.FindUser(pos[0])
+		if err != nil {
+			return err
+		}
+		if err := models.SetUserActive(user.ID, active); err != nil {
+			return err
+		}
+		audit(models.AuditEvent{
+			UserID: user.ID, Action: models.AuditAdminUser, TargetType: "user", TargetID: user.ID, Detail: fmt.Sprintf("active: %t", active),
+		})
+		if active {
+			fmt.Fprintf(stdout, "Пользователь %s включён\n", user.Username)
+		} else {
+			fmt.Fprintf(stdout, "Пользователь %s отключён\n", user.Username)
+		}
+		return nil
+	}
+}
+
+// userPasswd Новый пароль пользователя
+func userPasswd(args []string) error {
+	fs := newFlags("user passwd", "<пользователь> [-password <пароль>]")
+	password := fs.String("password", "", "Новый пароль (если не указан - читается из стандартного ввода)")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	user, err := models.FindUser(pos[0])
+	if err != nil {
+		return err
+	}
+	pw, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+	hash, err := models.HashPassword(pw)
+	if err != nil {
+		return err
+	}
+	sessions, tokens, err := models.SetUserPassword(user.ID, hash)
+	if err != nil {
+		return err
+	}
+	audit(models.AuditEvent{
+		UserID: user.ID, Action: models.AuditAdminPassword, TargetType: "user", TargetID: user.ID,
+		Detail: fmt.Sprintf("new password set; revoked sessions: %d, revoked tokens: %d", sessions, tokens),
+	})
+	fmt.Fprintf(stdout, "Пароль пользователя %s изменён: завершено сессий: %d, отозвано API токенов: %d\n", user.Username, sessions, tokens)
+	return nil
+}
+
+// userAdmin Назначение или снятие роли администратора
+func userAdmin(args []string) error {
+	fs := newFlags("user admin", "<пользователь> [-revoke]")
+	revoke := fs.Bool("revoke", false, "Снять роль администратора")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	user, err := models.FindUser(pos[0])
+	if err != nil {
+		return err
+	}
+	if err := models.SetUserAdmin(user.ID, !*revoke); err != nil {
+		return err
+	}
+	audit(models.AuditEvent{
+		UserID: user.ID, Action: models.AuditAdminUser, TargetType: "user", TargetID: user.ID, Detail: fmt.Sprintf("admin: %t", !*revoke),
+	})
+	state := "назначен администратором"
+	if *revoke {
+		state = "больше не администратор"
+	}
+	fmt.Fprintf(stdout, "Пользователь %s %s\n", user.Username, state)
+	return nil
+}
+
+// splitList Список через запятую без пустых элементов
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mihazzz123/siyuan-share/middleware"
+	"github.com/mihazzz123/siyuan-share/models"
+	"github.com/mihazzz123/siyuan-share/ratelimit"
+	"github.com/mihazzz123/siyuan-share/userdata"
+)
+
+// UpdateProfileRequest Изменение профиля (поля без значения не меняются)
+type UpdateProfileRequest struct {
+	Username string `json:"username" binding:"omitempty,min=3,max=100"`
+	Email    string `json:"email" binding:"omitempty,email"`
+}
+
+// ChangePasswordRequest Смена пароля
+type ChangePasswordRequest struct {
+	OldPassword string `json:"oldPassword" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required,min=6,max=200"`
+}
+
+// DeleteAccountRequest Подтверждение удаления аккаунта паролем
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// checkCurrentPassword Проверка текущего пароля с защитой от перебора (как при входе);
+// при ошибке ответ уже отправлен. Неверный пароль - 403, а не 401: на 401 Web-интерфейс
+// обновляет сессию и повторяет запрос, что засчитывало бы лишнюю попытку. Неверный пароль
+// записывается в журнал аудита как неудачная попытка action
+func checkCurrentPassword(c *gin.Context, user *models.User, password, action string) bool {
+	limitKeys := []string{ratelimit.UserKey(user.Username), ratelimit.IPKey(c.ClientIP())}
+	if wait := ratelimit.Default.Check(limitKeys...); wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return false
+	}
+	if user.PasswordHash == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Password not set, use password reset to set one"})
+		return false
+	}
+	if !user.CheckPassword(password) {
+		middleware.Audit(c, models.AuditEvent{
+			Action: action, TargetType: "user", TargetID: user.ID, Result: models.AuditFailure, Detail: "invalid password",
+		})
+		if wait := ratelimit.Default.Fail(limitKeys...); wait > 0 {
+			respondTooManyAttempts(c, wait)
+			return false
+		}
+		c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Invalid password"})
+		return false
+	}
+	ratelimit.Default.Success(ratelimit.UserKey(user.Username))
+	return true
+}
+
+// UpdateProfile Смена имени пользователя и email; при смене email подтверждение сбрасывается
+// и отправляется новое письмо
+func UpdateProfile(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	user, emailChanged, err := models.UpdateProfile(c.GetString("userID"), req.Username, req.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrUserExists):
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Username or email already exists"})
+		case errors.Is(err, models.ErrEmailDomainNotAllowed):
+			c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "This email domain is not allowed"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to update profile: " + err.Error()})
+		}
+		return
+	}
+
+	detail := "username: " + user.Username
+	if emailChanged {
+		detail += ", email changed"
+	}
+	middleware.Audit(c, models.AuditEvent{Action: models.AuditProfileUpdate, TargetType: "user", TargetID: user.ID, Detail: detail})
+
+	if emailChanged {
+		if baseURL, ok := publicBaseURL(c); ok {
+			if err := sendEmailLink(baseURL, user, models.PurposeVerifyEmail); err != nil {
+				log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"id": user.ID, "username": user.Username, "email": user.Email, "emailVerified": user.EmailVerifiedAt != nil,
+	}})
+}
+
+// ChangePassword Смена пароля по текущему паролю: другие сессии завершаются, API токены отзываются
+func ChangePassword(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	if !checkCurrentPassword(c, user, req.OldPassword, models.AuditPasswordChange) {
+		return
+	}
+	hash, err := models.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to hash password"})
+		return
+	}
+	sessions, tokens, err := models.ChangePassword(user.ID, hash, c.GetString("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to change password: " + err.Error()})
+		return
+	}
+	middleware.Audit(c, models.AuditEvent{
+		Action: models.AuditPasswordChange, TargetType: "user", TargetID: user.ID,
+		Detail: fmt.Sprintf("revoked sessions: %d, revoked tokens: %d", sessions, tokens),
+	})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"revokedSessions": sessions, "revokedTokens": tokens}})
+}
+
+// DeleteAccount Удаление аккаунта со всеми публикациями и API токенами после подтверждения паролем
+func DeleteAccount(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	if !checkCurrentPassword(c, user, req.Password, models.AuditAccountDelete) {
+		return
+	}
+	shares, err := models.DeleteAccount(user.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrLastAdmin) {
+			middleware.Audit(c, models.AuditEvent{
+				Action: models.AuditAccountDelete, TargetType: "user", TargetID: user.ID, Result: models.AuditDenied, Detail: "last administrator",
+			})
+			c.JSON(http.StatusConflict, gin.H{"code": 1, "msg": "The last active administrator cannot delete the account, promote another administrator first"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to delete account: " + err.Error()})
+		return
+	}
+	log.Printf("User %s (%s) deleted the account, %d shares removed", user.Username, user.ID, shares)
+	middleware.Audit(c, models.AuditEvent{
+		Action: models.AuditAccountDelete, TargetType: "user", TargetID: user.ID, Detail: fmt.Sprintf("deleted shares: %d", shares),
+	})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"deletedShares": shares}})
+}
+
+// ExportUserData Архив данных пользователя (zip, формат userdata): профиль, метаданные API токенов,
+// сессии и публикации с содержимым и историей версий. Архив передаётся потоком
+func ExportUserData(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	filename := "siyuan-share-export-" + time.Now().Format("20060102-150405") + ".zip"
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	if err := userdata.Export(c.Writer, user.ID); err != nil {
+		// Заголовки уже отправлены: клиент получит оборванный архив
+		log.Printf("Failed to export data of user %s: %v", user.ID, err)
+		middleware.Audit(c, models.AuditEvent{
+			Action: models.AuditDataExport, TargetType: "user", TargetID: user.ID, Result: models.AuditFailure, Detail: err.Error(),
+		})
+		c.Abort()
+		return
+	}
+	middleware.Audit(c, models.AuditEvent{Action: models.AuditDataExport, TargetType: "user", TargetID: user.ID})
+}
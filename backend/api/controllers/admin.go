@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mihazzz123/siyuan-share/jobs"
+	"github.com/mihazzz123/siyuan-share/middleware"
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+// UpdateUserRequest Изменение пользователя администратором (поля без значения не меняются)
+type UpdateUserRequest struct {
+	IsActive *bool `json:"isActive"`
+	IsAdmin  *bool `json:"isAdmin"`
+}
+
+// AdminPasswordRequest Сброс пароля администратором: новый пароль или, если он не указан,
+// письмо со ссылкой сброса
+type AdminPasswordRequest struct {
+	Password string `json:"password" binding:"omitempty,min=6,max=200"`
+}
+
+// pageParams Параметры пагинации page/size (size не больше 100)
+func pageParams(c *gin.Context) (page, size, offset int) {
+	page, size = 1, 20
+	if v, err := strconv.Atoi(c.Query("page")); err == nil && v > 0 {
+		page = v
+	}
+	if v, err := strconv.Atoi(c.Query("size")); err == nil && v > 0 {
+		size = min(v, 100)
+	}
+	return page, size, (page - 1) * size
+}
+
+// boolQuery Необязательный логический параметр запроса
+func boolQuery(c *gin.Context, name string) *bool {
+	v, err := strconv.ParseBool(c.Query(name))
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// respondAdminError Ответ на ошибку операции администратора
+func respondAdminError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, models.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "User not found"})
+	case errors.Is(err, models.ErrShareNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Share not found"})
+	case errors.Is(err, models.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"code": 1, "msg": "The last active administrator cannot be disabled or demoted"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to " + action + ": " + err.Error()})
+	}
+}
+
+// AdminListUsers Поиск пользователей: q - подстрока имени или email, active/admin - фильтры
+func AdminListUsers(c *gin.Context) {
+	page, size, offset := pageParams(c)
+	list, total, err := models.ListUsers(models.UserFilter{
+		Query:  c.Query("q"),
+		Active: boolQuery(c, "active"),
+		Admin:  boolQuery(c, "admin"),
+	}, offset, size)
+	if err != nil {
+		respondAdminError(c, "list users", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"items": list, "page": page, "size": size, "total": total}})
+}
+
+// AdminGetUser Пользователь с его API токенами и числом действующих сессий
+func AdminGetUser(c *gin.Context) {
+	user, err := models.FindUser(c.Param("id"))
+	if err != nil {
+		respondAdminError(c, "load user", err)
+		return
+	}
+	tokens, err := models.ListUserTokens(user.ID)
+	if err != nil {
+		respondAdminError(c, "list tokens", err)
+		return
+	}
+	sessions, err := models.ListSessions(user.ID)
+	if err != nil {
+		respondAdminError(c, "list sessions", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"user": user, "tokens": tokenList(tokens), "activeSessions": len(sessions),
+	}})
+}
+
+// AdminUpdateUser Включение/отключение пользователя и назначение роли администратора.
+// Свой статус и роль администратор изменить не может
+func AdminUpdateUser(c *gin.Context) {
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	user, err := models.FindUser(c.Param("id"))
+	if err != nil {
+		respondAdminError(c, "load user", err)
+		return
+	}
+	if user.ID == c.GetString("userID") {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "You cannot change your own status or role"})
+		return
+	}
+	if req.IsActive != nil {
+		if err := models.SetUserActive(user.ID, *req.IsActive); err != nil {
+			respondAdminError(c, "update user", err)
+			return
+		}
+	}
+	if req.IsAdmin != nil {
+		if err := models.SetUserAdmin(user.ID, *req.IsAdmin); err != nil {
+			respondAdminError(c, "update user", err)
+			return
+		}
+	}
+	detail := ""
+	if req.IsActive != nil {
+		detail += fmt.Sprintf("active: %t ", *req.IsActive)
+	}
+	if req.IsAdmin != nil {
+		detail += fmt.Sprintf("admin: %t", *req.IsAdmin)
+	}
+	middleware.Audit(c, models.AuditEvent{
+		UserID: user.ID, Action: models.AuditAdminUser, TargetType: "user", TargetID: user.ID, Detail: strings.TrimSpace(detail),
+	})
+	if user, err = models.FindUser(user.ID); err != nil {
+		respondAdminError(c, "load user", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": user})
+}
+
+// AdminResetPassword Новый пароль пользователя (сессии пользователя завершаются, API токены отзываются)
+// или письмо со ссылкой сброса
+func AdminResetPassword(c *gin.Context) {
+	var req AdminPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	user, err := models.FindUser(c.Param("id"))
+	if err != nil {
+		respondAdminError(c, "load user", err)
+		return
+	}
+	if req.Password == "" {
+		baseURL, ok := publicBaseURL(c)
+		if !ok {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"code": 1, "msg": "PUBLIC_URL is not configured"})
+			return
+		}
+		if err := sendEmailLink(baseURL, user, models.PurposeResetPassword); err != nil {
+			respondAdminError(c, "send email", err)
+			return
+		}
+		middleware.Audit(c, models.AuditEvent{
+			UserID: user.ID, Action: models.AuditAdminPassword, TargetType: "user", TargetID: user.ID, Detail: "reset link sent by email",
+		})
+		c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"emailSent": true}})
+		return
+	}
+	hash, err := models.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to hash password"})
+		return
+	}
+	sessions, tokens, err := models.SetUserPassword(user.ID, hash)
+	if err != nil {
+		respondAdminError(c, "reset password", err)
+		return
+	}
+	middleware.Audit(c, models.AuditEvent{
+		UserID: user.ID, Action: models.AuditAdminPassword, TargetType: "user", TargetID: user.ID,
+		Detail: fmt.Sprintf("new password set; revoked sessions: %d, revoked tokens: %d", sessions, tokens),
+	})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"emailSent": false, "revokedSessions": sessions, "revokedTokens": tokens}})
+}
+
+// AdminListUserTokens API токены пользователя
+func AdminListUserTokens(c *gin.Context) {
+	user, err := models.FindUser(c.Param("id"))
+	if err != nil {
+		respondAdminError(c, "load user", err)
+		return
+	}
+	tokens, err := models.ListUserTokens(user.ID)
+	if err != nil {
+		respondAdminError(c, "list tokens", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"items": tokenList(tokens)}})
+}
+
+// AdminRevokeToken Отзыв API токена любого пользователя
+func AdminRevokeToken(c *gin.Context) {
+	// Владелец токена нужен для журнала аудита
+	var owner string
+	models.DB.Model(&models.UserToken{}).Where("id = ?", c.Param("id")).Limit(1).Pluck("user_id", &owner)
+	ok, err := models.RevokeUserToken("", c.Param("id"))
+	if err != nil {
+		respondAdminError(c, "revoke token", err)
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Token not found or already revoked"})
+		return
+	}
+	middleware.Audit(c, models.AuditEvent{UserID: owner, Action: models.AuditAdminToken, TargetType: "token", TargetID: c.Param("id")})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success"})
+}
+
+// AdminListShares Публикации всех пользователей: q - подстрока заголовка или ID, userId - владелец
+func AdminListShares(c *gin.Context) {
+	page, size, offset := pageParams(c)
+	list, total, err := models.ListAllShares(models.ShareFilter{Query: c.Query("q"), UserID: c.Query("userId")}, offset, size)
+	if err != nil {
+		respondAdminError(c, "list shares", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"items": list, "page": page, "size": size, "total": total}})
+}
+
+// AdminGetShare Публикация с содержимым (в том числе защищённая паролем) для модерации
+func AdminGetShare(c *gin.Context) {
+	share, err := models.FindShare(c.Param("id"))
+	if err != nil {
+		respondAdminError(c, "load share", err)
+		return
+	}
+	owner, err := models.FindUser(share.UserID)
+	if err != nil && !errors.Is(err, models.ErrUserNotFound) {
+		respondAdminError(c, "load user", err)
+		return
+	}
+	data := gin.H{"share": share}
+	if owner != nil {
+		data["owner"] = gin.H{"id": owner.ID, "username": owner.Username, "email": owner.Email, "isActive": owner.IsActive}
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": data})
+}
+
+// AdminDeleteShare Удаление публикации любого пользователя вместе с публикациями ссылаемых блоков
+func AdminDeleteShare(c *gin.Context) {
+	var owner string
+	if share, err := models.FindShare(c.Param("id")); err == nil {
+		owner = share.UserID
+	}
+	count, err := models.DeleteShareByID(c.Param("id"))
+	if err != nil {
+		respondAdminError(c, "delete share", err)
+		return
+	}
+	middleware.Audit(c, models.AuditEvent{
+		UserID: owner, Action: models.AuditAdminShare, TargetType: "share", TargetID: c.Param("id"), Detail: fmt.Sprintf("deleted: %d", count),
+	})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"deleted": count}})
+}
+
+// adminStats Статистика экземпляра с итогами последнего прохода фоновой очистки
+type adminStats struct {
+	*models.InstanceStats
+	LastSweep *jobs.SweepResult `json:"lastSweep"` // null, если очистка ещё не запускалась
+}
+
+// AdminStats Статистика экземпляра
+func AdminStats(c *gin.Context) {
+	stats, err := models.GetInstanceStats()
+	if err != nil {
+		respondAdminError(c, "collect stats", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": adminStats{InstanceStats: stats, LastSweep: jobs.LastSweep()}})
+}
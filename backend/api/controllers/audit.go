@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+// timeQuery Необязательный параметр времени: RFC 3339 или дата YYYY-MM-DD
+func timeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, true
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return &t, true
+		}
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid " + name + ", expected RFC 3339 time or YYYY-MM-DD"})
+	return nil, false
+}
+
+// listAudit Ответ со страницей журнала аудита; для since/until ошибка формата - 400
+func listAudit(c *gin.Context, f models.AuditFilter) {
+	var ok bool
+	if f.Since, ok = timeQuery(c, "since"); !ok {
+		return
+	}
+	if f.Until, ok = timeQuery(c, "until"); !ok {
+		return
+	}
+	page, size, offset := pageParams(c)
+	list, total, err := models.ListAuditEvents(f, offset, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to list audit events: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"items": list, "page": page, "size": size, "total": total}})
+}
+
+// ListUserAudit Журнал аудита своего аккаунта: события, относящиеся к нему или выполненные им.
+// action - действие или группа ("token."), result, since/until. Только из сессии Web: журнал содержит
+// IP, User-Agent и ID сессий и токенов
+func ListUserAudit(c *gin.Context) {
+	listAudit(c, models.AuditFilter{
+		UserID: c.GetString("userID"),
+		Action: c.Query("action"),
+		Result: c.Query("result"),
+	})
+}
+
+// AdminListAudit Журнал аудита экземпляра: userId - аккаунт, actor - исполнитель, action, result, since/until
+func AdminListAudit(c *gin.Context) {
+	listAudit(c, models.AuditFilter{
+		UserID:  c.Query("userId"),
+		ActorID: c.Query("actor"),
+		Action:  c.Query("action"),
+		Result:  c.Query("result"),
+	})
+}
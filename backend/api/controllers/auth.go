@@ -2,14 +2,16 @@ package controllers
 
 import (
 	"crypto/rand"
-	"encoding/hex"
+	"errors"
+	"log"
 	"net/http"
-	"os"
-	"time"
+	"sync"
 
+	"github.com/mihazzz123/siyuan-share/middleware"
 	"github.com/mihazzz123/siyuan-share/models"
+	"github.com/mihazzz123/siyuan-share/oidc"
+	"github.com/mihazzz123/siyuan-share/ratelimit"
 	"github.com/gin-gonic/gin"
-	jwt "github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -17,45 +19,65 @@ type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=100"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6,max=200"`
+
+	InviteCode string `json:"inviteCode"` // Код приглашения (REGISTRATION_MODE=invite) или токен инициализации
 }
 
-// Register Регистрация пользователя
+// Register Регистрация пользователя по правилам REGISTRATION_MODE; первый пользователь - администратор
 func Register(c *gin.Context) {
+	if !oidc.PasswordLoginAllowed() {
+		c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Password registration is disabled, use single sign-on"})
+		return
+	}
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
 		return
 	}
 
-	// Проверка на дубликаты
-	var count int64
-	models.DB.Model(&models.User{}).Where("username = ?", req.Username).Or("email = ?", req.Email).Count(&count)
-	if count > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Username or email already exists"})
-		return
-	}
-
 	// Хэширование пароля
-	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hash, err := models.HashPassword(req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to hash password"})
 		return
 	}
 
-	user := &models.User{
-		ID:           "user_" + randHex(16),
-		Username:     req.Username,
-		Email:        req.Email,
-		PasswordHash: string(hash),
-		IsActive:     true,
+	user, err := models.RegisterUser(models.Registration, models.RegisterInput{
+		Username:       req.Username,
+		Email:          req.Email,
+		PasswordHash:   hash,
+		InviteCode:     req.InviteCode,
+		BootstrapToken: c.GetHeader("X-Bootstrap-Token"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrUserExists):
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Username or email already exists"})
+		case errors.Is(err, models.ErrRegistrationClosed):
+			c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Registration is closed"})
+		case errors.Is(err, models.ErrInviteRequired), errors.Is(err, models.ErrInviteInvalid):
+			c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "A valid invite code is required"})
+		case errors.Is(err, models.ErrEmailDomainNotAllowed):
+			c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Registration is not allowed for this email domain"})
+		case errors.Is(err, models.ErrBootstrapTokenInvalid):
+			c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "The first account requires the bootstrap token from DATA_DIR/bootstrap_token.txt"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to create user: " + err.Error()})
+		}
+		return
 	}
 
-	if err := models.DB.Create(user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to create user: " + err.Error()})
-		return
+	// Письмо подтверждения email; ошибка отправки не мешает регистрации (письмо можно запросить повторно)
+	if baseURL, ok := publicBaseURL(c); ok {
+		if err := sendEmailLink(baseURL, user, models.PurposeVerifyEmail); err != nil {
+			log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success"})
+	middleware.Audit(c, models.AuditEvent{
+		ActorID: user.ID, ActorName: user.Username, Action: models.AuditRegister, TargetType: "user", TargetID: user.ID,
+	})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"id": user.ID, "isAdmin": user.IsAdmin}})
 }
 
 type LoginRequest struct {
@@ -63,50 +85,125 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// Login Вход пользователя, возврат сессионного JWT
+// Login Вход пользователя, создание сессии и возврат access JWT и refresh токена
 func Login(c *gin.Context) {
+	if !oidc.PasswordLoginAllowed() {
+		c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Password login is disabled, use single sign-on"})
+		return
+	}
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
 		return
 	}
 
+	// Защита от перебора: блокировка по имени пользователя и по IP клиента
+	limitKeys := []string{ratelimit.UserKey(req.Username), ratelimit.IPKey(c.ClientIP())}
+	if wait := ratelimit.Default.Check(limitKeys...); wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
 	var user models.User
-	if err := models.DB.Where("username = ?", req.Username).First(&user).Error; err != nil {
+	// Клиенту всегда отвечаем "Invalid credentials": причина отказа пишется только в журнал аудита
+	loginFailed := func(reason string) {
+		auditLoginFailed(c, user.ID, req.Username, reason)
+		if wait := ratelimit.Default.Fail(limitKeys...); wait > 0 {
+			respondTooManyAttempts(c, wait)
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Invalid credentials"})
-		return
 	}
-	if user.PasswordHash == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Password not set"})
+
+	// Пароль сравнивается и для неизвестных пользователей и пользователей без пароля,
+	// чтобы время ответа не выдавало существование аккаунта
+	res := models.DB.Where("username = ?", req.Username).Limit(1).Find(&user)
+	hash := user.PasswordHash
+	if hash == "" {
+		hash = dummyPasswordHash()
+	}
+	passwordErr := bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.Password))
+	switch {
+	case res.Error != nil || res.RowsAffected == 0:
+		loginFailed("Unknown user")
+		return
+	case user.PasswordHash == "":
+		loginFailed("Password not set")
+		return
+	case passwordErr != nil:
+		loginFailed("Invalid password")
+		return
+	case !user.IsActive:
+		loginFailed("User is disabled")
 		return
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Invalid credentials"})
+	// Включена 2FA: сессия создаётся только после второго шага (POST /api/auth/login/2fa).
+	// Счётчик неудач по имени пользователя сбрасывается только после верного кода: иначе
+	// повторный вход по паролю обнулял бы ограничение перебора кодов
+	if user.TOTPEnabled {
+		challenge, err := issueLoginChallenge(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to sign token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+			"twoFactorRequired": true,
+			"challenge":         challenge,
+		}})
 		return
 	}
+	ratelimit.Default.Success(ratelimit.UserKey(req.Username))
 
-	// Генерация JWT
-	secret := os.Getenv("SESSION_SECRET")
-	if secret == "" {
-		secret = "dev-secret"
-	}
-	expires := time.Now().Add(24 * time.Hour)
-	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": expires.Unix(),
-		"iat": time.Now().Unix(),
+	startSession(c, &user, "password")
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// dummyPasswordHash Хэш случайного пароля той же стоимости, что и пароли пользователей
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		dummyHash, _ = bcrypt.GenerateFromPassword(secret, bcrypt.DefaultCost)
+	})
+	return string(dummyHash)
+}
+
+// auditLoginFailed Запись неудачной попытки входа; userID пуст, если пользователь не найден
+func auditLoginFailed(c *gin.Context, userID, username, reason string) {
+	middleware.Audit(c, models.AuditEvent{
+		UserID: userID, Action: models.AuditLoginFailed, TargetType: "user", TargetID: userID,
+		Result: models.AuditFailure, Detail: reason + " (username: " + username + ")",
+	})
+}
+
+// auditLogin Запись входа: method - password, 2fa или oidc
+func auditLogin(c *gin.Context, user *models.User, session *models.Session, method string) {
+	middleware.Audit(c, models.AuditEvent{
+		ActorID: user.ID, ActorName: user.Username, Via: models.AuditViaSession, Credential: session.ID,
+		Action: models.AuditLogin, TargetType: "session", TargetID: session.ID, Detail: "method: " + method,
+	})
+}
+
+// startSession Создание сессии и выдача пары токенов: короткий access JWT и refresh токен.
+// method - способ входа для журнала аудита
+func startSession(c *gin.Context, user *models.User, method string) {
+	session, refresh, err := models.CreateSession(user.ID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to create session: " + err.Error()})
+		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	s, err := token.SignedString([]byte(secret))
+	data, err := sessionTokens(session, refresh)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to sign token"})
 		return
 	}
+	data["user"] = gin.H{"id": user.ID, "username": user.Username, "email": user.Email}
+	auditLogin(c, user, session, method)
 
-	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
-		"token": s,
-		"user":  gin.H{"id": user.ID, "username": user.Username, "email": user.Email},
-	}})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": data})
 }
 
 // Me Возврат информации о текущем аутентифицированном пользователе
@@ -118,12 +215,6 @@ func Me(c *gin.Context) {
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
-		"id": user.ID, "username": user.Username, "email": user.Email, "isActive": user.IsActive, "createdAt": user.CreatedAt,
+		"id": user.ID, "username": user.Username, "email": user.Email, "isActive": user.IsActive, "totpEnabled": user.TOTPEnabled, "isAdmin": user.IsAdmin, "emailVerified": user.EmailVerifiedAt != nil, "createdAt": user.CreatedAt,
 	}})
 }
-
-func randHex(n int) string {
-	b := make([]byte, n)
-	_, _ = rand.Read(b)
-	return hex.EncodeToString(b)
-}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mihazzz123/siyuan-share/diff"
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+// parseSince Разбор параметра since: unix-время в секундах или RFC3339
+func parseSince(v string) (time.Time, bool) {
+	if ts, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(ts, 0), true
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// GetShareDiff Сравнение двух ревизий публикации (публичный доступ с учётом пароля)
+// Параметры: from, to - номера ревизий (по умолчанию to = текущая, from = to-1);
+// since - момент последнего визита, from вычисляется как ревизия, актуальная на тот момент;
+// mode=summary - только сводка без построчных и поблочных изменений.
+// Ревизия 0 соответствует пустому документу.
+func GetShareDiff(c *gin.Context) {
+	shareID := c.Param("id")
+
+	var share models.Share
+	if err := models.DB.Where("id = ?", shareID).First(&share).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Share not found"})
+		return
+	}
+	if !authorizeShareView(c, &share) {
+		return
+	}
+
+	to := share.Revision
+	if v := c.Query("to"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > share.Revision {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid revision: to"})
+			return
+		}
+		to = n
+	}
+
+	from := to - 1
+	if from < 0 {
+		from = 0
+	}
+	if v := c.Query("since"); v != "" {
+		since, ok := parseSince(v)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid since: expected unix time or RFC3339"})
+			return
+		}
+		rev, err := models.FindShareRevisionAt(share.ID, since)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to load revision: " + err.Error()})
+			return
+		}
+		from = 0
+		if rev != nil {
+			from = rev.Version
+		}
+	}
+	if v := c.Query("from"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > share.Revision {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid revision: from"})
+			return
+		}
+		from = n
+	}
+
+	load := func(version int) (*models.ShareRevision, bool) {
+		if version == 0 {
+			return &models.ShareRevision{ShareID: share.ID}, true
+		}
+		rev, err := models.FindShareRevision(share.ID, version)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to load revision: " + err.Error()})
+			return nil, false
+		}
+		if rev == nil {
+			c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Revision not found"})
+			return nil, false
+		}
+		return rev, true
+	}
+	fromRev, ok := load(from)
+	if !ok {
+		return
+	}
+	toRev, ok := load(to)
+	if !ok {
+		return
+	}
+
+	summaryOnly := c.Query("mode") == "summary"
+	result := diff.Compare(fromRev.Content, toRev.Content, !summaryOnly)
+	data := gin.H{
+		"shareId":       share.ID,
+		"from":          from,
+		"to":            to,
+		"fromCreatedAt": fromRev.CreatedAt,
+		"toCreatedAt":   toRev.CreatedAt,
+		"changed":       result.Summary.Changed() || fromRev.DocTitle != toRev.DocTitle,
+		"titleChanged":  fromRev.DocTitle != toRev.DocTitle,
+		"summary":       result.Summary,
+	}
+	if !summaryOnly {
+		data["lines"] = result.Lines
+		data["blocks"] = result.Blocks
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": data})
+}
@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/mihazzz123/siyuan-share/keys"
+	"github.com/mihazzz123/siyuan-share/mailer"
+	"github.com/mihazzz123/siyuan-share/middleware"
+	"github.com/mihazzz123/siyuan-share/models"
+	"github.com/mihazzz123/siyuan-share/ratelimit"
+)
+
+// mailSendTimeout Время на отправку письма в фоне
+const mailSendTimeout = 30 * time.Second
+
+// EmailTokenRequest Запрос с токеном из письма
+type EmailTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ForgotPasswordRequest Запрос письма для сброса пароля
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest Новый пароль по токену из письма
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6,max=200"`
+}
+
+// publicBaseURL Адрес Web-интерфейса для ссылок в письмах: PUBLIC_URL, а вне режима release -
+// адрес из заголовков запроса (в release ссылки по заголовку Host позволили бы подменить адрес в письме)
+func publicBaseURL(c *gin.Context) (string, bool) {
+	if v := os.Getenv("PUBLIC_URL"); v != "" {
+		return strings.TrimSuffix(v, "/"), true
+	}
+	if gin.Mode() == gin.ReleaseMode {
+		return "", false
+	}
+	proto := c.GetHeader("X-Forwarded-Proto")
+	host := c.GetHeader("X-Forwarded-Host")
+	if proto == "" {
+		if c.Request.TLS != nil {
+			proto = "https"
+		} else {
+			proto = "http"
+		}
+	}
+	if host == "" {
+		host = c.Request.Host
+	}
+	return proto + "://" + strings.TrimSuffix(host, "/"), true
+}
+
+// signEmailToken Подписанная ссылка на одноразовый токен: aud - назначение, jti - ID записи email_tokens
+func signEmailToken(t *models.EmailToken) (string, error) {
+	return keys.Default.Sign(jwt.MapClaims{
+		"aud": t.Purpose,
+		"sub": t.UserID,
+		"jti": t.ID,
+		"exp": t.ExpiresAt.Unix(),
+		"iat": time.Now().Unix(),
+	})
+}
+
+// parseEmailToken Проверка подписи и назначения токена из письма, возврат ID записи
+func parseEmailToken(raw, purpose string) (string, bool) {
+	claims := jwt.MapClaims{}
+	tok, err := keys.Default.Parse(raw, claims, jwt.WithAudience(purpose), jwt.WithExpirationRequired())
+	if err != nil || !tok.Valid {
+		return "", false
+	}
+	jti, _ := claims["jti"].(string)
+	return jti, jti != ""
+}
+
+// sendEmailLink Выпуск токена и отправка письма со ссылкой в фоне (не задерживает ответ и не раскрывает
+// по времени ответа, существует ли адрес)
+func sendEmailLink(baseURL string, user *models.User, purpose string) error {
+	var (
+		ttl     time.Duration
+		path    string
+		subject string
+		text    string
+	)
+	switch purpose {
+	case models.PurposeVerifyEmail:
+		ttl, path = models.EmailVerifyTTL(), "/verify-email"
+		subject = "Подтверждение email"
+		text = "Здравствуйте, %s!\n\nЧтобы подтвердить адрес, откройте ссылку (действует %s):\n\n%s\n\nЕсли вы не регистрировались, просто проигнорируйте это письмо.\n"
+	case models.PurposeResetPassword:
+		ttl, path = models.PasswordResetTTL(), "/reset-password"
+		subject = "Сброс пароля"
+		text = "Здравствуйте, %s!\n\nЧтобы задать новый пароль, откройте ссылку (действует %s):\n\n%s\n\nЕсли вы не запрашивали сброс, просто проигнорируйте это письмо - пароль не изменится.\n"
+	default:
+		return errors.New("unknown email token purpose")
+	}
+
+	t, err := models.IssueEmailToken(user, purpose, ttl)
+	if err != nil {
+		return err
+	}
+	signed, err := signEmailToken(t)
+	if err != nil {
+		return err
+	}
+	link := baseURL + path + "#" + url.Values{"token": {signed}}.Encode()
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: subject,
+		Text:    fmt.Sprintf(text, user.Username, ttl, link),
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+		if err := mailer.Default.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send %s email to user %s: %v", purpose, user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// VerifyEmail Подтверждение email по токену из письма
+func VerifyEmail(c *gin.Context) {
+	var req EmailTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	id, ok := parseEmailToken(req.Token, models.PurposeVerifyEmail)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid or expired link"})
+		return
+	}
+	user, err := models.VerifyEmail(id)
+	if err != nil {
+		if errors.Is(err, models.ErrEmailTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid or expired link"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to verify email: " + err.Error()})
+		return
+	}
+	middleware.Audit(c, models.AuditEvent{
+		ActorID: user.ID, ActorName: user.Username, Action: models.AuditEmailVerify, TargetType: "user", TargetID: user.ID, Detail: user.Email,
+	})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"email": user.Email}})
+}
+
+// ResendVerification Повторная отправка письма подтверждения текущему пользователю
+func ResendVerification(c *gin.Context) {
+	var user models.User
+	if err := models.DB.Where("id = ?", c.GetString("userID")).First(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to load user: " + err.Error()})
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Email is already verified"})
+		return
+	}
+	key := ratelimit.EmailKey(user.Email)
+	if wait := ratelimit.Default.Check(key); wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+	baseURL, ok := publicBaseURL(c)
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"code": 1, "msg": "PUBLIC_URL is not configured"})
+		return
+	}
+	ratelimit.Default.Fail(key)
+	if err := sendEmailLink(baseURL, &user, models.PurposeVerifyEmail); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to send email: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success"})
+}
+
+// ForgotPassword Письмо со ссылкой для сброса пароля. Ответ одинаков для существующих и
+// несуществующих адресов; частые запросы на один адрес молча пропускаются
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	ipKey := ratelimit.MailIPKey(c.ClientIP())
+	if wait := ratelimit.Default.Check(ipKey); wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+	baseURL, ok := publicBaseURL(c)
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"code": 1, "msg": "PUBLIC_URL is not configured"})
+		return
+	}
+	ratelimit.Default.Fail(ipKey)
+
+	emailKey := ratelimit.EmailKey(req.Email)
+	var user models.User
+	res := models.DB.Where("LOWER(email) = ? AND is_active = ?", strings.ToLower(req.Email), true).Limit(1).Find(&user)
+	if res.Error == nil && res.RowsAffected > 0 && ratelimit.Default.Check(emailKey) == 0 {
+		ratelimit.Default.Fail(emailKey)
+		if err := sendEmailLink(baseURL, &user, models.PurposeResetPassword); err != nil {
+			log.Printf("Failed to issue password reset for user %s: %v", user.ID, err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "If the address is registered, a reset link has been sent"})
+}
+
+// ResetPassword Новый пароль по ссылке из письма; все сессии пользователя завершаются, API токены отзываются
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	id, ok := parseEmailToken(req.Token, models.PurposeResetPassword)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid or expired link"})
+		return
+	}
+	hash, err := models.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to hash password"})
+		return
+	}
+	user, sessions, tokens, err := models.ResetPassword(id, hash)
+	if err != nil {
+		if errors.Is(err, models.ErrEmailTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid or expired link"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to reset password: " + err.Error()})
+		return
+	}
+	// Блокировка входа после неудачных попыток снимается
+	ratelimit.Default.Success(ratelimit.UserKey(user.Username), ratelimit.EmailKey(user.Email))
+	middleware.Audit(c, models.AuditEvent{
+		ActorID: user.ID, ActorName: user.Username, Action: models.AuditPasswordReset, TargetType: "user", TargetID: user.ID,
+		Detail: fmt.Sprintf("email link; revoked sessions: %d, revoked tokens: %d", sessions, tokens),
+	})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"revokedSessions": sessions, "revokedTokens": tokens}})
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mihazzz123/siyuan-share/middleware"
+	"github.com/mihazzz123/siyuan-share/models"
+	"github.com/mihazzz123/siyuan-share/userdata"
+)
+
+// ImportShares Импорт публикаций из архива экспорта (multipart, поле file) в аккаунт текущего
+// пользователя с сохранением исходных ID; dryRun=true - только отчёт о конфликтах
+func ImportShares(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, userdata.MaxArchiveSize)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"code": 1, "msg": "Archive is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Archive file is required (multipart field \"file\")"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to read archive: " + err.Error()})
+		return
+	}
+	defer f.Close()
+
+	items, err := userdata.ReadShares(f, fh.Size)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+	report, err := models.ImportShares(c.GetString("userID"), items, dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to import shares: " + err.Error()})
+		return
+	}
+	if !dryRun {
+		middleware.Audit(c, models.AuditEvent{
+			Action: models.AuditShareImport, TargetType: "user", TargetID: c.GetString("userID"),
+			Detail: fmt.Sprintf("%s: imported %d (remapped %d), skipped %d", fh.Filename, report.Imported, report.Remapped, report.Skipped),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": report})
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mihazzz123/siyuan-share/middleware"
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+// CreateInviteRequest Создание приглашения
+type CreateInviteRequest struct {
+	Note          string `json:"note" binding:"max=255"`
+	MaxUses       *int   `json:"maxUses"`       // По умолчанию 1, 0 - без ограничения
+	ExpiresInDays int    `json:"expiresInDays"` // 0 - бессрочно
+}
+
+// inviteJSON Приглашение в ответе API
+func inviteJSON(inv *models.Invite) gin.H {
+	return gin.H{
+		"id": inv.ID, "note": inv.Note, "maxUses": inv.MaxUses, "uses": inv.Uses,
+		"expiresAt": inv.ExpiresAt, "revoked": inv.RevokedAt != nil, "usable": inv.Usable(time.Now()),
+		"createdBy": inv.CreatedBy, "createdAt": inv.CreatedAt,
+	}
+}
+
+// CreateInvite Создание приглашения; код возвращается только в этом ответе
+func CreateInvite(c *gin.Context) {
+	var req CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	maxUses := 1
+	if req.MaxUses != nil {
+		maxUses = *req.MaxUses
+	}
+	if maxUses < 0 || req.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "maxUses and expiresInDays must not be negative"})
+		return
+	}
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+	inv, code, err := models.CreateInvite(c.GetString("userID"), req.Note, maxUses, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to create invite: " + err.Error()})
+		return
+	}
+	middleware.Audit(c, models.AuditEvent{
+		Action: models.AuditInviteCreate, TargetType: "invite", TargetID: inv.ID, Detail: fmt.Sprintf("max uses: %d", maxUses),
+	})
+	data := inviteJSON(inv)
+	data["code"] = code
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": data})
+}
+
+// ListInvites Список приглашений
+func ListInvites(c *gin.Context) {
+	list, err := models.ListInvites()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to list invites: " + err.Error()})
+		return
+	}
+	items := make([]gin.H, 0, len(list))
+	for i := range list {
+		items = append(items, inviteJSON(&list[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"items": items, "registration": models.Registration}})
+}
+
+// RevokeInvite Отзыв приглашения
+func RevokeInvite(c *gin.Context) {
+	ok, err := models.RevokeInvite(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to revoke invite: " + err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Invite not found or already revoked"})
+		return
+	}
+	middleware.Audit(c, models.AuditEvent{Action: models.AuditInviteRevoke, TargetType: "invite", TargetID: c.Param("id")})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success"})
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mihazzz123/siyuan-share/keys"
+	"github.com/mihazzz123/siyuan-share/models"
+	"github.com/mihazzz123/siyuan-share/ratelimit"
+)
+
+// setupTestEnv Чистая база, ключи подписи и ограничитель попыток в памяти для теста контроллеров
+func setupTestEnv(t *testing.T) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	t.Setenv("DATA_DIR", dir)
+	t.Setenv("SQLITE_LOG_MODE", "silent")
+	if err := models.InitDB(); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if db, err := models.DB.DB(); err == nil {
+			db.Close()
+		}
+	})
+	if err := keys.Init(keys.Config{DataDir: dir}); err != nil {
+		t.Fatalf("keys.Init: %v", err)
+	}
+	ratelimit.Default = ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.DefaultPolicy())
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/mihazzz123/siyuan-share/keys"
+	"github.com/mihazzz123/siyuan-share/models"
+	"github.com/mihazzz123/siyuan-share/oidc"
+)
+
+// oidcStateCookie Cookie с подписанным состоянием входа через OIDC (state, nonce, PKCE verifier)
+const oidcStateCookie = "oidc_state"
+
+// oidcStateAudience Аудитория токена состояния входа через OIDC
+const oidcStateAudience = "oidc-state"
+
+// oidcStateTTL Время на вход у провайдера
+const oidcStateTTL = 10 * time.Minute
+
+// AuthMethods Доступные способы входа и режим регистрации (для страницы входа)
+func AuthMethods(c *gin.Context) {
+	var users int64
+	models.DB.Unscoped().Model(&models.User{}).Count(&users)
+	data := gin.H{
+		"password":     oidc.PasswordLoginAllowed(),
+		"oidc":         oidc.Enabled(),
+		"registration": models.Registration.Mode,
+		// Первый пользователь ещё не создан: при закрытой регистрации нужен токен инициализации
+		"setupRequired": users == 0 && models.Registration.Mode != models.RegistrationOpen,
+	}
+	if oidc.Enabled() {
+		data["oidcName"] = oidc.Default.Config().Name
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": data})
+}
+
+// OIDCLogin Переход к провайдеру OIDC; state, nonce и PKCE verifier сохраняются в подписанной cookie
+func OIDCLogin(c *gin.Context) {
+	if !oidc.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "OIDC login is not configured"})
+		return
+	}
+	state, nonce, verifier := oidc.RandomString(), oidc.RandomString(), oidc.RandomString()
+	authURL, err := oidc.Default.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("OIDC login failed: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"code": 1, "msg": "Identity provider is unavailable"})
+		return
+	}
+	now := time.Now()
+	signed, err := keys.Default.Sign(jwt.MapClaims{
+		"aud":      oidcStateAudience,
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+		"exp":      now.Add(oidcStateTTL).Unix(),
+		"iat":      now.Unix(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to sign token"})
+		return
+	}
+	setOIDCStateCookie(c, signed, int(oidcStateTTL.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback Возврат от провайдера: проверка state, обмен кода, поиск/создание пользователя и создание сессии.
+// Результат передаётся Web-интерфейсу во фрагменте URL (не попадает в логи и заголовок Referer):
+// #token=...&refreshToken=... , #challenge=... (включена 2FA) или #oidcError=...
+func OIDCCallback(c *gin.Context) {
+	if !oidc.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "OIDC login is not configured"})
+		return
+	}
+	raw, _ := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+
+	if e := c.Query("error"); e != "" {
+		redirectToApp(c, url.Values{"oidcError": {"Identity provider returned: " + e}})
+		return
+	}
+	claims := jwt.MapClaims{}
+	tok, err := keys.Default.Parse(raw, claims, jwt.WithAudience(oidcStateAudience), jwt.WithExpirationRequired())
+	if err != nil || !tok.Valid || c.Query("state") == "" || claims["state"] != c.Query("state") {
+		redirectToApp(c, url.Values{"oidcError": {"Login session expired, please try again"}})
+		return
+	}
+	nonce, _ := claims["nonce"].(string)
+	verifier, _ := claims["verifier"].(string)
+
+	id, err := oidc.Default.Exchange(c.Request.Context(), c.Query("code"), verifier, nonce)
+	if err != nil {
+		log.Printf("OIDC callback failed: %v", err)
+		redirectToApp(c, url.Values{"oidcError": {"Identity provider login failed"}})
+		return
+	}
+	username := id.PreferredUsername
+	if username == "" {
+		username = id.Name
+	}
+	user, err := models.LoginExternalIdentity(models.Registration, models.ExternalIdentity{
+		Issuer:        id.Issuer,
+		Subject:       id.Subject,
+		Email:         id.Email,
+		EmailVerified: id.EmailVerified,
+		Username:      username,
+	}, oidc.Default.Config().AutoProvision)
+	if err != nil {
+		msg := "Failed to sign in"
+		switch {
+		case errors.Is(err, models.ErrExternalEmailNotVerified):
+			msg = "Email is not verified by the identity provider"
+		case errors.Is(err, models.ErrExternalAccountNotFound), errors.Is(err, models.ErrRegistrationClosed),
+			errors.Is(err, models.ErrInviteRequired):
+			msg = "No account with this email, ask the administrator to create one"
+		case errors.Is(err, models.ErrEmailDomainNotAllowed):
+			msg = "Registration is not allowed for this email domain"
+		case errors.Is(err, models.ErrBootstrapTokenInvalid):
+			msg = "The first account must be registered with the bootstrap token"
+		default:
+			log.Printf("OIDC user lookup failed: %v", err)
+		}
+		redirectToApp(c, url.Values{"oidcError": {msg}})
+		return
+	}
+	if !user.IsActive {
+		auditLoginFailed(c, user.ID, user.Username, "User is disabled (oidc)")
+		redirectToApp(c, url.Values{"oidcError": {"User is disabled"}})
+		return
+	}
+
+	// Включённая 2FA требуется и при входе через провайдера
+	if user.TOTPEnabled {
+		challenge, err := issueLoginChallenge(user.ID)
+		if err != nil {
+			redirectToApp(c, url.Values{"oidcError": {"Failed to sign token"}})
+			return
+		}
+		redirectToApp(c, url.Values{"challenge": {challenge}})
+		return
+	}
+	session, refresh, err := models.CreateSession(user.ID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		redirectToApp(c, url.Values{"oidcError": {"Failed to create session"}})
+		return
+	}
+	access, _, err := signAccessToken(session.UserID, session.ID)
+	if err != nil {
+		redirectToApp(c, url.Values{"oidcError": {"Failed to sign token"}})
+		return
+	}
+	auditLogin(c, user, session, "oidc")
+	redirectToApp(c, url.Values{"token": {access}, "refreshToken": {refresh}})
+}
+
+// setOIDCStateCookie Установка (или удаление при maxAge < 0) cookie состояния входа
+func setOIDCStateCookie(c *gin.Context, value string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, value, maxAge, "/api/auth/oidc", "", secure, true)
+}
+
+// redirectToApp Возврат в Web-интерфейс с параметрами во фрагменте URL
+func redirectToApp(c *gin.Context, params url.Values) {
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, "/#"+params.Encode())
+}
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/mihazzz123/siyuan-share/models"
+	"github.com/mihazzz123/siyuan-share/oidc"
+	"github.com/mihazzz123/siyuan-share/oidc/oidctest"
+)
+
+// oidcTestServer Маршруты входа через OIDC с тестовым провайдером
+func oidcTestServer(t *testing.T, policy models.RegistrationPolicy, autoProvision bool) (*gin.Engine, *oidctest.Issuer) {
+	t.Helper()
+	setupTestEnv(t)
+
+	iss := oidctest.NewIssuer("siyuan-share")
+	t.Cleanup(iss.Close)
+	oidc.Init(oidc.Config{
+		Issuer:        iss.URL,
+		ClientID:      "siyuan-share",
+		RedirectURL:   "http://app.test/api/auth/oidc/callback",
+		AutoProvision: autoProvision,
+	})
+	prevPolicy := models.Registration
+	models.Registration = policy
+	t.Cleanup(func() {
+		oidc.Default = nil
+		models.Registration = prevPolicy
+	})
+
+	r := gin.New()
+	r.GET("/api/auth/oidc/login", OIDCLogin)
+	r.GET("/api/auth/oidc/callback", OIDCCallback)
+	return r, iss
+}
+
+// oidcSignIn Полный вход: переход к провайдеру, выдача кода и возврат на callback.
+// Результат - параметры из фрагмента адреса перенаправления в Web-интерфейс
+func oidcSignIn(t *testing.T, r *gin.Engine, iss *oidctest.Issuer) url.Values {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login: HTTP %d %s", w.Code, w.Body.String())
+	}
+	cookies := w.Result().Cookies()
+
+	code, state, err := iss.Authorize(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?"+url.Values{"code": {code}, "state": {state}}.Encode(), nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	loc := w.Header().Get("Location")
+	if w.Code != http.StatusFound || !strings.HasPrefix(loc, "/#") {
+		t.Fatalf("callback: HTTP %d, Location %q", w.Code, loc)
+	}
+	params, err := url.ParseQuery(strings.TrimPrefix(loc, "/#"))
+	if err != nil {
+		t.Fatalf("callback fragment: %v", err)
+	}
+	return params
+}
+
+// identityCount Число связей с внешними учётными записями
+func identityCount(t *testing.T) int64 {
+	t.Helper()
+	var n int64
+	if err := models.DB.Model(&models.UserIdentity{}).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestOIDCLinksAccountByVerifiedEmail(t *testing.T) {
+	r, iss := oidcTestServer(t, models.RegistrationPolicy{Mode: models.RegistrationClosed}, false)
+	user := models.User{ID: models.NewUserID(), Username: "alice", Email: "Alice@Example.com", IsActive: true}
+	if err := models.CreateUser(&user); err != nil {
+		t.Fatal(err)
+	}
+	iss.SetUser(oidctest.User{Subject: "alice-sub", Email: "alice@example.com", EmailVerified: true})
+
+	params := oidcSignIn(t, r, iss)
+	if params.Get("token") == "" || params.Get("oidcError") != "" {
+		t.Fatalf("sign-in failed: %v", params)
+	}
+	var link models.UserIdentity
+	if err := models.DB.Where("issuer = ? AND subject = ?", iss.URL, "alice-sub").First(&link).Error; err != nil {
+		t.Fatalf("identity not linked: %v", err)
+	}
+	if link.UserID != user.ID {
+		t.Fatalf("identity linked to %s, want %s", link.UserID, user.ID)
+	}
+	var linked models.User
+	models.DB.Where("id = ?", user.ID).First(&linked)
+	if linked.EmailVerifiedAt == nil {
+		t.Fatal("email not marked verified after provider confirmation")
+	}
+
+	// Повторный вход находит связку по issuer+subject, даже если email у провайдера изменился
+	iss.SetUser(oidctest.User{Subject: "alice-sub", Email: "alice@elsewhere.test", EmailVerified: false})
+	if params := oidcSignIn(t, r, iss); params.Get("token") == "" {
+		t.Fatalf("repeat sign-in failed: %v", params)
+	}
+	if n := identityCount(t); n != 1 {
+		t.Fatalf("identities = %d, want 1", n)
+	}
+}
+
+func TestOIDCRejectsUnverifiedEmail(t *testing.T) {
+	r, iss := oidcTestServer(t, models.RegistrationPolicy{Mode: models.RegistrationOpen}, true)
+	user := models.User{ID: models.NewUserID(), Username: "alice", Email: "alice@example.com", IsActive: true}
+	if err := models.CreateUser(&user); err != nil {
+		t.Fatal(err)
+	}
+	iss.SetUser(oidctest.User{Subject: "attacker", Email: "alice@example.com", EmailVerified: false})
+
+	params := oidcSignIn(t, r, iss)
+	if params.Get("token") != "" || params.Get("oidcError") == "" {
+		t.Fatalf("unverified email signed in: %v", params)
+	}
+	if n := identityCount(t); n != 0 {
+		t.Fatalf("identities = %d, want 0", n)
+	}
+}
+
+func TestOIDCRejectsInvalidIDToken(t *testing.T) {
+	tests := map[string]func(jwt.MapClaims){
+		"nonce":    func(c jwt.MapClaims) { c["nonce"] = "other" },
+		"audience": func(c jwt.MapClaims) { c["aud"] = "other-client" },
+		"issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"expired":  func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-2 * time.Minute).Unix() },
+	}
+	for name, tamper := range tests {
+		t.Run(name, func(t *testing.T) {
+			r, iss := oidcTestServer(t, models.RegistrationPolicy{Mode: models.RegistrationOpen}, true)
+			iss.SetTamper(tamper)
+
+			params := oidcSignIn(t, r, iss)
+			if params.Get("token") != "" || params.Get("oidcError") != "Identity provider login failed" {
+				t.Fatalf("invalid id_token accepted: %v", params)
+			}
+			var users int64
+			models.DB.Model(&models.User{}).Count(&users)
+			if users != 0 {
+				t.Fatalf("users = %d, want 0", users)
+			}
+		})
+	}
+}
+
+func TestOIDCAutoProvisionFollowsRegistrationPolicy(t *testing.T) {
+	existing := func(t *testing.T) {
+		t.Helper()
+		u := models.User{ID: models.NewUserID(), Username: "admin", Email: "admin@example.com", IsActive: true}
+		if err := models.CreateUser(&u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name      string
+		policy    models.RegistrationPolicy
+		firstUser bool // Пользователей ещё нет
+		email     string
+		created   bool
+	}{
+		{name: "open", policy: models.RegistrationPolicy{Mode: models.RegistrationOpen}, email: "bob@example.com", created: true},
+		{name: "closed", policy: models.RegistrationPolicy{Mode: models.RegistrationClosed}, email: "bob@example.com"},
+		{name: "invite", policy: models.RegistrationPolicy{Mode: models.RegistrationInvite}, email: "bob@example.com"},
+		{name: "domain allowed", policy: models.RegistrationPolicy{Mode: models.RegistrationDomain, Domains: []string{"example.com"}}, email: "bob@example.com", created: true},
+		{name: "domain rejected", policy: models.RegistrationPolicy{Mode: models.RegistrationDomain, Domains: []string{"example.com"}}, email: "bob@other.test"},
+		{name: "first user without bootstrap token", policy: models.RegistrationPolicy{Mode: models.RegistrationClosed}, firstUser: true, email: "bob@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, iss := oidcTestServer(t, tt.policy, true)
+			if !tt.firstUser {
+				existing(t)
+			}
+			iss.SetUser(oidctest.User{Subject: "bob-sub", Email: tt.email, EmailVerified: true, PreferredUsername: "bob"})
+
+			params := oidcSignIn(t, r, iss)
+			var bob int64
+			models.DB.Model(&models.User{}).Where("email = ?", tt.email).Count(&bob)
+			if tt.created != (bob == 1) || tt.created != (params.Get("token") != "") {
+				t.Fatalf("created = %v, users with %s = %d, redirect %v", tt.created, tt.email, bob, params)
+			}
+		})
+	}
+}
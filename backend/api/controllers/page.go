@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mihazzz123/siyuan-share/kramdown"
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+const (
+	siteName             = "SiYuan Share"
+	shareDescriptionSize = 200
+)
+
+var (
+	titleTagPattern = regexp.MustCompile(`(?is)<title>.*?</title>`)
+
+	shareHeadTemplate = template.Must(template.New("head").Parse(`<title>{{.Title}}</title>
+    <meta name="description" content="{{.Description}}" />
+    <meta name="robots" content="{{if .NoIndex}}noindex, nofollow{{else}}index, follow{{end}}" />
+{{if .URL}}    <link rel="canonical" href="{{.URL}}" />
+{{end}}    <meta property="og:site_name" content="{{.SiteName}}" />
+    <meta property="og:type" content="article" />
+    <meta property="og:title" content="{{.Title}}" />
+    <meta property="og:description" content="{{.Description}}" />
+{{if .URL}}
+    <meta property="og:url" content="{{.URL}}" />{{end}}{{if not .ModifiedAt.IsZero}}
+    <meta property="article:modified_time" content="{{.ModifiedAt.Format "2006-01-02T15:04:05Z07:00"}}" />{{end}}
+    <meta name="twitter:card" content="summary" />
+    <meta name="twitter:title" content="{{.Title}}" />
+    <meta name="twitter:description" content="{{.Description}}" />
+`))
+
+	shareNoscriptTemplate = template.Must(template.New("noscript").Parse(`<noscript><article class="markdown-body"><h1>{{.Title}}</h1>{{.Body}}</article></noscript>`))
+)
+
+// sharePageMeta Метаданные страницы публикации
+type sharePageMeta struct {
+	SiteName    string
+	Title       string
+	Description string
+	URL         string // Пусто - canonical и og:url не выводятся
+	NoIndex     bool
+	ModifiedAt  time.Time
+	Body        template.HTML
+}
+
+// ServeSharePage Отдача index.html SPA для /s/:id с метаданными публикации для превью ссылок
+// и поисковиков: title, description, og:*, twitter:*, canonical и robots. Адрес в canonical и og:url
+// берётся из PUBLIC_URL (вне режима release - из запроса), а не из заголовков в release.
+// Приватные и защищённые паролем публикации помечаются noindex; для защищённых паролем
+// заголовок и содержимое не раскрываются. Просмотр при этом не засчитывается - это делает SPA.
+func ServeSharePage(c *gin.Context, shareID string, index []byte) {
+	meta := sharePageMeta{
+		SiteName: siteName,
+		Title:    siteName,
+		URL:      canonicalShareURL(c, shareID),
+		NoIndex:  true,
+	}
+	status := http.StatusOK
+
+	var share models.Share
+	switch {
+	case models.DB.Where("id = ?", shareID).First(&share).Error != nil:
+		status = http.StatusNotFound
+		meta.Description = "Публикация не найдена"
+	case share.IsExpired():
+		status = http.StatusGone
+		meta.Description = "Срок действия публикации истёк"
+	case share.RequirePassword:
+		meta.Title = "Защищённая публикация · " + siteName
+		meta.Description = "Для просмотра публикации требуется пароль"
+	default:
+		meta.Title = share.DocTitle
+		meta.NoIndex = !share.IsPublic
+		meta.ModifiedAt = share.UpdatedAt
+		if html, err := renderShareHTML(c, &share); err == nil {
+			meta.Description = kramdown.Excerpt(html, shareDescriptionSize)
+			meta.Body = template.HTML(html)
+		}
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Data(status, "text/html; charset=utf-8", injectSharePageMeta(index, meta))
+}
+
+// injectSharePageMeta Замена <title> в index.html на метаданные публикации и
+// добавление отрендеренного содержимого в <noscript> для клиентов без JavaScript
+func injectSharePageMeta(index []byte, meta sharePageMeta) []byte {
+	var head bytes.Buffer
+	if err := shareHeadTemplate.Execute(&head, meta); err != nil {
+		return index
+	}
+
+	page := titleTagPattern.ReplaceAllLiteral(index, nil)
+	page = bytes.Replace(page, []byte("</head>"), append(head.Bytes(), []byte("</head>")...), 1)
+
+	if meta.Body != "" {
+		var body bytes.Buffer
+		if err := shareNoscriptTemplate.Execute(&body, meta); err == nil {
+			page = bytes.Replace(page, []byte(`<div id="root"></div>`), append([]byte(`<div id="root"></div>`), body.Bytes()...), 1)
+		}
+	}
+	return page
+}
@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondTooManyAttempts Ответ 429 с заголовком Retry-After (в секундах)
+func respondTooManyAttempts(c *gin.Context, wait time.Duration) {
+	secs := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(secs))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"code":       1,
+		"msg":        "Too many failed attempts, try again later",
+		"retryAfter": secs,
+	})
+}
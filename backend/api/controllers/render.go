@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/mihazzz123/siyuan-share/kramdown"
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+// renderCache LRU-кэш отрендеренного HTML публикаций
+type renderCache struct {
+	mu    sync.Mutex
+	max   int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type renderCacheEntry struct {
+	key  string
+	html string
+}
+
+func newRenderCache(max int) *renderCache {
+	return &renderCache{max: max, ll: list.New(), items: map[string]*list.Element{}}
+}
+
+func (rc *renderCache) get(key string) (string, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if el, ok := rc.items[key]; ok {
+		rc.ll.MoveToFront(el)
+		return el.Value.(*renderCacheEntry).html, true
+	}
+	return "", false
+}
+
+func (rc *renderCache) put(key, html string) {
+	if rc.max <= 0 {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if el, ok := rc.items[key]; ok {
+		el.Value.(*renderCacheEntry).html = html
+		rc.ll.MoveToFront(el)
+		return
+	}
+	rc.items[key] = rc.ll.PushFront(&renderCacheEntry{key: key, html: html})
+	for rc.ll.Len() > rc.max {
+		last := rc.ll.Back()
+		rc.ll.Remove(last)
+		delete(rc.items, last.Value.(*renderCacheEntry).key)
+	}
+}
+
+// shareHTMLCache Кэш HTML по ключу публикация+хэш содержимого (RENDER_CACHE_SIZE, по умолчанию 256 записей)
+var shareHTMLCache = newRenderCache(renderCacheSize())
+
+func renderCacheSize() int {
+	if v, err := strconv.Atoi(os.Getenv("RENDER_CACHE_SIZE")); err == nil && v >= 0 {
+		return v
+	}
+	return 256
+}
+
+// wantsHTML Запрошен ли HTML вместо JSON: параметр format=html или Accept с приоритетом text/html
+func wantsHTML(c *gin.Context) bool {
+	switch strings.ToLower(c.Query("format")) {
+	case "html":
+		return true
+	case "json":
+		return false
+	}
+	if c.GetHeader("Accept") == "" {
+		return false
+	}
+	return c.NegotiateFormat(binding.MIMEJSON, binding.MIMEHTML) == binding.MIMEHTML
+}
+
+// siteBaseURL Адрес сервиса для ссылок в кэшируемом HTML: PUBLIC_URL, вне режима release -
+// адрес из запроса; в release без PUBLIC_URL - пусто (относительные ссылки). Заголовки запроса
+// в release не используются: иначе клиент мог бы подставить свой хост в кэш
+func siteBaseURL(c *gin.Context) string {
+	baseURL, _ := publicBaseURL(c)
+	return baseURL
+}
+
+// canonicalShareURL Канонический адрес публикации для canonical и og:url; пусто, если адрес
+// сервиса не задан (release без PUBLIC_URL) - тогда теги не выводятся
+func canonicalShareURL(c *gin.Context, shareID string) string {
+	if baseURL := siteBaseURL(c); baseURL != "" {
+		return baseURL + "/s/" + shareID
+	}
+	return ""
+}
+
+// renderShareHTML Очищенный HTML публикации. Ключ кэша - хэш содержимого с подставленными
+// ссылками на блоки: смена ссылаемых блоков или удаление их публикаций без новой ревизии
+// тоже даёт новый ключ
+func renderShareHTML(c *gin.Context, share *models.Share) (string, error) {
+	content := resolveShareContent(share, siteBaseURL(c))
+	sum := sha256.Sum256([]byte(content))
+	key := share.ID + ":" + hex.EncodeToString(sum[:16])
+	if html, ok := shareHTMLCache.get(key); ok {
+		return html, nil
+	}
+	html, err := kramdown.Render(content)
+	if err != nil {
+		return "", err
+	}
+	shareHTMLCache.put(key, html)
+	return html, nil
+}
+
+var shareDocumentTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+{{if .URL}}<link rel="canonical" href="{{.URL}}">
+{{end}}</head>
+<body>
+<article class="markdown-body" data-share-id="{{.ID}}" data-revision="{{.Revision}}">
+<h1>{{.Title}}</h1>
+{{.Body}}
+</article>
+</body>
+</html>
+`))
+
+// writeShareHTMLDocument Отправка отдельного HTML-документа публикации
+func writeShareHTMLDocument(c *gin.Context, share *models.Share, html string) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	_ = shareDocumentTemplate.Execute(c.Writer, gin.H{
+		"ID":       share.ID,
+		"Title":    share.DocTitle,
+		"Revision": share.Revision,
+		"URL":      canonicalShareURL(c, share.ID),
+		"Body":     template.HTML(html),
+	})
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+// findOwnedShare Поиск публикации текущего пользователя по параметру :id, при ошибке ответ уже отправлен
+func findOwnedShare(c *gin.Context) (*models.Share, bool) {
+	var share models.Share
+	if err := models.DB.Where("id = ? AND user_id = ?", c.Param("id"), c.GetString("userID")).First(&share).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Share not found or unauthorized"})
+		return nil, false
+	}
+	return &share, true
+}
+
+// findRevisionParam Поиск ревизии публикации по параметру :rev, при ошибке ответ уже отправлен
+func findRevisionParam(c *gin.Context, shareID string) (*models.ShareRevision, bool) {
+	version, err := strconv.Atoi(c.Param("rev"))
+	if err != nil || version <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid revision"})
+		return nil, false
+	}
+	rev, err := models.FindShareRevision(shareID, version)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to load revision: " + err.Error()})
+		return nil, false
+	}
+	if rev == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Revision not found"})
+		return nil, false
+	}
+	return rev, true
+}
+
+// ListShareRevisions Список ревизий публикации
+func ListShareRevisions(c *gin.Context) {
+	share, ok := findOwnedShare(c)
+	if !ok {
+		return
+	}
+
+	revs, err := models.ListShareRevisions(share.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to list revisions: " + err.Error()})
+		return
+	}
+
+	items := make([]gin.H, 0, len(revs))
+	for _, r := range revs {
+		items = append(items, gin.H{
+			"version":      r.Version,
+			"docTitle":     r.DocTitle,
+			"restoredFrom": r.RestoredFrom,
+			"createdAt":    r.CreatedAt,
+			"current":      r.Version == share.Revision,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"shareId":  share.ID,
+		"revision": share.Revision,
+		"items":    items,
+	}})
+}
+
+// GetShareRevision Получение содержимого ревизии публикации
+func GetShareRevision(c *gin.Context) {
+	share, ok := findOwnedShare(c)
+	if !ok {
+		return
+	}
+	rev, ok := findRevisionParam(c, share.ID)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": rev})
+}
+
+// RestoreShareRevision Восстановление ревизии: её содержимое публикуется как новая ревизия
+// Дочерние публикации ссылаемых блоков не затрагиваются и остаются в последнем состоянии
+func RestoreShareRevision(c *gin.Context) {
+	share, ok := findOwnedShare(c)
+	if !ok {
+		return
+	}
+	rev, ok := findRevisionParam(c, share.ID)
+	if !ok {
+		return
+	}
+
+	if err := models.RestoreShareRevision(share, rev); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to restore revision: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"shareId":      share.ID,
+		"revision":     share.Revision,
+		"restoredFrom": rev.Version,
+	}})
+}
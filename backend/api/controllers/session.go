@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/mihazzz123/siyuan-share/keys"
+	"github.com/mihazzz123/siyuan-share/middleware"
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+// RefreshRequest Запрос обновления сессии
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// signAccessToken Подпись access JWT сессии текущим ключом (claim sid - ID сессии)
+func signAccessToken(userID, sessionID string) (string, time.Time, error) {
+	now := time.Now()
+	expires := now.Add(models.SessionAccessTTL())
+	claims := jwt.MapClaims{
+		"sub": userID,
+		"sid": sessionID,
+		"exp": expires.Unix(),
+		"iat": now.Unix(),
+	}
+	s, err := keys.Default.Sign(claims)
+	return s, expires, err
+}
+
+// sessionTokens Ответ с парой токенов сессии
+func sessionTokens(session *models.Session, refresh string) (gin.H, error) {
+	access, expires, err := signAccessToken(session.UserID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"token":            access,
+		"expiresAt":        expires,
+		"refreshToken":     refresh,
+		"refreshExpiresAt": session.ExpiresAt,
+		"sessionId":        session.ID,
+	}, nil
+}
+
+// RefreshSession Обмен refresh токена на новую пару токенов
+func RefreshSession(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	session, refresh, err := models.RefreshSession(req.RefreshToken)
+	if err != nil {
+		msg := "Invalid refresh token"
+		switch {
+		case errors.Is(err, models.ErrSessionExpired):
+			msg = "Session expired"
+		case errors.Is(err, models.ErrRefreshTokenReused):
+			msg = "Refresh token already used, session revoked"
+			middleware.Audit(c, models.AuditEvent{
+				UserID: session.UserID, Via: models.AuditViaSession, Credential: session.ID, Action: models.AuditRefreshReuse,
+				TargetType: "session", TargetID: session.ID, Result: models.AuditDenied,
+			})
+		case errors.Is(err, models.ErrSessionUserInactive):
+			msg = "User inactive or not found"
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": msg})
+		return
+	}
+	data, err := sessionTokens(session, refresh)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to sign token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": data})
+}
+
+// Logout Выход: отзыв текущей сессии
+func Logout(c *gin.Context) {
+	sessionID := c.GetString("sessionID")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Not a session token"})
+		return
+	}
+	if err := models.RevokeSession(c.GetString("userID"), sessionID); err != nil && !errors.Is(err, models.ErrSessionNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to revoke session: " + err.Error()})
+		return
+	}
+	middleware.Audit(c, models.AuditEvent{Action: models.AuditLogout, TargetType: "session", TargetID: sessionID})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success"})
+}
+
+// LogoutAll Выход на всех устройствах: отзыв всех сессий пользователя (только из сессии Web)
+func LogoutAll(c *gin.Context) {
+	revoked, err := models.RevokeUserSessions(c.GetString("userID"), "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to revoke sessions: " + err.Error()})
+		return
+	}
+	middleware.Audit(c, models.AuditEvent{Action: models.AuditLogoutAll, TargetType: "user", TargetID: c.GetString("userID"), Detail: fmt.Sprintf("revoked sessions: %d", revoked)})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"revoked": revoked}})
+}
+
+// ListSessions Действующие сессии текущего пользователя (только из сессии Web)
+func ListSessions(c *gin.Context) {
+	sessions, err := models.ListSessions(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to list sessions: " + err.Error()})
+		return
+	}
+	current := c.GetString("sessionID")
+	list := make([]gin.H, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, gin.H{
+			"id": s.ID, "userAgent": s.UserAgent, "ip": s.IP, "createdAt": s.CreatedAt,
+			"lastSeenAt": s.LastSeenAt, "expiresAt": s.ExpiresAt, "current": s.ID == current,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"items": list}})
+}
+
+// RevokeSession Отзыв одной сессии текущего пользователя (только из сессии Web)
+func RevokeSession(c *gin.Context) {
+	err := models.RevokeSession(c.GetString("userID"), c.Param("id"))
+	if errors.Is(err, models.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Session not found or already revoked"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to revoke session: " + err.Error()})
+		return
+	}
+	middleware.Audit(c, models.AuditEvent{Action: models.AuditSessionRevoke, TargetType: "session", TargetID: c.Param("id")})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success"})
+}
+
+// JWKS Открытые ключи подписи (EdDSA/RS256) для проверки токенов сторонними сервисами
+func JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"keys": keys.Default.PublicKeys()})
+}
@@ -1,16 +1,15 @@
 package controllers
 
 import (
-	"crypto/rand"
-	"encoding/hex"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/mihazzz123/siyuan-share/middleware"
 	"github.com/mihazzz123/siyuan-share/models"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -48,6 +47,7 @@ type CreateShareResponse struct {
 	CreatedAt       time.Time `json:"createdAt"`
 	UpdatedAt       time.Time `json:"updatedAt"`
 	Reused          bool      `json:"reused"`
+	Revision        int       `json:"revision"`
 }
 
 // BatchDeleteShareRequest Запрос на массовое удаление публикаций
@@ -78,109 +78,71 @@ func CreateShare(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	userIDStr := userID.(string)
 
-	existingShare, err := models.FindActiveShareByDoc(userIDStr, req.DocID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	password := strings.TrimSpace(req.Password)
+
+	if req.RequirePassword && password != "" && len(password) < 4 {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"code": 1,
-			"msg":  "Failed to query share: " + err.Error(),
+			"msg":  "Password must be at least 4 characters",
 		})
 		return
 	}
 
-	// Если публикация существует, но истекла, она считается недействительной
-	if existingShare != nil && existingShare.IsExpired() {
-		existingShare = nil
-	}
-
-	password := strings.TrimSpace(req.Password)
-
-	if req.RequirePassword {
-		if password != "" && len(password) < 4 {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"code": 1,
-				"msg":  "Password must be at least 4 characters",
-			})
-			return
-		}
-		if password == "" {
-			if existingShare == nil || existingShare.PasswordHash == "" {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"code": 1,
-					"msg":  "Password must be provided for new share",
-				})
-				return
-			}
-		}
-	}
-
-	var share *models.Share
-	reused := false
-	if existingShare != nil {
-		share = existingShare
-		reused = true
-	} else {
-		share = &models.Share{
-			ID:     generateShareID(),
-			UserID: userIDStr,
-			DocID:  req.DocID,
-		}
-	}
-
-	share.DocTitle = req.DocTitle
-	share.Content = req.Content
-	share.RequirePassword = req.RequirePassword
-	share.IsPublic = req.IsPublic
-	share.ExpireAt = time.Now().AddDate(0, 0, req.ExpireDays)
-
-	// Обработка данных ссылаемых блоков
-	if len(req.References) > 0 {
-		refsJSON, err := json.Marshal(req.References)
+	// Хеш вычисляется до транзакции; пустой хеш - сохранить пароль существующей публикации
+	passwordHash := ""
+	if req.RequirePassword && password != "" {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"code": 1,
-				"msg":  "Failed to serialize references: " + err.Error(),
+				"msg":  "Failed to encrypt password",
 			})
 			return
 		}
-		share.References = string(refsJSON)
-	} else {
-		share.References = ""
+		passwordHash = string(hashedPassword)
 	}
 
-	if req.RequirePassword {
-		if password != "" {
-			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"code": 1,
-					"msg":  "Failed to encrypt password",
-				})
-				return
-			}
-			share.PasswordHash = string(hashedPassword)
-		}
-		// Если пусто, используется старый пароль (проверка гарантирует возможность повторного использования)
-	} else {
-		share.PasswordHash = ""
+	refs := make([]models.PublishReference, 0, len(req.References))
+	for _, ref := range req.References {
+		refs = append(refs, models.PublishReference{
+			BlockReference: models.BlockReference{
+				BlockID:     ref.BlockID,
+				Content:     ref.Content,
+				DisplayText: ref.DisplayText,
+				RefCount:    ref.RefCount,
+			},
+			Title: generateBlockTitle(ref),
+		})
 	}
 
-	if reused {
-		if err := models.DB.Save(share).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"code": 1,
-				"msg":  "Failed to update share: " + err.Error(),
-			})
-			return
-		}
-	} else {
-		if err := models.DB.Create(share).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"code": 1,
-				"msg":  "Failed to create share: " + err.Error(),
-			})
-			return
-		}
+	// Публикация документа, публикаций ссылаемых блоков и связей между ними в одной транзакции
+	result, err := models.PublishShare(models.PublishInput{
+		UserID:          userIDStr,
+		DocID:           req.DocID,
+		DocTitle:        req.DocTitle,
+		Content:         req.Content,
+		RequirePassword: req.RequirePassword,
+		PasswordHash:    passwordHash,
+		IsPublic:        req.IsPublic,
+		ExpireAt:        time.Now().AddDate(0, 0, req.ExpireDays),
+		References:      refs,
+	})
+	if errors.Is(err, models.ErrSharePasswordRequired) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 1,
+			"msg":  "Password must be provided for new share",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 1,
+			"msg":  "Failed to publish share: " + err.Error(),
+		})
+		return
 	}
+	share := result.Share
+	reused := result.Reused
 
 	// Построение URL публикации (автоматически или через X-Base-URL)
 	baseURL := c.GetHeader("X-Base-URL")
@@ -203,44 +165,6 @@ func CreateShare(c *gin.Context) {
 	}
 	shareURL := strings.TrimSuffix(baseURL, "/") + "/s/" + share.ID
 
-	// Создание дочерних публикаций для ссылаемых блоков
-	if len(req.References) > 0 {
-		for _, ref := range req.References {
-			// Проверка существования публикации для этого блока (по docId = blockId)
-			existingBlockShare, _ := models.FindActiveShareByDoc(userIDStr, ref.BlockID)
-
-			// Генерация заголовка для ссылаемого блока
-			blockTitle := generateBlockTitle(ref)
-
-			var blockShare *models.Share
-			if existingBlockShare != nil && !existingBlockShare.IsExpired() {
-				// Обновление существующей публикации блока
-				blockShare = existingBlockShare
-				blockShare.DocTitle = blockTitle
-				blockShare.Content = ref.Content
-				blockShare.ExpireAt = share.ExpireAt
-				blockShare.ParentShareID = share.ID
-				models.DB.Save(blockShare)
-			} else {
-				// Создание новой публикации блока
-				blockShare = &models.Share{
-					ID:            generateShareID(),
-					UserID:        userIDStr,
-					DocID:         ref.BlockID, // Использование blockId в качестве docId
-					DocTitle:      blockTitle,
-					Content:       ref.Content,
-					ParentShareID: share.ID,
-					// Наследование пароля и срока действия от родительской публикации
-					RequirePassword: share.RequirePassword,
-					PasswordHash:    share.PasswordHash,
-					ExpireAt:        share.ExpireAt,
-					IsPublic:        share.IsPublic,
-				}
-				models.DB.Create(blockShare)
-			}
-		}
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
 		"msg":  "success",
@@ -255,6 +179,7 @@ func CreateShare(c *gin.Context) {
 			CreatedAt:       share.CreatedAt,
 			UpdatedAt:       share.UpdatedAt,
 			Reused:          reused,
+			Revision:        share.Revision,
 		},
 	})
 }
@@ -378,6 +303,7 @@ func DeleteShare(c *gin.Context) {
 		return
 	}
 
+	middleware.Audit(c, models.AuditEvent{Action: models.AuditShareDelete, TargetType: "share", TargetID: shareID})
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
 		"msg":  "success",
@@ -408,6 +334,9 @@ func DeleteSharesBatch(c *gin.Context) {
 			return
 		}
 
+		middleware.Audit(c, models.AuditEvent{
+			Action: models.AuditShareBatch, TargetType: "user", TargetID: userID, Detail: fmt.Sprintf("all shares, deleted: %d", count),
+		})
 		c.JSON(http.StatusOK, gin.H{
 			"code": 0,
 			"msg":  "success",
@@ -446,6 +375,18 @@ func DeleteSharesBatch(c *gin.Context) {
 		response.Failed = failed
 	}
 
+	event := models.AuditEvent{
+		Action: models.AuditShareBatch, TargetType: "share",
+		Detail: fmt.Sprintf("deleted: %d, not found: %d, failed: %d", len(response.Deleted), len(response.NotFound), len(failed)),
+	}
+	if len(response.Deleted) > 0 {
+		event.Detail += "; ids: " + strings.Join(response.Deleted, ",")
+	}
+	if len(failed) > 0 {
+		event.Result = models.AuditFailure
+	}
+	middleware.Audit(c, event)
+
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
 		"msg":  "success",
@@ -453,13 +394,6 @@ func DeleteSharesBatch(c *gin.Context) {
 	})
 }
 
-// generateShareID Генерация случайного ID публикации
-func generateShareID() string {
-	b := make([]byte, 16)
-	rand.Read(b)
-	return hex.EncodeToString(b)
-}
-
 // generateBlockTitle Генерация заголовка ссылаемого блока
 func generateBlockTitle(ref BlockReferenceReq) string {
 	// Приоритет отображаемому тексту
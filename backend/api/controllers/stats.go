@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+const (
+	statsDefaultDays = 30
+	statsMaxDays     = 365
+	statsTopSources  = 10 // Источников перехода в ответе
+)
+
+// GetShareStats Статистика просмотров публикации за последние days суток (UTC, включая текущие)
+func GetShareStats(c *gin.Context) {
+	share, ok := findOwnedShare(c)
+	if !ok {
+		return
+	}
+
+	days := statsDefaultDays
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > statsMaxDays {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid days: expected 1.." + strconv.Itoa(statsMaxDays)})
+			return
+		}
+		days = n
+	}
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -(days - 1))
+	stats, err := models.GetShareStats(share, from, to, statsTopSources)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to load stats: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": stats})
+}
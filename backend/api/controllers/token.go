@@ -1,35 +1,49 @@
 package controllers
 
 import (
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/mihazzz123/siyuan-share/middleware"
 	"github.com/mihazzz123/siyuan-share/models"
 	"github.com/gin-gonic/gin"
 )
 
 type CreateTokenRequest struct {
-	Name string `json:"name" binding:"required,min=1,max=100"`
+	Name   string   `json:"name" binding:"required,min=1,max=100"`
+	Scopes []string `json:"scopes"` // Области действия, по умолчанию models.DefaultTokenScopes
+
+	// Политика токена
+	ExpiresAt    *time.Time `json:"expiresAt"`                             // Срок действия (RFC 3339), пусто - бессрочный
+	AllowedCIDRs []string   `json:"allowedCidrs"`                          // Разрешённые диапазоны IP, пусто - любые
+	RotationDays int        `json:"rotationDays" binding:"min=0,max=3650"` // Период автоматической ротации в днях, 0 - без ротации
 }
 
-// ListTokens Список активных токенов текущего пользователя (без открытого текста)
+// ListTokens Список токенов текущего пользователя, включая отозванные и истёкшие (revoked, expired),
+// без открытого текста
 func ListTokens(c *gin.Context) {
-	userID := c.GetString("userID")
-	var tokens []models.UserToken
-	if err := models.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+	tokens, err := models.ListUserTokens(c.GetString("userID"))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to list tokens: " + err.Error()})
 		return
 	}
-	// Глубокое копирование с удалением чувствительных полей
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"items": tokenList(tokens)}})
+}
+
+// tokenList Токены в ответе API (без чувствительных полей)
+func tokenList(tokens []models.UserToken) []gin.H {
+	now := time.Now()
 	list := make([]gin.H, 0, len(tokens))
 	for _, t := range tokens {
 		list = append(list, gin.H{
-			"id": t.ID, "name": t.Name, "revoked": t.Revoked, "lastUsedAt": t.LastUsedAt, "createdAt": t.CreatedAt,
+			"id": t.ID, "name": t.Name, "scopes": t.ScopeList(), "revoked": t.Revoked, "lastUsedAt": t.LastUsedAt, "createdAt": t.CreatedAt,
+			"expiresAt": t.ExpiresAt, "expired": t.IsExpired(now), "allowedCidrs": t.CIDRList(),
+			"rotationDays": t.RotationDays, "rotatedAt": t.RotatedAt,
 		})
 	}
-	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"items": list}})
+	return list
 }
 
 // CreateToken Создание нового API токена (возвращается один раз в открытом виде)
@@ -40,66 +54,70 @@ func CreateToken(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
 		return
 	}
-	raw := randomToken(32)
-	hash := hashToken(raw)
-	ut := &models.UserToken{
-		ID:        "tok_" + randomToken(12),
-		UserID:    userID,
-		Name:      req.Name,
-		TokenHash: hash,
-	}
-	if err := models.DB.Create(ut).Error; err != nil {
+	// Токен не может выдать области действия, которых нет у него самого, а также
+	// действовать дольше него и из других диапазонов IP
+	ut, err := models.CreateUserToken(models.TokenInput{
+		UserID:       userID,
+		Name:         req.Name,
+		Scopes:       req.Scopes,
+		MaxScopes:    middleware.Scopes(c),
+		ExpiresAt:    req.ExpiresAt,
+		AllowedCIDRs: req.AllowedCIDRs,
+		RotationDays: req.RotationDays,
+		Parent:       middleware.Token(c),
+	})
+	var inputErr models.TokenInputError
+	switch {
+	case errors.As(err, &inputErr):
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": inputErr.Error()})
+		return
+	case errors.Is(err, models.ErrScopesNotGrantable):
+		middleware.Audit(c, models.AuditEvent{
+			Action: models.AuditTokenCreate, TargetType: "token", Result: models.AuditDenied,
+			Detail: "scopes not grantable: " + strings.Join(req.Scopes, " "),
+		})
+		c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Cannot grant scopes beyond those of the current token"})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to save token: " + err.Error()})
 		return
 	}
-	ut.PlainToken = raw
+	middleware.Audit(c, models.AuditEvent{
+		Action: models.AuditTokenCreate, TargetType: "token", TargetID: ut.ID,
+		Detail: ut.Name + "; scopes: " + strings.Join(ut.ScopeList(), " "),
+	})
 	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
-		"id": ut.ID, "name": ut.Name, "token": ut.PlainToken, "createdAt": ut.CreatedAt,
+		"id": ut.ID, "name": ut.Name, "token": ut.PlainToken, "scopes": ut.ScopeList(), "createdAt": ut.CreatedAt,
+		"expiresAt": ut.ExpiresAt, "allowedCidrs": ut.CIDRList(), "rotationDays": ut.RotationDays,
 	}})
 }
 
 // RefreshToken Обновление токена (новый текст, сохранение записи)
 func RefreshToken(c *gin.Context) {
-	userID := c.GetString("userID")
-	id := c.Param("id")
-	var ut models.UserToken
-	if err := models.DB.Where("id = ? AND user_id = ? AND revoked = ?", id, userID, false).First(&ut).Error; err != nil {
+	ut, err := models.ReissueUserToken(c.GetString("userID"), c.Param("id"))
+	if errors.Is(err, models.ErrTokenNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Token not found"})
 		return
 	}
-	raw := randomToken(32)
-	hash := hashToken(raw)
-	ut.TokenHash = hash
-	if err := models.DB.Save(&ut).Error; err != nil {
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to refresh token: " + err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"id": ut.ID, "name": ut.Name, "token": raw}})
+	middleware.Audit(c, models.AuditEvent{Action: models.AuditTokenRefresh, TargetType: "token", TargetID: ut.ID, Detail: ut.Name})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"id": ut.ID, "name": ut.Name, "token": ut.PlainToken}})
 }
 
 // RevokeToken Отзыв токена
 func RevokeToken(c *gin.Context) {
-	userID := c.GetString("userID")
-	id := c.Param("id")
-	result := models.DB.Model(&models.UserToken{}).Where("id = ? AND user_id = ? AND revoked = ?", id, userID, false).Update("revoked", true)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to revoke token: " + result.Error.Error()})
+	ok, err := models.RevokeUserToken(c.GetString("userID"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to revoke token: " + err.Error()})
 		return
 	}
-	if result.RowsAffected == 0 {
+	if !ok {
 		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Token not found or already revoked"})
 		return
 	}
+	middleware.Audit(c, models.AuditEvent{Action: models.AuditTokenRevoke, TargetType: "token", TargetID: c.Param("id")})
 	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success"})
 }
-
-func randomToken(n int) string {
-	b := make([]byte, n)
-	rand.Read(b)
-	return hex.EncodeToString(b)
-}
-
-func hashToken(raw string) string {
-	h := sha256.Sum256([]byte(raw))
-	return hex.EncodeToString(h[:])
-}
@@ -0,0 +1,314 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/mihazzz123/siyuan-share/keys"
+	"github.com/mihazzz123/siyuan-share/middleware"
+	"github.com/mihazzz123/siyuan-share/models"
+	"github.com/mihazzz123/siyuan-share/ratelimit"
+	"github.com/mihazzz123/siyuan-share/totp"
+)
+
+// loginChallengeAudience Аудитория токена незавершённого входа (пароль проверен, ждём второй фактор)
+const loginChallengeAudience = "login-2fa"
+
+// loginChallengeTTL Время на ввод кода второго фактора
+const loginChallengeTTL = 5 * time.Minute
+
+// LoginTwoFactorRequest Второй шаг входа: код TOTP или резервный код
+type LoginTwoFactorRequest struct {
+	Challenge    string `json:"challenge" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// TwoFactorCodeRequest Запрос с кодом TOTP
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest Отключение 2FA: пароль и код TOTP или резервный код
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// totpIssuer Название сервиса в приложении-аутентификаторе (TOTP_ISSUER)
+func totpIssuer() string {
+	if v := os.Getenv("TOTP_ISSUER"); v != "" {
+		return v
+	}
+	return "SiYuan Share"
+}
+
+// issueLoginChallenge Токен второго шага входа
+func issueLoginChallenge(userID string) (string, error) {
+	now := time.Now()
+	return keys.Default.Sign(jwt.MapClaims{
+		"aud": loginChallengeAudience,
+		"sub": userID,
+		"exp": now.Add(loginChallengeTTL).Unix(),
+		"iat": now.Unix(),
+	})
+}
+
+// parseLoginChallenge Проверка токена второго шага входа, возврат ID пользователя
+func parseLoginChallenge(raw string) (string, error) {
+	tok, err := keys.Default.Parse(raw, jwt.MapClaims{}, jwt.WithAudience(loginChallengeAudience), jwt.WithExpirationRequired())
+	if err != nil || !tok.Valid {
+		return "", errors.New("invalid or expired challenge")
+	}
+	sub, _ := tok.Claims.GetSubject()
+	if sub == "" {
+		return "", errors.New("invalid or expired challenge")
+	}
+	return sub, nil
+}
+
+// LoginTwoFactor Второй шаг входа: проверка кода и создание сессии
+func LoginTwoFactor(c *gin.Context) {
+	var req LoginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	userID, err := parseLoginChallenge(req.Challenge)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Invalid or expired challenge, please log in again"})
+		return
+	}
+
+	var user models.User
+	if err := models.DB.Where("id = ? AND is_active = ?", userID, true).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "User inactive or not found"})
+		return
+	}
+
+	// Перебор кодов ограничивается так же, как перебор паролей
+	limitKeys := attemptLimitKeys(c, &user)
+	if attemptBlocked(c, limitKeys) {
+		return
+	}
+
+	if !verifySecondFactor(&user, req.Code, req.RecoveryCode) {
+		auditLoginFailed(c, user.ID, user.Username, "invalid two-factor code")
+		if attemptFailed(c, limitKeys) {
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Invalid two-factor code"})
+		return
+	}
+	ratelimit.Default.Success(ratelimit.UserKey(user.Username))
+
+	startSession(c, &user, "2fa")
+}
+
+// attemptLimitKeys Ключи ограничения перебора паролей и кодов пользователя: имя и IP клиента
+func attemptLimitKeys(c *gin.Context, user *models.User) []string {
+	return []string{ratelimit.UserKey(user.Username), ratelimit.IPKey(c.ClientIP())}
+}
+
+// attemptBlocked Заблокированы ли попытки по ключам; при блокировке ответ 429 уже отправлен
+func attemptBlocked(c *gin.Context, limitKeys []string) bool {
+	if wait := ratelimit.Default.Check(limitKeys...); wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return true
+	}
+	return false
+}
+
+// attemptFailed Учёт неудачной попытки; если ключи заблокированы, ответ 429 уже отправлен
+func attemptFailed(c *gin.Context, limitKeys []string) bool {
+	if wait := ratelimit.Default.Fail(limitKeys...); wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return true
+	}
+	return false
+}
+
+// verifySecondFactor Проверка кода TOTP или резервного кода
+func verifySecondFactor(user *models.User, code, recoveryCode string) bool {
+	if code != "" {
+		return models.VerifyTOTP(user, code)
+	}
+	if recoveryCode != "" {
+		return models.UseRecoveryCode(user.ID, recoveryCode)
+	}
+	return false
+}
+
+// currentUser Текущий пользователь; при ошибке ответ уже отправлен
+func currentUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	if err := models.DB.Where("id = ?", c.GetString("userID")).First(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to load user: " + err.Error()})
+		return nil, false
+	}
+	return &user, true
+}
+
+// GetTwoFactorStatus Состояние 2FA текущего пользователя
+func GetTwoFactorStatus(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"enabled":           user.TOTPEnabled,
+		"recoveryCodesLeft": models.CountRecoveryCodes(user.ID),
+	}})
+}
+
+// SetupTwoFactor Первый шаг настройки: новый секрет и URI для QR-кода (2FA ещё не включена)
+func SetupTwoFactor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"code": 1, "msg": "Two-factor authentication is already enabled"})
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to generate secret"})
+		return
+	}
+	if err := models.DB.Model(user).UpdateColumn("totp_pending_secret", secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to save secret: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"secret":          secret,
+		"provisioningUri": totp.ProvisioningURI(totpIssuer(), user.Username, secret),
+	}})
+}
+
+// EnableTwoFactor Подтверждение настройки кодом из приложения; возвращает резервные коды (один раз)
+func EnableTwoFactor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"code": 1, "msg": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TOTPPendingSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Call /api/user/2fa/setup first"})
+		return
+	}
+	step, valid := totp.Validate(user.TOTPPendingSecret, req.Code, time.Now(), 0)
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid code"})
+		return
+	}
+	if err := models.EnableTOTP(user, user.TOTPPendingSecret, step); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to enable two-factor authentication: " + err.Error()})
+		return
+	}
+	codes, err := models.GenerateRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to generate recovery codes: " + err.Error()})
+		return
+	}
+	middleware.Audit(c, models.AuditEvent{Action: models.Audit2FAEnable, TargetType: "user", TargetID: user.ID})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"recoveryCodes": codes}})
+}
+
+// RegenerateRecoveryCodes Новый набор резервных кодов (прежние перестают действовать)
+func RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	// Похищенная сессия не должна позволять перебирать коды
+	limitKeys := attemptLimitKeys(c, user)
+	if attemptBlocked(c, limitKeys) {
+		return
+	}
+	if !models.VerifyTOTP(user, req.Code) {
+		middleware.Audit(c, models.AuditEvent{
+			Action: models.Audit2FARecovery, TargetType: "user", TargetID: user.ID, Result: models.AuditFailure, Detail: "invalid two-factor code",
+		})
+		if attemptFailed(c, limitKeys) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid code"})
+		return
+	}
+	ratelimit.Default.Success(ratelimit.UserKey(user.Username))
+	codes, err := models.GenerateRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to generate recovery codes: " + err.Error()})
+		return
+	}
+	middleware.Audit(c, models.AuditEvent{Action: models.Audit2FARecovery, TargetType: "user", TargetID: user.ID})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"recoveryCodes": codes}})
+}
+
+// DisableTwoFactor Отключение 2FA: требуется пароль и код (TOTP или резервный)
+func DisableTwoFactor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Two-factor authentication is not enabled"})
+		return
+	}
+	// Похищенная сессия не должна позволять перебирать пароль и коды
+	limitKeys := attemptLimitKeys(c, user)
+	if attemptBlocked(c, limitKeys) {
+		return
+	}
+	if !user.CheckPassword(req.Password) {
+		middleware.Audit(c, models.AuditEvent{
+			Action: models.Audit2FADisable, TargetType: "user", TargetID: user.ID, Result: models.AuditFailure, Detail: "invalid password",
+		})
+		if attemptFailed(c, limitKeys) {
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Invalid password"})
+		return
+	}
+	if !verifySecondFactor(user, req.Code, req.RecoveryCode) {
+		middleware.Audit(c, models.AuditEvent{
+			Action: models.Audit2FADisable, TargetType: "user", TargetID: user.ID, Result: models.AuditFailure, Detail: "invalid two-factor code",
+		})
+		if attemptFailed(c, limitKeys) {
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Invalid two-factor code"})
+		return
+	}
+	ratelimit.Default.Success(ratelimit.UserKey(user.Username))
+	if err := models.DisableTOTP(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to disable two-factor authentication: " + err.Error()})
+		return
+	}
+	middleware.Audit(c, models.AuditEvent{Action: models.Audit2FADisable, TargetType: "user", TargetID: user.ID})
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success"})
+}
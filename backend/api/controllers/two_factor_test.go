@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mihazzz123/siyuan-share/models"
+	"github.com/mihazzz123/siyuan-share/totp"
+)
+
+// twoFactorUser Активный пользователь с паролем secret123 и включённой 2FA
+func twoFactorUser(t *testing.T) *models.User {
+	t.Helper()
+	hash, err := models.HashPassword("secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{ID: models.NewUserID(), Username: "alice", Email: "alice@example.com", PasswordHash: hash, IsActive: true}
+	if err := models.CreateUser(&user); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.EnableTOTP(&user, secret, 0); err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+// postJSON POST-запрос с телом JSON с адреса remoteIP; возвращает статус и разобранный ответ
+func postJSON(r *gin.Engine, path, remoteIP string, body interface{}) (int, map[string]interface{}) {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = remoteIP + ":40000"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var resp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func TestPasswordLoginDoesNotResetTwoFactorLimit(t *testing.T) {
+	setupTestEnv(t)
+	twoFactorUser(t)
+	r := gin.New()
+	r.POST("/api/auth/login", Login)
+	r.POST("/api/auth/login/2fa", LoginTwoFactor)
+
+	// Каждая попытка - новый вход по паролю с нового IP: блокировать должен счётчик по имени
+	for i := 1; i <= 10; i++ {
+		ip := fmt.Sprintf("198.51.100.%d", i)
+		status, resp := postJSON(r, "/api/auth/login", ip, gin.H{"username": "alice", "password": "secret123"})
+		if status == http.StatusTooManyRequests {
+			return
+		}
+		data, _ := resp["data"].(map[string]interface{})
+		challenge, _ := data["challenge"].(string)
+		if status != http.StatusOK || challenge == "" {
+			t.Fatalf("login %d: HTTP %d %v", i, status, resp)
+		}
+		status, resp = postJSON(r, "/api/auth/login/2fa", ip, gin.H{"challenge": challenge, "code": "000000"})
+		if status == http.StatusTooManyRequests {
+			return
+		}
+		if status != http.StatusUnauthorized {
+			t.Fatalf("2fa %d: HTTP %d %v", i, status, resp)
+		}
+	}
+	t.Fatal("two-factor code guessing was not rate limited across password logins")
+}
+
+func TestTwoFactorSettingsRateLimited(t *testing.T) {
+	for _, path := range []string{"/api/user/2fa/disable", "/api/user/2fa/recovery-codes"} {
+		t.Run(path, func(t *testing.T) {
+			setupTestEnv(t)
+			user := twoFactorUser(t)
+			r := gin.New()
+			// Запрос из сессии Web пользователя
+			session := func(c *gin.Context) {
+				c.Set("userID", user.ID)
+				c.Set("sessionID", "ses_test")
+			}
+			r.POST("/api/user/2fa/disable", session, DisableTwoFactor)
+			r.POST("/api/user/2fa/recovery-codes", session, RegenerateRecoveryCodes)
+
+			for i := 1; i <= 10; i++ {
+				status, resp := postJSON(r, path, "198.51.100.1", gin.H{"password": "secret123", "code": "000000"})
+				if status == http.StatusTooManyRequests {
+					return
+				}
+				if status != http.StatusUnauthorized && status != http.StatusBadRequest {
+					t.Fatalf("attempt %d: HTTP %d %v", i, status, resp)
+				}
+			}
+			t.Fatal("two-factor code guessing was not rate limited")
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/mihazzz123/siyuan-share/keys"
+	"github.com/mihazzz123/siyuan-share/middleware"
+	"github.com/mihazzz123/siyuan-share/models"
+	"github.com/mihazzz123/siyuan-share/ratelimit"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// viewerTokenAudience Назначение токена просмотра, отличает его от сессионного JWT
+	viewerTokenAudience = "share-view"
+	// viewerCookiePrefix Префикс cookie с токеном просмотра, за ним следует ID публикации
+	viewerCookiePrefix = "share_unlock_"
+)
+
+// UnlockShareRequest Запрос токена просмотра защищённой паролем публикации
+type UnlockShareRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// viewerTokenTTL Время жизни токена просмотра (SHARE_UNLOCK_TTL, по умолчанию 1 час)
+func viewerTokenTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SHARE_UNLOCK_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return time.Hour
+}
+
+// passwordFingerprint Отпечаток хэша пароля: смена пароля публикации аннулирует выданные токены
+func passwordFingerprint(passwordHash string) string {
+	h := sha256.Sum256([]byte(passwordHash))
+	return hex.EncodeToString(h[:8])
+}
+
+// issueViewerToken Подписанный токен просмотра публикации и её дочерних публикаций
+func issueViewerToken(share *models.Share, expires time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"aud": viewerTokenAudience,
+		"sid": share.ID,
+		"pfp": passwordFingerprint(share.PasswordHash),
+		"exp": expires.Unix(),
+		"iat": time.Now().Unix(),
+	}
+	return keys.Default.Sign(claims)
+}
+
+// parseViewerToken Проверка подписи и срока токена просмотра, возврат ID публикации и отпечатка пароля
+func parseViewerToken(raw string) (shareID, fingerprint string, err error) {
+	tok, err := keys.Default.Parse(raw, jwt.MapClaims{}, jwt.WithAudience(viewerTokenAudience), jwt.WithExpirationRequired())
+	if err != nil || !tok.Valid {
+		return "", "", errors.New("invalid viewer token")
+	}
+	claims, _ := tok.Claims.(jwt.MapClaims)
+	shareID, _ = claims["sid"].(string)
+	fingerprint, _ = claims["pfp"].(string)
+	if shareID == "" {
+		return "", "", errors.New("invalid viewer token")
+	}
+	return shareID, fingerprint, nil
+}
+
+// viewerTokenCandidates Токены просмотра из заголовка Authorization и cookie публикации/родителя
+func viewerTokenCandidates(c *gin.Context, share *models.Share) []string {
+	var tokens []string
+	if parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2); len(parts) == 2 && parts[0] == "Bearer" {
+		tokens = append(tokens, strings.TrimSpace(parts[1]))
+	}
+	for _, id := range []string{share.ID, share.ParentShareID} {
+		if id == "" {
+			continue
+		}
+		if v, err := c.Cookie(viewerCookiePrefix + id); err == nil && v != "" {
+			tokens = append(tokens, v)
+		}
+	}
+	return tokens
+}
+
+// hasViewerAccess Есть ли у запроса действующий токен просмотра для публикации:
+// токен самой публикации или её родителя (для публикаций ссылаемых блоков)
+func hasViewerAccess(c *gin.Context, share *models.Share) bool {
+	for _, raw := range viewerTokenCandidates(c, share) {
+		shareID, fp, err := parseViewerToken(raw)
+		if err != nil {
+			continue
+		}
+		if shareID == share.ID && fp == passwordFingerprint(share.PasswordHash) {
+			return true
+		}
+		if share.ParentShareID != "" && shareID == share.ParentShareID {
+			var parent models.Share
+			if err := models.DB.Where("id = ?", share.ParentShareID).First(&parent).Error; err != nil {
+				continue
+			}
+			if !parent.IsExpired() && fp == passwordFingerprint(parent.PasswordHash) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkSharePassword Проверка пароля публикации с защитой от перебора (блокировка по публикации
+// и по IP клиента); при отказе ответ уже отправлен
+func checkSharePassword(c *gin.Context, share *models.Share, password string) bool {
+	limitKeys := []string{ratelimit.ShareKey(share.ID), ratelimit.IPKey(c.ClientIP())}
+	if wait := ratelimit.Default.Check(limitKeys...); wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return false
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(share.PasswordHash), []byte(password)); err != nil {
+		// Событие относится к владельцу публикации
+		middleware.Audit(c, models.AuditEvent{
+			UserID: share.UserID, Action: models.AuditUnlockFailed, TargetType: "share", TargetID: share.ID, Result: models.AuditFailure,
+		})
+		if wait := ratelimit.Default.Fail(limitKeys...); wait > 0 {
+			respondTooManyAttempts(c, wait)
+			return false
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Invalid password"})
+		return false
+	}
+	ratelimit.Default.Success(ratelimit.ShareKey(share.ID))
+	return true
+}
+
+// legacyPasswordSunset Дата удаления параметра ?password=: начиная с неё он не принимается
+// даже при SHARE_PASSWORD_QUERY
+var legacyPasswordSunset = time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+// legacyPasswordAllowed Принимается ли устаревший ?password= (SHARE_PASSWORD_QUERY=true, по умолчанию
+// выключено) - только для перехода старых клиентов до legacyPasswordSunset
+func legacyPasswordAllowed() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("SHARE_PASSWORD_QUERY"))
+	return enabled && time.Now().Before(legacyPasswordSunset)
+}
+
+// checkLegacySharePassword Устаревший пароль в параметре ?password= (до появления POST /api/s/:id/unlock).
+// Пароль в адресе попадает в журналы прокси и заголовок Referer, поэтому ответ помечается заголовками
+// Deprecation, Sunset и Warning со ссылкой на /unlock, а использование пишется в лог
+func checkLegacySharePassword(c *gin.Context, share *models.Share, password string) bool {
+	log.Printf("Deprecated ?password= used for share %s, clients should use POST /api/s/%s/unlock", share.ID, share.ID)
+	c.Header("Deprecation", "true")
+	c.Header("Sunset", legacyPasswordSunset.Format(http.TimeFormat))
+	c.Header("Link", "</api/s/"+share.ID+"/unlock>; rel=\"successor-version\"")
+	c.Header("Warning", `299 - "The password query parameter is deprecated, use POST /api/s/:id/unlock"`)
+	return checkSharePassword(c, share, password)
+}
+
+// isSecureRequest Пришёл ли запрос по HTTPS (напрямую или через прокси)
+func isSecureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+}
+
+// UnlockShare Однократная проверка пароля публикации и выдача короткоживущего токена просмотра.
+// Токен возвращается в ответе (для заголовка Authorization) и устанавливается в HttpOnly cookie.
+func UnlockShare(c *gin.Context) {
+	var req UnlockShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+
+	var share models.Share
+	if err := models.DB.Where("id = ?", c.Param("id")).First(&share).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "Share not found"})
+		return
+	}
+	if share.IsExpired() {
+		c.JSON(http.StatusGone, gin.H{"code": 1, "msg": "Share has expired"})
+		return
+	}
+	if !share.RequirePassword {
+		c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{"token": "", "expiresAt": nil}})
+		return
+	}
+
+	if !checkSharePassword(c, &share, req.Password) {
+		return
+	}
+
+	// Токен не переживает публикацию
+	expires := time.Now().Add(viewerTokenTTL())
+	if share.ExpireAt.Before(expires) {
+		expires = share.ExpireAt
+	}
+	token, err := issueViewerToken(&share, expires)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "Failed to sign token"})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(viewerCookiePrefix+share.ID, token, int(time.Until(expires).Seconds()), "/", "", isSecureRequest(c), true)
+
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "success", "data": gin.H{
+		"token":     token,
+		"expiresAt": expires,
+	}})
+}
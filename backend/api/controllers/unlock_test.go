@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+func TestLegacySharePasswordOptIn(t *testing.T) {
+	setupTestEnv(t)
+	hash, err := models.HashPassword("secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	share := models.Share{
+		ID: "share-1", UserID: "u1", DocID: "doc-1", DocTitle: "Doc", Content: "text",
+		RequirePassword: true, PasswordHash: hash, IsPublic: true, ExpireAt: time.Now().Add(time.Hour),
+	}
+	if err := models.DB.Create(&share).Error; err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.GET("/api/s/:id", GetShare)
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/s/share-1?password=secret123", nil))
+		return w
+	}
+
+	// По умолчанию пароль в адресе не принимается
+	if w := get(); w.Code != http.StatusUnauthorized {
+		t.Fatalf("without SHARE_PASSWORD_QUERY: HTTP %d %s", w.Code, w.Body.String())
+	}
+
+	t.Setenv("SHARE_PASSWORD_QUERY", "true")
+	w := get()
+	if w.Code != http.StatusOK {
+		t.Fatalf("with SHARE_PASSWORD_QUERY: HTTP %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Deprecation") != "true" || w.Header().Get("Sunset") == "" {
+		t.Fatalf("missing deprecation headers: %v", w.Header())
+	}
+
+	// После даты удаления параметр не принимается и с SHARE_PASSWORD_QUERY
+	prev := legacyPasswordSunset
+	legacyPasswordSunset = time.Now().Add(-time.Minute)
+	t.Cleanup(func() { legacyPasswordSunset = prev })
+	if w := get(); w.Code != http.StatusUnauthorized {
+		t.Fatalf("after sunset: HTTP %d %s", w.Code, w.Body.String())
+	}
+}
@@ -1,14 +1,14 @@
 package controllers
 
 import (
-	"encoding/json"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 
+	"github.com/mihazzz123/siyuan-share/analytics"
 	"github.com/mihazzz123/siyuan-share/models"
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // GetShare Получение содержимого публикации
@@ -24,48 +24,32 @@ func GetShare(c *gin.Context) {
 		return
 	}
 
-	// Проверка срока действия
-	if share.IsExpired() {
-		c.JSON(http.StatusGone, gin.H{
-			"code": 1,
-			"msg":  "Share has expired",
-		})
+	if !authorizeShareView(c, &share) {
 		return
 	}
 
-	// Если требуется пароль, проверка пароля
-	if share.RequirePassword {
-		password := c.Query("password")
-		if password == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code": 1,
-				"msg":  "Password required",
-			})
-			return
-		}
+	// Учёт просмотра: счётчик и статистика обновляются фоновой записью
+	viewCount := share.ViewCount
+	if recordShareView(c, &share) {
+		viewCount++
+	}
 
-		if err := bcrypt.CompareHashAndPassword([]byte(share.PasswordHash), []byte(password)); err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
+	// Отдача HTML для краулеров, RSS-читалок и curl (format=html или Accept: text/html)
+	c.Writer.Header().Add("Vary", "Accept")
+	if wantsHTML(c) {
+		html, err := renderShareHTML(c, &share)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
 				"code": 1,
-				"msg":  "Invalid password",
+				"msg":  "Failed to render share: " + err.Error(),
 			})
 			return
 		}
+		writeShareHTMLDocument(c, &share, html)
+		return
 	}
 
-	// Увеличение количества просмотров
-	models.DB.Model(&share).UpdateColumn("view_count", share.ViewCount+1)
-
-	// Обработка замены ссылок на блоки
-	content := share.Content
-	if share.References != "" {
-		var refs []models.BlockReference
-		if err := json.Unmarshal([]byte(share.References), &refs); err == nil {
-			// Получение baseURL для построения ссылок на блоки
-			baseURL := getBaseURL(c)
-			content = replaceBlockReferences(content, refs, baseURL, share.UserID)
-		}
-	}
+	content := resolveShareContent(&share, getBaseURL(c))
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
@@ -76,12 +60,72 @@ func GetShare(c *gin.Context) {
 			"content":         content,
 			"requirePassword": share.RequirePassword,
 			"expireAt":        share.ExpireAt,
-			"viewCount":       share.ViewCount + 1,
+			"viewCount":       viewCount,
+			"revision":        share.Revision,
 			"createdAt":       share.CreatedAt,
+			"updatedAt":       share.UpdatedAt,
 		},
 	})
 }
 
+// recordShareView Учёт просмотра публикации; false - просмотр бота, не входящий в ViewCount.
+// SPA загружает публикацию запросом XHR, поэтому исходный источник перехода
+// (document.referrer) передаёт в заголовке X-Referrer
+func recordShareView(c *gin.Context, share *models.Share) bool {
+	referrer := c.GetHeader("X-Referrer")
+	if referrer == "" {
+		referrer = c.GetHeader("Referer")
+	}
+	host := ""
+	if u, err := url.Parse(getBaseURL(c)); err == nil {
+		host = u.Host
+	}
+	return analytics.Record(analytics.View{
+		ShareID:   share.ID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Header:    c.Request.Header,
+		Referrer:  referrer,
+		Host:      host,
+	})
+}
+
+// resolveShareContent Содержимое публикации с заменой ссылок на блоки на URL их публикаций
+func resolveShareContent(share *models.Share, baseURL string) string {
+	content := share.Content
+	// Ссылаемые блоки и их публикации загружаются одним запросом
+	refs, err := models.ListShareReferences(share.ID)
+	if err == nil && len(refs) > 0 {
+		content = replaceBlockReferences(content, refs, baseURL)
+	}
+	return content
+}
+
+// authorizeShareView Проверка срока действия и пароля публикации, при отказе ответ уже отправлен
+func authorizeShareView(c *gin.Context, share *models.Share) bool {
+	// Проверка срока действия
+	if share.IsExpired() {
+		c.JSON(http.StatusGone, gin.H{
+			"code": 1,
+			"msg":  "Share has expired",
+		})
+		return false
+	}
+
+	// Если требуется пароль, нужен токен просмотра, выданный POST /api/s/:id/unlock
+	if share.RequirePassword && !hasViewerAccess(c, share) {
+		if password := c.Query("password"); password != "" && legacyPasswordAllowed() {
+			return checkLegacySharePassword(c, share, password)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code": 1,
+			"msg":  "Password required",
+		})
+		return false
+	}
+	return true
+}
+
 // getBaseURL Получение базового URL
 func getBaseURL(c *gin.Context) string {
 	baseURL := c.GetHeader("X-Base-URL")
@@ -104,9 +148,9 @@ func getBaseURL(c *gin.Context) string {
 }
 
 // replaceBlockReferences Замена ссылок на блоки в контенте на URL этих блоков
-func replaceBlockReferences(content string, refs []models.BlockReference, baseURL string, userID string) string {
+func replaceBlockReferences(content string, refs []models.ResolvedReference, baseURL string) string {
 	// Построение карты ID блока к контенту
-	blockMap := make(map[string]models.BlockReference)
+	blockMap := make(map[string]models.ResolvedReference)
 	for _, ref := range refs {
 		blockMap[ref.BlockID] = ref
 	}
@@ -136,13 +180,8 @@ func replaceBlockReferences(content string, refs []models.BlockReference, baseUR
 			return "[ссылка]"
 		}
 
-		// Поиск записи публикации для этого блока
-		var blockShare models.Share
-		err := models.DB.Where("user_id = ? AND doc_id = ?", userID, blockID).
-			Order("created_at DESC").
-			First(&blockShare).Error
-
-		if err != nil {
+		// Публикация блока определена при публикации и проверена в ListShareReferences
+		if ref.LiveChildID == "" {
 			// Публикация блока не найдена, откат к отображению текста
 			if displayText != "" {
 				return displayText
@@ -157,7 +196,7 @@ func replaceBlockReferences(content string, refs []models.BlockReference, baseUR
 		}
 
 		// Генерация URL для публикации блока
-		blockShareURL := baseURL + "/s/" + blockShare.ID
+		blockShareURL := baseURL + "/s/" + ref.LiveChildID
 
 		// Определить отображаемый текст
 		linkText := displayText
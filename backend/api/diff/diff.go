@@ -0,0 +1,325 @@
+// Package diff Построчное и поблочное сравнение содержимого публикаций
+package diff
+
+import (
+	"strings"
+
+	"github.com/mihazzz123/siyuan-share/kramdown"
+)
+
+// Op Тип операции в результате сравнения
+type Op string
+
+const (
+	OpEqual    Op = "equal"
+	OpInsert   Op = "insert"
+	OpDelete   Op = "delete"
+	OpModified Op = "modified" // Только для блоков: тот же ID блока, другое содержимое
+)
+
+// Ограничения сравнения: при превышении документы считаются полностью переписанными.
+// Время алгоритма Майерса O((N+M)*D), память O(N+M)
+const (
+	maxItems        = 5000 // Строк или блоков в каждой версии
+	maxEditDistance = 1000 // Число правок
+)
+
+// edit Элементарная правка: индексы в исходной (a) и новой (b) последовательности, -1 если нет
+type edit struct {
+	op   Op
+	a, b int
+}
+
+// myers Кратчайший сценарий правок между последовательностями длиной n и m (алгоритм Майерса
+// в линейной памяти, разбиение по средней змейке). При превышении ограничений возвращается
+// замена всего отрезка между общими началом и концом и false
+func myers(n, m int, eq func(i, j int) bool) ([]edit, bool) {
+	prefix := 0
+	for prefix < n && prefix < m && eq(prefix, prefix) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < n-prefix && suffix < m-prefix && eq(n-1-suffix, m-1-suffix) {
+		suffix++
+	}
+	a1, b1 := n-suffix, m-suffix
+
+	s := &myersState{eq: eq, out: make([]edit, 0, n+m)}
+	for i := 0; i < prefix; i++ {
+		s.out = append(s.out, edit{OpEqual, i, i})
+	}
+	ok := a1-prefix <= maxItems && b1-prefix <= maxItems
+	if ok {
+		limit := (a1 + b1 - 2*prefix + 1) / 2
+		if limit > maxEditDistance/2+1 {
+			limit = maxEditDistance/2 + 1
+		}
+		s.off = limit + 1
+		s.vf, s.vb = make([]int, 2*limit+3), make([]int, 2*limit+3)
+		ok = s.compare(prefix, a1, prefix, b1)
+	}
+	if !ok {
+		s.out = s.out[:prefix]
+		for i := prefix; i < a1; i++ {
+			s.out = append(s.out, edit{OpDelete, i, -1})
+		}
+		for j := prefix; j < b1; j++ {
+			s.out = append(s.out, edit{OpInsert, -1, j})
+		}
+	}
+	for k := suffix; k > 0; k-- {
+		s.out = append(s.out, edit{OpEqual, n - k, m - k})
+	}
+	return s.out, ok
+}
+
+// myersState Состояние поиска: диагонали прямого (vf) и обратного (vb) прохода общие для всех
+// уровней разбиения, limit = off-1 - наибольшее число шагов прохода
+type myersState struct {
+	eq     func(i, j int) bool
+	off    int
+	vf, vb []int
+	out    []edit
+}
+
+// compare Правки для отрезков a[a0:a1] и b[b0:b1] в порядке следования
+func (s *myersState) compare(a0, a1, b0, b1 int) bool {
+	for a0 < a1 && b0 < b1 && s.eq(a0, b0) {
+		s.out = append(s.out, edit{OpEqual, a0, b0})
+		a0++
+		b0++
+	}
+	suffix := 0
+	for a1-suffix > a0 && b1-suffix > b0 && s.eq(a1-1-suffix, b1-1-suffix) {
+		suffix++
+	}
+	a1, b1 = a1-suffix, b1-suffix
+
+	switch {
+	case a0 == a1:
+		for j := b0; j < b1; j++ {
+			s.out = append(s.out, edit{OpInsert, -1, j})
+		}
+	case b0 == b1:
+		for i := a0; i < a1; i++ {
+			s.out = append(s.out, edit{OpDelete, i, -1})
+		}
+	default:
+		// После отсечения общих концов правок не меньше двух, обе половины строго меньше
+		x, y, u, v, ok := s.middleSnake(a0, a1, b0, b1)
+		if !ok || !s.compare(a0, x, b0, y) {
+			return false
+		}
+		for ; x < u; x, y = x+1, y+1 {
+			s.out = append(s.out, edit{OpEqual, x, y})
+		}
+		if !s.compare(u, a1, v, b1) {
+			return false
+		}
+	}
+
+	for k := 0; k < suffix; k++ {
+		s.out = append(s.out, edit{OpEqual, a1 + k, b1 + k})
+	}
+	return true
+}
+
+// middleSnake Средняя змейка кратчайшего сценария: встречные проходы с начала и с конца
+// отрезков, (x, y)-(u, v) - общий участок, на котором они сошлись; false - правок больше ограничения
+func (s *myersState) middleSnake(a0, a1, b0, b1 int) (x, y, u, v int, ok bool) {
+	n, m := a1-a0, b1-b0
+	delta := n - m
+	odd := delta&1 != 0
+	maxD := (n + m + 1) / 2
+	if maxD > s.off-1 {
+		maxD = s.off - 1
+	}
+	off, vf, vb := s.off, s.vf, s.vb
+	vf[off+1], vb[off+1] = 0, 0
+
+	for d := 0; d <= maxD; d++ {
+		// Прямой проход
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			sx, sy := x, y
+			for x < n && y < m && s.eq(a0+x, b0+y) {
+				x++
+				y++
+			}
+			vf[off+k] = x
+			if odd && delta-k >= -(d-1) && delta-k <= d-1 && x+vb[off+delta-k] >= n {
+				return a0 + sx, b0 + sy, a0 + x, b0 + y, true
+			}
+		}
+		// Обратный проход в перевёрнутых координатах: x' = n-x, диагональ k' = delta-k
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vb[off+k-1] < vb[off+k+1]) {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+			y := x - k
+			sx, sy := x, y
+			for x < n && y < m && s.eq(a0+n-1-x, b0+m-1-y) {
+				x++
+				y++
+			}
+			vb[off+k] = x
+			if !odd && delta-k >= -d && delta-k <= d && x+vf[off+delta-k] >= n {
+				return a0 + n - x, b0 + m - y, a0 + n - sx, b0 + m - sy, true
+			}
+		}
+	}
+	return 0, 0, 0, 0, false
+}
+
+// LineChange Изменение строки; номера строк начинаются с 1, 0 означает отсутствие строки
+type LineChange struct {
+	Op      Op     `json:"op"`
+	OldLine int    `json:"oldLine,omitempty"`
+	NewLine int    `json:"newLine,omitempty"`
+	Text    string `json:"text"`
+}
+
+// BlockChange Изменение блока документа
+type BlockChange struct {
+	Op       Op     `json:"op"`
+	BlockID  string `json:"blockId,omitempty"`
+	OldIndex int    `json:"oldIndex"` // Индекс блока в старой версии, -1 если блок добавлен
+	NewIndex int    `json:"newIndex"` // Индекс блока в новой версии, -1 если блок удалён
+	OldText  string `json:"oldText,omitempty"`
+	NewText  string `json:"newText,omitempty"`
+}
+
+// Summary Сводка изменений. Replaced - версии слишком велики или различаются слишком сильно:
+// документ считается переписанным целиком, построчные и поблочные изменения не вычисляются
+type Summary struct {
+	LinesAdded     int  `json:"linesAdded"`
+	LinesRemoved   int  `json:"linesRemoved"`
+	BlocksAdded    int  `json:"blocksAdded"`
+	BlocksRemoved  int  `json:"blocksRemoved"`
+	BlocksModified int  `json:"blocksModified"`
+	Replaced       bool `json:"replaced,omitempty"`
+}
+
+// Changed Есть ли изменения
+func (s Summary) Changed() bool {
+	return s.LinesAdded+s.LinesRemoved+s.BlocksAdded+s.BlocksRemoved+s.BlocksModified > 0
+}
+
+// Result Результат сравнения двух версий содержимого
+type Result struct {
+	Summary Summary       `json:"summary"`
+	Lines   []LineChange  `json:"lines,omitempty"`
+	Blocks  []BlockChange `json:"blocks,omitempty"`
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lines Построчное сравнение; hunks - нужен ли список изменённых строк
+func lines(oldText, newText string, hunks bool, sum *Summary) []LineChange {
+	a, b := splitLines(oldText), splitLines(newText)
+	edits, ok := myers(len(a), len(b), func(i, j int) bool { return a[i] == b[j] })
+	if !ok {
+		sum.Replaced, hunks = true, false
+	}
+
+	var out []LineChange
+	if hunks {
+		out = make([]LineChange, 0, len(edits))
+	}
+	for _, e := range edits {
+		switch e.op {
+		case OpDelete:
+			sum.LinesRemoved++
+		case OpInsert:
+			sum.LinesAdded++
+		}
+		if !hunks {
+			continue
+		}
+		switch e.op {
+		case OpEqual:
+			out = append(out, LineChange{Op: OpEqual, OldLine: e.a + 1, NewLine: e.b + 1, Text: a[e.a]})
+		case OpDelete:
+			out = append(out, LineChange{Op: OpDelete, OldLine: e.a + 1, Text: a[e.a]})
+		case OpInsert:
+			out = append(out, LineChange{Op: OpInsert, NewLine: e.b + 1, Text: b[e.b]})
+		}
+	}
+	return out
+}
+
+// blockKey Ключ сопоставления блоков: ID блока SiYuan, если он есть, иначе содержимое
+func blockKey(b kramdown.Block) string {
+	if b.ID != "" {
+		return "id:" + b.ID
+	}
+	return "text:" + b.Text
+}
+
+// blocks Поблочное сравнение: блоки с одинаковым ID сопоставляются даже при изменённом
+// содержимом и помечаются как modified, блоки без ID сравниваются по содержимому
+func blocks(oldText, newText string, hunks bool, sum *Summary) []BlockChange {
+	a, b := kramdown.ParseBlocks(oldText), kramdown.ParseBlocks(newText)
+	edits, ok := myers(len(a), len(b), func(i, j int) bool { return blockKey(a[i]) == blockKey(b[j]) })
+	if !ok {
+		sum.Replaced, hunks = true, false
+	}
+
+	var out []BlockChange
+	if hunks {
+		out = make([]BlockChange, 0, len(edits))
+	}
+	for _, e := range edits {
+		switch e.op {
+		case OpEqual:
+			if a[e.a].Text == b[e.b].Text {
+				if hunks {
+					out = append(out, BlockChange{Op: OpEqual, BlockID: b[e.b].ID, OldIndex: e.a, NewIndex: e.b})
+				}
+				continue
+			}
+			sum.BlocksModified++
+			if hunks {
+				out = append(out, BlockChange{Op: OpModified, BlockID: b[e.b].ID, OldIndex: e.a, NewIndex: e.b,
+					OldText: a[e.a].Text, NewText: b[e.b].Text})
+			}
+		case OpDelete:
+			sum.BlocksRemoved++
+			if hunks {
+				out = append(out, BlockChange{Op: OpDelete, BlockID: a[e.a].ID, OldIndex: e.a, NewIndex: -1, OldText: a[e.a].Text})
+			}
+		case OpInsert:
+			sum.BlocksAdded++
+			if hunks {
+				out = append(out, BlockChange{Op: OpInsert, BlockID: b[e.b].ID, OldIndex: -1, NewIndex: e.b, NewText: b[e.b].Text})
+			}
+		}
+	}
+	return out
+}
+
+// Compare Сравнение двух версий: сводка и, при hunks, изменённые строки и блоки
+func Compare(oldText, newText string, hunks bool) Result {
+	var res Result
+	res.Lines = lines(oldText, newText, hunks, &res.Summary)
+	res.Blocks = blocks(oldText, newText, hunks, &res.Summary)
+	if res.Summary.Replaced {
+		res.Lines, res.Blocks = nil, nil
+	}
+	return res
+}
@@ -0,0 +1,175 @@
+// Package jobs Фоновые задачи сервиса
+package jobs
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+// SweeperConfig Параметры фоновой очистки публикаций
+type SweeperConfig struct {
+	Interval       time.Duration // Период очистки, 0 - очистка отключена
+	Grace          time.Duration // Срок хранения после ExpireAt/DeletedAt до окончательного удаления
+	VacuumInterval time.Duration // Период VACUUM и wal_checkpoint, 0 - отключено
+	AuditRetention time.Duration // Срок хранения журнала аудита, 0 - хранить бессрочно
+	ViewRetention  time.Duration // Срок хранения событий просмотров, 0 - хранить бессрочно
+}
+
+// SweeperConfigFromEnv Настройки из окружения: SWEEP_INTERVAL (по умолчанию 1h, off - отключить),
+// SWEEP_GRACE (по умолчанию 168h), VACUUM_INTERVAL (по умолчанию 24h, off - отключить),
+// AUDIT_RETENTION (по умолчанию 2160h, off - хранить бессрочно),
+// VIEW_EVENT_RETENTION (по умолчанию 720h, off - хранить бессрочно)
+func SweeperConfigFromEnv() SweeperConfig {
+	return SweeperConfig{
+		Interval:       envDuration("SWEEP_INTERVAL", time.Hour),
+		Grace:          envDuration("SWEEP_GRACE", 7*24*time.Hour),
+		VacuumInterval: envDuration("VACUUM_INTERVAL", 24*time.Hour),
+		AuditRetention: envDuration("AUDIT_RETENTION", 90*24*time.Hour),
+		ViewRetention:  envDuration("VIEW_EVENT_RETENTION", 30*24*time.Hour),
+	}
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(name))
+	switch strings.ToLower(v) {
+	case "":
+		return def
+	case "off", "0", "false":
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("Invalid %s=%q, using %s", name, v, def)
+		return def
+	}
+	return d
+}
+
+// SweepResult Итоги последнего прохода очистки
+type SweepResult struct {
+	At       time.Time          `json:"at"`
+	Cutoff   time.Time          `json:"cutoff"`
+	Report   models.PurgeReport `json:"report"`
+	Sessions int64              `json:"sessions"` // Удалённые истёкшие и отозванные сессии
+	Tokens   int64              `json:"tokens"`   // Удалённые истёкшие токены из писем
+	Audit    int64              `json:"audit"`    // Удалённые события аудита старше AUDIT_RETENTION
+	Views    int64              `json:"views"`    // Удалённые события просмотров старше VIEW_EVENT_RETENTION
+	Vacuumed bool               `json:"vacuumed"`
+	Error    string             `json:"error,omitempty"`
+}
+
+var (
+	lastSweepMu sync.RWMutex
+	lastSweep   *SweepResult
+)
+
+// LastSweep Итоги последнего прохода очистки (nil, если очистка ещё не запускалась)
+func LastSweep() *SweepResult {
+	lastSweepMu.RLock()
+	defer lastSweepMu.RUnlock()
+	return lastSweep
+}
+
+// StartSweeper Запуск фоновой очистки до отмены ctx: первый проход через минуту после старта
+func StartSweeper(ctx context.Context, cfg SweeperConfig) {
+	if cfg.Interval <= 0 {
+		log.Println("Share sweeper disabled")
+		return
+	}
+	log.Printf("Share sweeper started: interval %s, grace %s, vacuum %s, audit retention %s, view retention %s",
+		cfg.Interval, cfg.Grace, cfg.VacuumInterval, cfg.AuditRetention, cfg.ViewRetention)
+
+	go func() {
+		timer := time.NewTimer(time.Minute)
+		defer timer.Stop()
+		var lastVacuum time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+
+			vacuum := cfg.VacuumInterval > 0 && time.Since(lastVacuum) >= cfg.VacuumInterval
+			res := Sweep(cfg.Grace, cfg.AuditRetention, cfg.ViewRetention, vacuum)
+			if res.Vacuumed {
+				lastVacuum = res.At
+			}
+			timer.Reset(cfg.Interval)
+		}
+	}()
+}
+
+// Sweep Один проход очистки: окончательное удаление публикаций, сессий и токенов из писем старше grace,
+// событий аудита старше auditRetention и событий просмотров старше viewRetention (0 - не удаляются)
+// и, при vacuum, сжатие БД
+func Sweep(grace, auditRetention, viewRetention time.Duration, vacuum bool) SweepResult {
+	now := time.Now()
+	res := SweepResult{At: now, Cutoff: now.Add(-grace)}
+
+	report, err := models.PurgeShares(res.Cutoff)
+	res.Report = report
+	if err != nil {
+		res.Error = err.Error()
+		log.Printf("Share sweeper failed: %v", err)
+	} else if report.Total() > 0 {
+		log.Printf("Share sweeper purged %d shares (expired: %d, deleted: %d, reference children: %d), %d revisions",
+			report.Total(), report.Expired, report.Deleted, report.ChildShares, report.Revisions)
+	}
+
+	sessions, err := models.PurgeSessions(res.Cutoff)
+	res.Sessions = sessions
+	if err != nil {
+		log.Printf("Session sweeper failed: %v", err)
+	} else if sessions > 0 {
+		log.Printf("Session sweeper purged %d sessions", sessions)
+	}
+
+	tokens, err := models.PurgeEmailTokens(res.Cutoff)
+	res.Tokens = tokens
+	if err != nil {
+		log.Printf("Email token sweeper failed: %v", err)
+	} else if tokens > 0 {
+		log.Printf("Email token sweeper purged %d tokens", tokens)
+	}
+
+	if auditRetention > 0 {
+		audit, err := models.PurgeAuditEvents(now.Add(-auditRetention))
+		res.Audit = audit
+		if err != nil {
+			log.Printf("Audit sweeper failed: %v", err)
+		} else if audit > 0 {
+			log.Printf("Audit sweeper purged %d events", audit)
+		}
+	}
+
+	if viewRetention > 0 {
+		views, err := models.PurgeViewEvents(now.Add(-viewRetention))
+		res.Views = views
+		if err != nil {
+			log.Printf("View event sweeper failed: %v", err)
+		} else if views > 0 {
+			log.Printf("View event sweeper purged %d events", views)
+		}
+	}
+
+	if vacuum {
+		if err := models.VacuumDB(); err != nil {
+			log.Printf("Database vacuum failed: %v", err)
+		} else {
+			res.Vacuumed = true
+			log.Println("Database vacuumed")
+		}
+	}
+
+	lastSweepMu.Lock()
+	lastSweep = &res
+	lastSweepMu.Unlock()
+	return res
+}
@@ -0,0 +1,445 @@
+// Package keys Управление ключами подписи JWT: ключ по умолчанию генерируется и хранится
+// в DATA_DIR, несколько ключей различаются по kid, поддерживается ротация
+package keys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// Алгоритмы подписи
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA" // Ed25519
+	AlgRS256 = "RS256"
+)
+
+// keyFileName Файл ключей в DATA_DIR
+const keyFileName = "jwt_keys.json"
+
+// minSecretLength Минимальная длина SESSION_SECRET в байтах
+const minSecretLength = 32
+
+// weakSecrets Известные небезопасные значения SESSION_SECRET (примеры из документации и шаблонов)
+var weakSecrets = map[string]bool{
+	"dev-secret": true, "secret": true, "changeme": true, "change-me": true, "password": true,
+	"your-secret": true, "your-secret-key": true, "session-secret": true, "siyuan-share": true,
+}
+
+// ErrUnknownKey Токен подписан неизвестным или удалённым ключом
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key Ключ подписи
+type Key struct {
+	ID        string    `json:"kid"`
+	Alg       string    `json:"alg"`
+	Material  string    `json:"material"` // HS256: base64 секрета, EdDSA/RS256: закрытый ключ PKCS#8 PEM
+	CreatedAt time.Time `json:"createdAt"`
+	// RetiredAt Время вывода из подписи: ключ ещё проверяет выданные токены до RetiredAt+Retain
+	RetiredAt *time.Time `json:"retiredAt,omitempty"`
+
+	signKey   interface{}
+	verifyKey interface{}
+	persisted bool
+}
+
+// Config Настройки менеджера ключей
+type Config struct {
+	DataDir  string        // Каталог файла ключей
+	Alg      string        // Алгоритм новых ключей (JWT_ALG)
+	Secret   string        // SESSION_SECRET: если задан, используется как ключ HS256 вместо файла
+	Rotation time.Duration // Период ротации ключа (JWT_KEY_ROTATION, 0 - без ротации)
+	Retain   time.Duration // Сколько выведенный ключ ещё проверяет подписи (JWT_KEY_RETAIN)
+	Release  bool          // Режим release: слабый SESSION_SECRET - ошибка запуска
+}
+
+// ConfigFromEnv Настройки из переменных окружения
+func ConfigFromEnv(release bool) Config {
+	cfg := Config{
+		DataDir: os.Getenv("DATA_DIR"),
+		Alg:     strings.TrimSpace(os.Getenv("JWT_ALG")),
+		Secret:  os.Getenv("SESSION_SECRET"),
+		Retain:  7 * 24 * time.Hour,
+		Release: release,
+	}
+	if cfg.DataDir == "" {
+		cfg.DataDir = "./data"
+	}
+	if d, err := time.ParseDuration(os.Getenv("JWT_KEY_ROTATION")); err == nil && d > 0 {
+		cfg.Rotation = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("JWT_KEY_RETAIN")); err == nil && d > 0 {
+		cfg.Retain = d
+	}
+	return cfg
+}
+
+// Manager Набор ключей: один подписывающий, остальные только проверяют подписи
+type Manager struct {
+	mu   sync.RWMutex
+	cfg  Config
+	keys []*Key // Последний невыведенный ключ - подписывающий
+	now  func() time.Time
+}
+
+// Default Менеджер ключей приложения (см. Init)
+var Default *Manager
+
+// Init Инициализация менеджера ключей по умолчанию
+func Init(cfg Config) error {
+	m, err := NewManager(cfg)
+	if err != nil {
+		return err
+	}
+	Default = m
+	return nil
+}
+
+// NewManager Загрузка ключей из файла (или SESSION_SECRET) с генерацией ключа при первом запуске
+func NewManager(cfg Config) (*Manager, error) {
+	if cfg.Alg == "" {
+		cfg.Alg = AlgHS256
+	}
+	switch strings.ToUpper(cfg.Alg) {
+	case "HS256":
+		cfg.Alg = AlgHS256
+	case "EDDSA", "ED25519":
+		cfg.Alg = AlgEdDSA
+	case "RS256":
+		cfg.Alg = AlgRS256
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALG %q (HS256, EdDSA, RS256)", cfg.Alg)
+	}
+
+	m := &Manager{cfg: cfg, now: time.Now}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Secret != "" {
+		if reason := weakSecretReason(cfg.Secret); reason != "" {
+			if cfg.Release {
+				return nil, fmt.Errorf("SESSION_SECRET is insecure (%s); unset it to use a generated key or set a random value of at least %d bytes", reason, minSecretLength)
+			}
+			log.Printf("WARNING: SESSION_SECRET is insecure (%s), the server will refuse to start with it in release mode", reason)
+		}
+		// Ключ из окружения подписывает токены, ключи из файла только проверяют ранее выданные
+		sum := sha256.Sum256([]byte(cfg.Secret))
+		env := &Key{ID: "env-" + hex.EncodeToString(sum[:4]), Alg: AlgHS256, CreatedAt: m.now()}
+		env.signKey, env.verifyKey = []byte(cfg.Secret), []byte(cfg.Secret)
+		m.keys = append(m.keys, env)
+		return m, nil
+	}
+
+	if m.signing() == nil || m.signing().Alg != cfg.Alg {
+		if _, err := m.rotateLocked(); err != nil {
+			return nil, err
+		}
+	} else if err := m.maybeRotate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// weakSecretReason Причина, по которой секрет считается слабым (пусто - секрет допустим)
+func weakSecretReason(secret string) string {
+	if weakSecrets[strings.ToLower(strings.TrimSpace(secret))] {
+		return "known default value"
+	}
+	if len(secret) < minSecretLength {
+		return fmt.Sprintf("shorter than %d bytes", minSecretLength)
+	}
+	return ""
+}
+
+func (m *Manager) path() string {
+	return filepath.Join(m.cfg.DataDir, keyFileName)
+}
+
+// load Чтение файла ключей, ключи с истёкшим сроком проверки отбрасываются
+func (m *Manager) load() error {
+	data, err := os.ReadFile(m.path())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var stored []*Key
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("invalid %s: %w", keyFileName, err)
+	}
+	now := m.now()
+	for _, k := range stored {
+		if k.RetiredAt != nil && now.After(k.RetiredAt.Add(m.cfg.Retain)) {
+			continue
+		}
+		if err := k.decode(); err != nil {
+			return fmt.Errorf("key %s: %w", k.ID, err)
+		}
+		k.persisted = true
+		m.keys = append(m.keys, k)
+	}
+	return nil
+}
+
+// save Запись ключей из файла (ключ из SESSION_SECRET не сохраняется)
+func (m *Manager) save() error {
+	stored := make([]*Key, 0, len(m.keys))
+	for _, k := range m.keys {
+		if k.persisted {
+			stored = append(stored, k)
+		}
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.cfg.DataDir, 0755); err != nil {
+		return err
+	}
+	tmp := m.path() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path())
+}
+
+// signing Подписывающий ключ: последний невыведенный
+func (m *Manager) signing() *Key {
+	for i := len(m.keys) - 1; i >= 0; i-- {
+		if m.keys[i].RetiredAt == nil {
+			return m.keys[i]
+		}
+	}
+	return nil
+}
+
+// Rotate Генерация нового подписывающего ключа; прежний продолжает проверять подписи JWT_KEY_RETAIN
+func (m *Manager) Rotate() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cfg.Secret != "" {
+		return "", errors.New("signing key is set by SESSION_SECRET and cannot be rotated")
+	}
+	return m.rotateLocked()
+}
+
+func (m *Manager) rotateLocked() (string, error) {
+	k, err := generateKey(m.cfg.Alg, m.now())
+	if err != nil {
+		return "", err
+	}
+	now := m.now()
+	kept := m.keys[:0]
+	for _, old := range m.keys {
+		if old.RetiredAt == nil {
+			old.RetiredAt = &now
+		}
+		// Ключи, срок проверки которых истёк, удаляются
+		if now.Before(old.RetiredAt.Add(m.cfg.Retain)) {
+			kept = append(kept, old)
+		}
+	}
+	m.keys = append(kept, k)
+	if err := m.save(); err != nil {
+		return "", err
+	}
+	log.Printf("JWT signing key generated: kid=%s alg=%s", k.ID, k.Alg)
+	return k.ID, nil
+}
+
+// maybeRotate Ротация по расписанию JWT_KEY_ROTATION
+func (m *Manager) maybeRotate() error {
+	if m.cfg.Rotation <= 0 || m.cfg.Secret != "" {
+		return nil
+	}
+	if k := m.signing(); k != nil && m.now().Sub(k.CreatedAt) < m.cfg.Rotation {
+		return nil
+	}
+	_, err := m.rotateLocked()
+	return err
+}
+
+// Sign Подпись claims текущим ключом, kid указывается в заголовке токена
+func (m *Manager) Sign(claims jwt.Claims) (string, error) {
+	m.mu.RLock()
+	k := m.signing()
+	due := m.cfg.Rotation > 0 && m.cfg.Secret == "" && k != nil && m.now().Sub(k.CreatedAt) >= m.cfg.Rotation
+	m.mu.RUnlock()
+	if due {
+		m.mu.Lock()
+		if err := m.maybeRotate(); err != nil {
+			log.Printf("JWT key rotation failed: %v", err)
+		}
+		k = m.signing()
+		m.mu.Unlock()
+	}
+	if k == nil {
+		return "", errors.New("no signing key")
+	}
+	tok := jwt.NewWithClaims(signingMethod(k.Alg), claims)
+	tok.Header["kid"] = k.ID
+	return tok.SignedString(k.signKey)
+}
+
+// Parse Проверка подписи токена ключом из заголовка kid; токены без kid проверяются
+// подписывающим ключом. Алгоритм должен совпадать с алгоритмом ключа.
+func (m *Manager) Parse(raw string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append(opts, jwt.WithValidMethods([]string{AlgHS256, AlgEdDSA, AlgRS256}))
+	return jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		kid, _ := t.Header["kid"].(string)
+		var k *Key
+		if kid == "" {
+			k = m.signing()
+		} else {
+			for _, c := range m.keys {
+				if c.ID == kid {
+					k = c
+					break
+				}
+			}
+		}
+		if k == nil {
+			return nil, ErrUnknownKey
+		}
+		if t.Method.Alg() != k.Alg {
+			return nil, fmt.Errorf("unexpected signing method %s for key %s", t.Method.Alg(), k.ID)
+		}
+		return k.verifyKey, nil
+	}, opts...)
+}
+
+// PublicKeys Открытые ключи EdDSA/RS256 в формате JWK (для сторонней проверки токенов)
+func (m *Manager) PublicKeys() []map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := []map[string]string{}
+	for _, k := range m.keys {
+		switch pub := k.verifyKey.(type) {
+		case ed25519.PublicKey:
+			out = append(out, map[string]string{"kty": "OKP", "crv": "Ed25519", "kid": k.ID, "alg": k.Alg, "use": "sig",
+				"x": base64.RawURLEncoding.EncodeToString(pub)})
+		case *rsa.PublicKey:
+			out = append(out, map[string]string{"kty": "RSA", "kid": k.ID, "alg": k.Alg, "use": "sig",
+				"n": base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(bigEndian(pub.E))})
+		}
+	}
+	return out
+}
+
+func bigEndian(e int) []byte {
+	var b []byte
+	for e > 0 {
+		b = append([]byte{byte(e & 0xff)}, b...)
+		e >>= 8
+	}
+	return b
+}
+
+func signingMethod(alg string) jwt.SigningMethod {
+	switch alg {
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+// generateKey Новый случайный ключ указанного алгоритма
+func generateKey(alg string, now time.Time) (*Key, error) {
+	kidBytes := make([]byte, 8)
+	if _, err := rand.Read(kidBytes); err != nil {
+		return nil, err
+	}
+	k := &Key{ID: hex.EncodeToString(kidBytes), Alg: alg, CreatedAt: now, persisted: true}
+
+	switch alg {
+	case AlgHS256:
+		secret := make([]byte, 64)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		k.Material = base64.StdEncoding.EncodeToString(secret)
+	case AlgEdDSA, AlgRS256:
+		var priv crypto.Signer
+		var err error
+		if alg == AlgEdDSA {
+			_, priv, err = ed25519.GenerateKey(rand.Reader)
+		} else {
+			priv, err = rsa.GenerateKey(rand.Reader, 2048)
+		}
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			return nil, err
+		}
+		k.Material = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %s", alg)
+	}
+	return k, k.decode()
+}
+
+// decode Разбор Material в ключи подписи и проверки
+func (k *Key) decode() error {
+	switch k.Alg {
+	case AlgHS256:
+		secret, err := base64.StdEncoding.DecodeString(k.Material)
+		if err != nil {
+			return err
+		}
+		k.signKey, k.verifyKey = secret, secret
+	case AlgEdDSA, AlgRS256:
+		block, _ := pem.Decode([]byte(k.Material))
+		if block == nil {
+			return errors.New("invalid PEM")
+		}
+		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return err
+		}
+		switch p := priv.(type) {
+		case ed25519.PrivateKey:
+			if k.Alg != AlgEdDSA {
+				return errors.New("key type does not match algorithm")
+			}
+			k.signKey, k.verifyKey = p, p.Public()
+		case *rsa.PrivateKey:
+			if k.Alg != AlgRS256 {
+				return errors.New("key type does not match algorithm")
+			}
+			k.signKey, k.verifyKey = p, &p.PublicKey
+		default:
+			return errors.New("unsupported private key type")
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %s", k.Alg)
+	}
+	return nil
+}
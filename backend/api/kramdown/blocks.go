@@ -0,0 +1,133 @@
+// Package kramdown Разбор Markdown/kramdown SiYuan на блоки с учётом атрибутов IAL
+package kramdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Block Блок документа: абзац, заголовок, список, таблица, блок кода и т.д.
+type Block struct {
+	ID        string            `json:"id,omitempty"`    // ID блока SiYuan из IAL {: id="..."}
+	Attrs     map[string]string `json:"attrs,omitempty"` // Все атрибуты IAL блока
+	Text      string            `json:"text"`            // Содержимое блока без IAL
+	StartLine int               `json:"startLine"`       // Номер первой строки (с 1)
+	EndLine   int               `json:"endLine"`         // Номер последней строки (с 1)
+}
+
+var (
+	// Отдельная строка IAL: {: id="20210101000000-abcdefg" updated="..."}
+	ialLinePattern = regexp.MustCompile(`^\s*\{:\s*([^}]*)\}\s*$`)
+	// IAL в начале элемента списка: "* {: id="..."}текст" или "1. {: id="..."}текст"
+	listItemIALPattern = regexp.MustCompile(`^(\s*(?:[-*+]|\d+\.)\s+)\{:[^}]*\}`)
+	// Строчный IAL после элемента: "`код`{: id="..."}"
+	inlineIALPattern = regexp.MustCompile(`\{:\s*[^}]*\}`)
+	// Пара атрибута IAL: key="value"
+	ialAttrPattern = regexp.MustCompile(`([\w-]+)="([^"]*)"`)
+	// ID блока SiYuan
+	blockIDPattern = regexp.MustCompile(`^[0-9]{14}-[0-9a-z]{7}$`)
+)
+
+// ParseIAL Разбор содержимого IAL (без фигурных скобок) в карту атрибутов
+func ParseIAL(s string) map[string]string {
+	attrs := map[string]string{}
+	for _, m := range ialAttrPattern.FindAllStringSubmatch(s, -1) {
+		attrs[m[1]] = m[2]
+	}
+	return attrs
+}
+
+// IsBlockID Проверка формата ID блока SiYuan
+func IsBlockID(id string) bool {
+	return blockIDPattern.MatchString(id)
+}
+
+// StripIAL Удаление всех IAL из текста, разметка остаётся без изменений
+func StripIAL(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if ialLinePattern.MatchString(line) {
+			continue
+		}
+		line = listItemIALPattern.ReplaceAllString(line, "$1")
+		line = inlineIALPattern.ReplaceAllString(line, "")
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// ParseBlocks Разбиение документа на блоки верхнего уровня
+// Блоки разделяются пустыми строками; блоки кода (``` и ~~~) и формулы ($$) не разрываются.
+// IAL на отдельной строке закрывает текущий блок и задаёт его атрибуты.
+func ParseBlocks(content string) []Block {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	lines := strings.Split(content, "\n")
+
+	var blocks []Block
+	var cur []string
+	start := 0
+	fence := ""
+
+	flush := func(end int, attrs map[string]string) {
+		if len(cur) == 0 {
+			return
+		}
+		b := Block{
+			Text:      StripIAL(strings.Join(cur, "\n")),
+			StartLine: start + 1,
+			EndLine:   end + 1,
+		}
+		if len(attrs) > 0 {
+			b.Attrs = attrs
+			if id := attrs["id"]; IsBlockID(id) {
+				b.ID = id
+			}
+		}
+		blocks = append(blocks, b)
+		cur = nil
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		// Внутри блока кода или формулы до закрывающего маркера
+		if fence != "" {
+			cur = append(cur, line)
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+
+		if m := ialLinePattern.FindStringSubmatch(line); m != nil {
+			// IAL документа или IAL без блока перед ним игнорируются
+			if len(cur) > 0 {
+				flush(i, ParseIAL(m[1]))
+			}
+			continue
+		}
+
+		if trimmed == "" {
+			flush(i-1, nil)
+			continue
+		}
+
+		if len(cur) == 0 {
+			start = i
+		}
+		cur = append(cur, line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			fence = "```"
+		case strings.HasPrefix(trimmed, "~~~"):
+			fence = "~~~"
+		case trimmed == "$$":
+			fence = "$$"
+		}
+	}
+	flush(len(lines)-1, nil)
+
+	return blocks
+}
@@ -0,0 +1,100 @@
+package kramdown
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindMathInline Тип узла строчной формулы
+var KindMathInline = ast.NewNodeKind("MathInline")
+
+// MathInline Строчная формула $...$ (или $$...$$ внутри строки)
+type MathInline struct {
+	ast.BaseInline
+	Formula []byte
+	Display bool
+}
+
+// Kind Тип узла
+func (n *MathInline) Kind() ast.NodeKind { return KindMathInline }
+
+// Dump Отладочный вывод узла
+func (n *MathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Formula": string(n.Formula)}, nil)
+}
+
+// mathInlineParser Разбор строчных формул по правилам pandoc: после открывающего $ и перед
+// закрывающим не должно быть пробела, за закрывающим $ не должна следовать цифра ("$5 и $10" - не формула)
+type mathInlineParser struct{}
+
+func (p *mathInlineParser) Trigger() []byte { return []byte{'$'} }
+
+func (p *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	delim := 1
+	if len(line) > 1 && line[1] == '$' {
+		delim = 2
+	}
+	if len(line) <= delim || util.IsSpace(line[delim]) {
+		return nil
+	}
+	for i := delim; i+delim <= len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case line[i] == '$':
+			if delim == 2 && (i+1 >= len(line) || line[i+1] != '$') {
+				continue
+			}
+			if util.IsSpace(line[i-1]) {
+				continue
+			}
+			end := i + delim
+			if delim == 1 && end < len(line) && line[end] >= '0' && line[end] <= '9' {
+				continue
+			}
+			node := &MathInline{
+				Formula: append([]byte(nil), line[delim:i]...),
+				Display: delim == 2,
+			}
+			block.Advance(end)
+			return node
+		}
+	}
+	return nil
+}
+
+// mathRenderer Вывод формул в виде <span class="language-math"> для KaTeX/MathJax на клиенте
+type mathRenderer struct{}
+
+func (r *mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMathInline, r.renderMathInline)
+}
+
+func (r *mathRenderer) renderMathInline(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+	n := node.(*MathInline)
+	class := "language-math"
+	if n.Display {
+		class = "language-math math-display"
+	}
+	_, _ = w.WriteString(`<span class="` + class + `">`)
+	_, _ = w.Write(util.EscapeHTML(n.Formula))
+	_, _ = w.WriteString("</span>")
+	return ast.WalkSkipChildren, nil
+}
+
+// mathExtension Расширение goldmark для строчных формул; блочные формулы $$ преобразуются
+// в блоки кода ```math на этапе предобработки
+type mathExtension struct{}
+
+func (e *mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(&mathInlineParser{}, 500)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&mathRenderer{}, 500)))
+}
@@ -0,0 +1,167 @@
+package kramdown
+
+import (
+	"bytes"
+	stdhtml "html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// ialMarkerPrefix Маркер IAL, в который предобработка превращает {: id="..."} после блока.
+// HTML-комментарий прерывает абзац, поэтому становится отдельным узлом сразу за блоком.
+const ialMarkerPrefix = "<!--ial:"
+
+var (
+	markdown = goldmark.New(
+		goldmark.WithExtensions(extension.GFM, &mathExtension{}),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+			parser.WithASTTransformers(util.Prioritized(&ialTransformer{}, 100)),
+		),
+		goldmark.WithRendererOptions(
+			// Сырой HTML из SiYuan (<u>, <kbd>, <sup> ...) пропускается и затем очищается политикой
+			html.WithUnsafe(),
+		),
+	)
+
+	policy = newPolicy()
+)
+
+// newPolicy Политика очистки HTML: UGC плюс классы языков кода/формул и ID блоков
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+( math-display)?$`)).OnElements("code", "span")
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^[\p{L}\p{N}_-]{1,128}$`)).Globally()
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^$|^(checked|disabled)$`)).OnElements("input")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowElements("input", "kbd", "mark", "u")
+	return p
+}
+
+// Render Преобразование Markdown/kramdown SiYuan в очищенный HTML.
+// IAL {: id="..."} после блока превращается в атрибут id элемента (абзацы, заголовки,
+// списки, цитаты, таблицы), блоки $$ выводятся как <pre><code class="language-math">,
+// строчные формулы - как <span class="language-math">.
+func Render(content string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(preprocess(content)), &buf); err != nil {
+		return "", err
+	}
+	return policy.Sanitize(buf.String()), nil
+}
+
+// preprocess Подготовка kramdown к разбору goldmark: IAL после блоков заменяются маркерами,
+// строчные IAL удаляются, блоки формул $$ превращаются в блоки кода math.
+// Содержимое блоков кода не изменяется.
+func preprocess(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines))
+	fence := ""
+	prevBlank := true
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				if fence == "$$" {
+					line = "```"
+				}
+				fence = ""
+			}
+			out = append(out, line)
+			prevBlank = false
+			continue
+		}
+
+		if m := ialLinePattern.FindStringSubmatch(line); m != nil {
+			// IAL без блока перед ним (например, IAL документа) отбрасывается
+			if !prevBlank {
+				if id := ParseIAL(m[1])["id"]; IsBlockID(id) {
+					out = append(out, ialMarkerPrefix+id+"-->")
+				}
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			fence = "```"
+		case strings.HasPrefix(trimmed, "~~~"):
+			fence = "~~~"
+		case trimmed == "$$":
+			fence = "$$"
+			line = "```math"
+		default:
+			line = listItemIALPattern.ReplaceAllString(line, "$1")
+			line = inlineIALPattern.ReplaceAllString(line, "")
+		}
+
+		out = append(out, line)
+		prevBlank = trimmed == ""
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// ialTransformer Перенос ID блока из маркера IAL в атрибут предыдущего блока и удаление маркера
+type ialTransformer struct{}
+
+func (t *ialTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	var markers []ast.Node
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		hb, ok := n.(*ast.HTMLBlock)
+		if !ok || hb.Lines().Len() != 1 {
+			return ast.WalkContinue, nil
+		}
+		seg := hb.Lines().At(0)
+		line := strings.TrimSpace(string(seg.Value(source)))
+		if !strings.HasPrefix(line, ialMarkerPrefix) || !strings.HasSuffix(line, "-->") {
+			return ast.WalkContinue, nil
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(line, ialMarkerPrefix), "-->")
+		if prev := hb.PreviousSibling(); prev != nil {
+			prev.SetAttributeString("id", []byte(id))
+		}
+		markers = append(markers, hb)
+		return ast.WalkSkipChildren, nil
+	})
+
+	for _, m := range markers {
+		m.Parent().RemoveChild(m.Parent(), m)
+	}
+}
+
+// plainTextPolicy Политика, удаляющая всю разметку
+var plainTextPolicy = bluemonday.StrictPolicy()
+
+// Excerpt Текстовая выдержка из HTML для описаний и превью ссылок:
+// без тегов, с нормализованными пробелами, не длиннее maxRunes символов (по границе слова)
+func Excerpt(htmlContent string, maxRunes int) string {
+	plain := stdhtml.UnescapeString(plainTextPolicy.Sanitize(htmlContent))
+	plain = strings.Join(strings.Fields(plain), " ")
+	runes := []rune(plain)
+	if len(runes) <= maxRunes {
+		return plain
+	}
+	cut := string(runes[:maxRunes])
+	if i := strings.LastIndex(cut, " "); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:-") + "…"
+}
@@ -0,0 +1,138 @@
+// Package mailer Отправка писем: SMTP для продакшена, файлы или журнал для локальной разработки
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Драйверы отправки (MAIL_DRIVER)
+const (
+	DriverSMTP = "smtp"
+	DriverFile = "file" // Письма сохраняются в MAIL_DIR в формате .eml
+	DriverLog  = "log"  // Письма выводятся в журнал сервера (ссылки с токенами скрываются)
+	DriverNone = "none" // Письма не отправляются
+)
+
+// ErrMailDisabled Отправка писем не настроена (MAIL_DRIVER=none)
+var ErrMailDisabled = errors.New("mail delivery is not configured")
+
+// Message Письмо (только текст)
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Mailer Способ отправки писем
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config Настройки отправки писем
+type Config struct {
+	Driver string
+	From   string // Адрес отправителя (MAIL_FROM)
+	Dir    string // Каталог писем драйвера file (MAIL_DIR)
+	SMTP   SMTPConfig
+}
+
+// ConfigFromEnv Настройки из переменных окружения. Без MAIL_DRIVER используется smtp, если задан
+// SMTP_HOST, иначе log; в режиме release (release) вместо log - none: драйверы log и file
+// сохраняют ссылки сброса пароля вне почты и включаются только явно
+func ConfigFromEnv(release bool) Config {
+	cfg := Config{
+		Driver: strings.ToLower(strings.TrimSpace(os.Getenv("MAIL_DRIVER"))),
+		From:   os.Getenv("MAIL_FROM"),
+		Dir:    os.Getenv("MAIL_DIR"),
+		SMTP: SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			TLS:      strings.ToLower(os.Getenv("SMTP_TLS")),
+		},
+	}
+	if cfg.Driver == "" {
+		switch {
+		case cfg.SMTP.Host != "":
+			cfg.Driver = DriverSMTP
+		case release:
+			cfg.Driver = DriverNone
+		default:
+			cfg.Driver = DriverLog
+		}
+	}
+	if cfg.From == "" {
+		cfg.From = "SiYuan Share <noreply@localhost>"
+	}
+	if cfg.Dir == "" {
+		dataDir := os.Getenv("DATA_DIR")
+		if dataDir == "" {
+			dataDir = "./data"
+		}
+		cfg.Dir = filepath.Join(dataDir, "mail")
+	}
+	cfg.SMTP.Port, _ = strconv.Atoi(os.Getenv("SMTP_PORT"))
+	return cfg
+}
+
+// Default Способ отправки приложения (см. Init); по умолчанию - журнал
+var Default Mailer = &LogMailer{}
+
+// Init Настройка способа отправки по умолчанию
+func Init(cfg Config) error {
+	m, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	Default = m
+	log.Printf("Mailer: %s", cfg.Driver)
+	return nil
+}
+
+// New Создание способа отправки по настройкам
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Driver {
+	case DriverSMTP:
+		if cfg.SMTP.Host == "" {
+			return nil, fmt.Errorf("MAIL_DRIVER=smtp requires SMTP_HOST")
+		}
+		return NewSMTPMailer(cfg.SMTP, cfg.From)
+	case DriverFile:
+		return &FileMailer{Dir: cfg.Dir, From: cfg.From}, nil
+	case DriverLog:
+		return &LogMailer{}, nil
+	case DriverNone:
+		log.Println("Mail delivery is disabled: set SMTP_HOST or MAIL_DRIVER to send verification and password reset emails")
+		return NoneMailer{}, nil
+	}
+	return nil, fmt.Errorf("invalid MAIL_DRIVER %q (smtp, file, log, none)", cfg.Driver)
+}
+
+// linkToken Токен в ссылке письма
+var linkToken = regexp.MustCompile(`token=[^\s&]+`)
+
+// LogMailer Вывод писем в журнал (для разработки). Токены ссылок скрываются: журнал доступен
+// шире, чем почта получателя; для перехода по ссылкам используйте драйвер file
+type LogMailer struct{}
+
+// Send Запись письма в журнал
+func (LogMailer) Send(_ context.Context, msg Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, linkToken.ReplaceAllString(msg.Text, "token=[redacted]"))
+	return nil
+}
+
+// NoneMailer Отправка отключена
+type NoneMailer struct{}
+
+// Send Письмо не отправляется
+func (NoneMailer) Send(context.Context, Message) error {
+	return ErrMailDisabled
+}
@@ -0,0 +1,156 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPConfig Параметры SMTP сервера
+type SMTPConfig struct {
+	Host     string
+	Port     int // По умолчанию 587 (465 при TLS=tls)
+	Username string
+	Password string
+	// TLS Режим шифрования: starttls (по умолчанию), tls (сразу TLS, порт 465) или none
+	TLS string
+}
+
+// SMTPMailer Отправка писем через SMTP сервер
+type SMTPMailer struct {
+	cfg  SMTPConfig
+	from *mail.Address
+}
+
+// NewSMTPMailer Создание отправителя SMTP
+func NewSMTPMailer(cfg SMTPConfig, from string) (*SMTPMailer, error) {
+	addr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM: %w", err)
+	}
+	switch cfg.TLS {
+	case "":
+		cfg.TLS = "starttls"
+	case "starttls", "tls", "none":
+	default:
+		return nil, fmt.Errorf("invalid SMTP_TLS %q (starttls, tls, none)", cfg.TLS)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+		if cfg.TLS == "tls" {
+			cfg.Port = 465
+		}
+	}
+	return &SMTPMailer{cfg: cfg, from: addr}, nil
+}
+
+// Send Отправка письма
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	dialer := &net.Dialer{Timeout: 15 * time.Second}
+	tlsConfig := &tls.Config{ServerName: m.cfg.Host, MinVersion: tls.VersionTLS12}
+
+	var conn net.Conn
+	if m.cfg.TLS == "tls" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(time.Minute))
+	}
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if m.cfg.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("SMTP server does not support STARTTLS (set SMTP_TLS=none to send unencrypted)")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if m.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(m.from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(compose(m.from.String(), to.String(), msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// FileMailer Сохранение писем в каталог в формате .eml (для разработки и тестов)
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// Send Запись письма в файл
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0700); err != nil {
+		return err
+	}
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	name := time.Now().UTC().Format("20060102-150405.000000") + "-" + hex.EncodeToString(b) + ".eml"
+	return os.WriteFile(filepath.Join(m.Dir, name), compose(m.From, msg.To, msg), 0600)
+}
+
+// compose Текст письма с заголовками (UTF-8, 8bit)
+func compose(from, to string, msg Message) []byte {
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		buf.WriteString(k + ": " + strings.NewReplacer("\r", "", "\n", "").Replace(v) + "\r\n")
+	}
+	header("From", from)
+	header("To", to)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "8bit")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Text, "\r\n", "\n"), "\n", "\r\n"))
+	return buf.Bytes()
+}
@@ -1,11 +1,24 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/mihazzz123/siyuan-share/analytics"
+	"github.com/mihazzz123/siyuan-share/cli"
+	"github.com/mihazzz123/siyuan-share/jobs"
+	"github.com/mihazzz123/siyuan-share/keys"
+	"github.com/mihazzz123/siyuan-share/mailer"
 	"github.com/mihazzz123/siyuan-share/models"
+	"github.com/mihazzz123/siyuan-share/oidc"
+	"github.com/mihazzz123/siyuan-share/ratelimit"
 	"github.com/mihazzz123/siyuan-share/routes"
 	"github.com/gin-gonic/gin"
 )
@@ -14,18 +27,68 @@ import (
 var staticFiles embed.FS
 
 func main() {
+	os.Exit(cli.Run(os.Args[1:], serve))
+}
+
+// serve Запуск HTTP-сервера (команда serve, по умолчанию)
+func serve() {
 	// Инициализация базы данных
 	if err := models.InitDB(); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	// Удаление процесса токена инициализации: пользователи управляют токенами через регистрацию
+	// Ограничитель попыток ввода паролей (RATE_LIMIT_STORE=memory|sqlite)
+	if err := ratelimit.Init(models.DB); err != nil {
+		log.Fatalf("Failed to initialize rate limiter: %v", err)
+	}
+
+	// Правила регистрации (REGISTRATION_MODE, REGISTRATION_DOMAINS)
+	policy, err := models.LoadRegistrationPolicy()
+	if err != nil {
+		log.Fatalf("Invalid registration settings: %v", err)
+	}
+	models.Registration = policy
+
+	// Пока нет пользователей и регистрация не открыта, первый администратор регистрируется
+	// с одноразовым токеном инициализации из DATA_DIR/bootstrap_token.txt
+	if policy.Mode != models.RegistrationOpen {
+		if _, err := models.EnsureBootstrapToken(); err != nil {
+			log.Fatalf("Failed to create bootstrap token: %v", err)
+		}
+	}
 
 	// Настройка режима Gin
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// Ключи подписи JWT: сгенерированный ключ в DATA_DIR или SESSION_SECRET (в release слабый секрет - ошибка)
+	if err := keys.Init(keys.ConfigFromEnv(gin.Mode() == gin.ReleaseMode)); err != nil {
+		log.Fatalf("Failed to initialize JWT keys: %v", err)
+	}
+
+	// Вход через OpenID Connect (OIDC_CONFIG_FILE и/или OIDC_*), без настроек отключён
+	oidcCfg, err := oidc.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load OIDC config: %v", err)
+	}
+	oidc.Init(oidcCfg)
+
+	// Отправка писем (MAIL_DRIVER: smtp, file или log)
+	if err := mailer.Init(mailer.ConfigFromEnv(gin.Mode() == gin.ReleaseMode)); err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// Фоновые задачи останавливаются вместе с сервером по SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Окончательное удаление истёкших и удалённых публикаций
+	jobs.StartSweeper(ctx, jobs.SweeperConfigFromEnv())
+
+	// Фоновая запись просмотров публикаций (BOT_VIEWS=separate|count|ignore)
+	analytics.Start(analytics.ConfigFromEnv())
+
 	// Создание маршрутов
 	r := routes.SetupRouter(&staticFiles)
 
@@ -35,8 +98,21 @@ func main() {
 		port = "8088"
 	}
 
-	log.Printf("Server starting on port %s...", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		log.Printf("Server starting on port %s...", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown failed: %v", err)
 	}
+	// Запись просмотров, оставшихся в очереди
+	analytics.Stop()
 }
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+// Audit Запись события аудита для текущего запроса. Исполнитель, способ входа, IP и User-Agent
+// берутся из запроса (установлены AuthMiddleware); UserID по умолчанию - исполнитель
+func Audit(c *gin.Context, e models.AuditEvent) {
+	if e.ActorID == "" {
+		e.ActorID = c.GetString("userID")
+		e.ActorName = c.GetString("username")
+	}
+	if e.Via == "" {
+		if id := c.GetString("sessionID"); id != "" {
+			e.Via, e.Credential = models.AuditViaSession, id
+		} else if id := c.GetString("tokenID"); id != "" {
+			e.Via, e.Credential = models.AuditViaToken, id
+		}
+	}
+	e.IP = c.ClientIP()
+	e.UserAgent = c.Request.UserAgent()
+	models.RecordAudit(e)
+}
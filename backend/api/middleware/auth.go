@@ -1,22 +1,21 @@
 package middleware
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/mihazzz123/siyuan-share/keys"
 	"github.com/mihazzz123/siyuan-share/models"
 	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v5"
 )
 
 // AuthMiddleware Middleware аутентификации: поддерживает два способа
-// 1) Сессионный JWT (для состояния входа в Web)
+// 1) Сессионный JWT (для состояния входа в Web), обладает всеми областями действия пользователя
 // 2) Пользовательский API токен (таблица user_tokens, долгосрочный токен, для плагинов/CLI)
-func AuthMiddleware() gin.HandlerFunc {
+// required - области действия, которые должны быть у API токена (иначе 403)
+func AuthMiddleware(required ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -34,23 +33,46 @@ func AuthMiddleware() gin.HandlerFunc {
 		raw := strings.TrimSpace(parts[1])
 
 		// Сначала попытка парсинга как сессионный JWT токен
-		if userID, ok := parseJWT(raw); ok {
-			c.Set("userID", userID)
+		if userID, sessionID, ok := parseJWT(raw); ok {
+			// Сессия должна существовать и не быть отозванной, пользователь - активным
+			user, err := models.ValidateSession(sessionID, userID)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Session invalid: " + err.Error()})
+				c.Abort()
+				return
+			}
+			c.Set("userID", user.ID)
+			c.Set("username", user.Username)
+			c.Set("sessionID", sessionID)
+			c.Set("isAdmin", user.IsAdmin)
+			c.Set("scopes", models.UserScopes(user))
 			c.Next()
 			return
 		}
 
 		// Откат к API токену: поиск в таблице user_tokens
-		hash := sha256.Sum256([]byte(raw))
-		tokenHash := hex.EncodeToString(hash[:])
-
-		var ut models.UserToken
-		if err := models.DB.Where("token_hash = ? AND revoked = ?", tokenHash, false).First(&ut).Error; err != nil {
+		ut, usedPrev, err := models.FindUsableToken(raw)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Invalid or revoked token"})
 			c.Abort()
 			return
 		}
 
+		// Политика токена: срок действия и разрешённые диапазоны IP
+		now := time.Now()
+		if ut.IsExpired(now) {
+			auditTokenRejected(c, ut, "expired")
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "Token expired"})
+			c.Abort()
+			return
+		}
+		if !ut.AllowsIP(c.ClientIP()) {
+			auditTokenRejected(c, ut, "ip not allowed")
+			c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Token is not allowed from this IP address"})
+			c.Abort()
+			return
+		}
+
 		// Проверка доступности пользователя
 		var user models.User
 		if err := models.DB.Where("id = ? AND is_active = ?", ut.UserID, true).First(&user).Error; err != nil {
@@ -59,41 +81,124 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Проверка областей действия токена
+		scopes := ut.ScopeList()
+		if !models.HasScopes(scopes, required...) {
+			auditTokenRejected(c, ut, "insufficient scope, required: "+strings.Join(required, " "))
+			c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Insufficient token scope, required: " + strings.Join(required, " ")})
+			c.Abort()
+			return
+		}
+
+		// Автоматическая ротация: новый текст токена возвращается в заголовке X-Rotated-Token,
+		// прежний действует ещё TOKEN_ROTATION_GRACE
+		if !usedPrev && ut.RotationDue(now) {
+			if rotated, err := models.RotateToken(ut); err == nil && rotated != "" {
+				c.Header("X-Rotated-Token", rotated)
+				Audit(c, models.AuditEvent{
+					ActorID: user.ID, ActorName: user.Username, Via: models.AuditViaToken, Credential: ut.ID,
+					Action: models.AuditTokenRotate, TargetType: "token", TargetID: ut.ID,
+				})
+			}
+		}
+		if ut.ExpiresAt != nil {
+			c.Header("X-Token-Expires-At", ut.ExpiresAt.UTC().Format(time.RFC3339))
+		}
+
 		// Обновление последнего использованиевремени（без блокировкиосновного потока）
-		now := time.Now()
-		models.DB.Model(&ut).Update("last_used_at", &now)
+		models.DB.Model(ut).UpdateColumn("last_used_at", &now)
 
 		c.Set("userID", user.ID)
 		c.Set("username", user.Username)
+		c.Set("tokenID", ut.ID)
+		c.Set("token", ut)
+		c.Set("isAdmin", user.IsAdmin)
+		c.Set("scopes", scopes)
 		c.Next()
 	}
 }
 
-func parseJWT(tokenString string) (string, bool) {
-	if strings.Count(tokenString, ".") != 2 {
-		return "", false
+// auditTokenRejected Запись отказа в доступе по действующему API токену
+func auditTokenRejected(c *gin.Context, ut *models.UserToken, reason string) {
+	Audit(c, models.AuditEvent{
+		ActorID: ut.UserID, Via: models.AuditViaToken, Credential: ut.ID,
+		Action: models.AuditTokenRejected, TargetType: "token", TargetID: ut.ID,
+		Result: models.AuditDenied, Detail: reason,
+	})
+}
+
+// AdminRequired Доступ только для администраторов; ставится после AuthMiddleware
+func AdminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("isAdmin") {
+			c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "Administrator access required"})
+			c.Abort()
+			return
+		}
+		c.Next()
 	}
-	secret := os.Getenv("SESSION_SECRET")
-	if secret == "" {
-		secret = "dev-secret"
+}
+
+// SessionRequired Доступ только из сессии Web, не по API токену с любыми областями действия;
+// ставится после AuthMiddleware
+func SessionRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("sessionID") == "" {
+			c.JSON(http.StatusForbidden, gin.H{"code": 1, "msg": "This action requires a web session"})
+			c.Abort()
+			return
+		}
+		c.Next()
 	}
-	tok, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		// Использование метода HMAC по умолчанию
-		return []byte(secret), nil
-	}, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}))
+}
+
+// Token API токен текущего запроса (nil - сессия Web)
+func Token(c *gin.Context) *models.UserToken {
+	if v, ok := c.Get("token"); ok {
+		if ut, ok := v.(*models.UserToken); ok {
+			return ut
+		}
+	}
+	return nil
+}
+
+// Scopes Области действия текущего запроса (установлены AuthMiddleware)
+func Scopes(c *gin.Context) []string {
+	if v, ok := c.Get("scopes"); ok {
+		if scopes, ok := v.([]string); ok {
+			return scopes
+		}
+	}
+	return nil
+}
+
+// parseJWT Проверка access JWT сессии, возврат ID пользователя и ID сессии (claim sid).
+// Токены без sid (выданные до появления сессий) не принимаются.
+func parseJWT(tokenString string) (string, string, bool) {
+	if strings.Count(tokenString, ".") != 2 {
+		return "", "", false
+	}
+	// Подпись проверяется ключом из заголовка kid (см. пакет keys)
+	tok, err := keys.Default.Parse(tokenString, jwt.MapClaims{})
 	if err != nil || !tok.Valid {
-		return "", false
+		return "", "", false
 	}
 	if claims, ok := tok.Claims.(jwt.MapClaims); ok {
 		// Проверка срока действия
 		if exp, has := claims["exp"].(float64); has {
 			if time.Unix(int64(exp), 0).Before(time.Now()) {
-				return "", false
+				return "", "", false
 			}
 		}
-		if sub, has := claims["sub"].(string); has && sub != "" {
-			return sub, true
+		// Токены просмотра публикаций (aud share-view) не являются сессиями
+		if aud, _ := claims.GetAudience(); len(aud) > 0 {
+			return "", "", false
+		}
+		sub, _ := claims["sub"].(string)
+		sid, _ := claims["sid"].(string)
+		if sub != "" && sid != "" {
+			return sub, sid, true
 		}
 	}
-	return "", false
+	return "", "", false
 }
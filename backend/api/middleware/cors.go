@@ -17,8 +17,10 @@ func CORSMiddleware() gin.HandlerFunc {
 
 		// Схема Bearer Token обычно не требует Credentials
 		// Если в будущем потребуется передача Cookie, это можно включить для конкретных маршрутов：c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Authorization, X-Base-URL, X-Bootstrap-Token")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Authorization, X-Base-URL, X-Bootstrap-Token, X-Referrer")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		// Заголовки политики API токенов доступны клиенту (плагину)
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Rotated-Token, X-Token-Expires-At")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UpdateProfile Смена имени пользователя и email (пустое значение - без изменений) с проверкой
+// уникальности и разрешённых доменов email. При смене email подтверждение сбрасывается;
+// emailChanged сообщает, что нужно отправить новое письмо подтверждения
+func UpdateProfile(userID, username, email string) (user *User, emailChanged bool, err error) {
+	user = &User{}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", userID).First(user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		updates := map[string]interface{}{}
+		if username != "" && username != user.Username {
+			updates["username"] = username
+		}
+		if email != "" && !strings.EqualFold(email, user.Email) {
+			if !user.IsAdmin && !Registration.EmailAllowed(email) {
+				return ErrEmailDomainNotAllowed
+			}
+			updates["email"] = email
+			updates["email_verified_at"] = nil
+			emailChanged = true
+		} else if email != "" && email != user.Email {
+			updates["email"] = email // Изменён только регистр, подтверждение сохраняется
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		if v, ok := updates["username"]; ok {
+			user.Username = v.(string)
+		}
+		if v, ok := updates["email"]; ok {
+			user.Email = v.(string)
+		}
+
+		var dup int64
+		if err := tx.Unscoped().Model(&User{}).
+			Where("id != ? AND (username = ? OR LOWER(email) = ?)", userID, user.Username, strings.ToLower(user.Email)).
+			Count(&dup).Error; err != nil {
+			return err
+		}
+		if dup > 0 {
+			return ErrUserExists
+		}
+		if err := tx.Model(&User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", userID).First(user).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		err = ErrUserExists
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return user, emailChanged, nil
+}
+
+// ChangePassword Новый пароль (хэш) пользователя: все сессии, кроме keepSessionID, завершаются,
+// API токены отзываются
+func ChangePassword(userID, passwordHash, keepSessionID string) (sessions, tokens int64, err error) {
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&User{}).Where("id = ?", userID).Update("password_hash", passwordHash)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+		sessions, tokens, err = revokeCredentials(tx, userID, keepSessionID)
+		return err
+	})
+	return sessions, tokens, err
+}
+
+// revokeCredentials Завершение сессий пользователя, кроме keepSessionID (пусто - всех), и отзыв
+// всех его API токенов; используется при любой смене пароля. Возвращает число отозванных
+func revokeCredentials(tx *gorm.DB, userID, keepSessionID string) (sessions, tokens int64, err error) {
+	res := tx.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL AND id != ?", userID, keepSessionID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return 0, 0, res.Error
+	}
+	sessions = res.RowsAffected
+	res = tx.Model(&UserToken{}).Where("user_id = ? AND revoked = ?", userID, false).Update("revoked", true)
+	return sessions, res.RowsAffected, res.Error
+}
+
+// DeleteAccount Удаление пользователя вместе с публикациями (DeleteSharesByUser, окончательно их
+// удалит фоновая очистка), API токенами, сессиями, резервными кодами, связями OIDC и токенами из
+// писем. Запись пользователя удаляется окончательно, чтобы имя и email можно было занять снова.
+// Последнего активного администратора удалить нельзя. Возвращает число удалённых публикаций
+func DeleteAccount(userID string) (int64, error) {
+	user, err := FindUser(userID)
+	if err != nil {
+		return 0, err
+	}
+	if last, err := lastAdmin(DB, user); err != nil || last {
+		if err == nil {
+			err = ErrLastAdmin
+		}
+		return 0, err
+	}
+
+	// Публикации удаляются первыми: при ошибке дальше аккаунт остаётся, но без публичных ссылок
+	shares, err := DeleteSharesByUser(user.ID)
+	if err != nil {
+		return 0, err
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&UserToken{}, &Session{}, &RecoveryCode{}, &UserIdentity{}, &EmailToken{}} {
+			if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(user).Error
+	})
+	return shares, err
+}
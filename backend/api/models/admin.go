@@ -0,0 +1,274 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrShareNotFound = errors.New("share not found")
+	ErrLastAdmin     = errors.New("the last active administrator cannot be disabled or demoted")
+)
+
+// UserSummary Пользователь с числом публикаций и действующих API токенов (для администратора)
+type UserSummary struct {
+	User
+	ShareCount int64 `json:"shareCount"`
+	TokenCount int64 `json:"tokenCount"`
+}
+
+// UserFilter Условия поиска пользователей
+type UserFilter struct {
+	Query  string // Подстрока имени пользователя или email
+	Active *bool
+	Admin  *bool
+}
+
+// ListUsers Страница пользователей, новые первыми
+func ListUsers(f UserFilter, offset, limit int) ([]UserSummary, int64, error) {
+	q := DB.Model(&User{})
+	if s := strings.ToLower(strings.TrimSpace(f.Query)); s != "" {
+		like := "%" + s + "%"
+		q = q.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ? OR id = ?", like, like, f.Query)
+	}
+	if f.Active != nil {
+		q = q.Where("is_active = ?", *f.Active)
+	}
+	if f.Admin != nil {
+		q = q.Where("is_admin = ?", *f.Admin)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []UserSummary
+	err := q.Select("users.*," +
+		" (SELECT COUNT(*) FROM shares WHERE shares.user_id = users.id AND shares.deleted_at IS NULL) AS share_count," +
+		" (SELECT COUNT(*) FROM user_tokens WHERE user_tokens.user_id = users.id AND user_tokens.revoked = false AND user_tokens.deleted_at IS NULL) AS token_count").
+		Order("created_at DESC").Offset(offset).Limit(limit).
+		Find(&list).Error
+	return list, total, err
+}
+
+// FindUser Поиск пользователя по ID, имени или email (без учёта регистра); совпадение ID или
+// имени важнее совпадения email
+func FindUser(idOrName string) (*User, error) {
+	var user User
+	res := DB.Where("id = ? OR username = ?", idOrName, idOrName).Limit(1).Find(&user)
+	if res.Error == nil && res.RowsAffected == 0 && idOrName != "" {
+		res = DB.Where("LOWER(email) = ?", strings.ToLower(idOrName)).Limit(1).Find(&user)
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+// lastAdmin Единственный ли это активный администратор
+func lastAdmin(tx *gorm.DB, user *User) (bool, error) {
+	if !user.IsAdmin || !user.IsActive {
+		return false, nil
+	}
+	var admins int64
+	err := tx.Model(&User{}).Where("is_admin = ? AND is_active = ? AND id != ?", true, true, user.ID).Count(&admins).Error
+	return admins == 0, err
+}
+
+// SetUserActive Включение или отключение пользователя; при отключении завершаются его сессии.
+// Последнего активного администратора отключить нельзя
+func SetUserActive(userID string, active bool) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		if !active {
+			if last, err := lastAdmin(tx, &user); err != nil || last {
+				if err == nil {
+					err = ErrLastAdmin
+				}
+				return err
+			}
+		}
+		if err := tx.Model(&user).UpdateColumn("is_active", active).Error; err != nil {
+			return err
+		}
+		if active {
+			return nil
+		}
+		return tx.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", time.Now()).Error
+	})
+}
+
+// SetUserAdmin Назначение или снятие роли администратора; последнего активного администратора снять нельзя
+func SetUserAdmin(userID string, admin bool) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		if !admin {
+			if last, err := lastAdmin(tx, &user); err != nil || last {
+				if err == nil {
+					err = ErrLastAdmin
+				}
+				return err
+			}
+		}
+		return tx.Model(&user).UpdateColumn("is_admin", admin).Error
+	})
+}
+
+// SetUserPassword Установка нового пароля (хэша) администратором: все сессии пользователя
+// завершаются, API токены отзываются. Возвращает число завершённых сессий и отозванных токенов
+func SetUserPassword(userID, passwordHash string) (sessions, tokens int64, err error) {
+	return ChangePassword(userID, passwordHash, "")
+}
+
+// ShareSummary Публикация с именем владельца (без содержимого)
+type ShareSummary struct {
+	ID              string    `json:"id"`
+	UserID          string    `json:"userId"`
+	Username        string    `json:"username"`
+	DocID           string    `json:"docId"`
+	DocTitle        string    `json:"docTitle"`
+	ParentShareID   string    `json:"parentShareId,omitempty"`
+	RequirePassword bool      `json:"requirePassword"`
+	IsPublic        bool      `json:"isPublic"`
+	ExpireAt        time.Time `json:"expireAt"`
+	ViewCount       int       `json:"viewCount"`
+	Revision        int       `json:"revision"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// ShareFilter Условия поиска публикаций
+type ShareFilter struct {
+	Query  string // Подстрока заголовка, ID публикации или документа
+	UserID string
+}
+
+// ListAllShares Страница публикаций всех пользователей, новые первыми
+func ListAllShares(f ShareFilter, offset, limit int) ([]ShareSummary, int64, error) {
+	q := DB.Model(&Share{}).Joins("LEFT JOIN users ON users.id = shares.user_id")
+	if s := strings.TrimSpace(f.Query); s != "" {
+		q = q.Where("LOWER(shares.doc_title) LIKE ? OR shares.id = ? OR shares.doc_id = ?", "%"+strings.ToLower(s)+"%", s, s)
+	}
+	if f.UserID != "" {
+		q = q.Where("shares.user_id = ?", f.UserID)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []ShareSummary
+	err := q.Select("shares.id, shares.user_id, users.username, shares.doc_id, shares.doc_title, shares.parent_share_id," +
+		" shares.require_password, shares.is_public, shares.expire_at, shares.view_count, shares.revision, shares.created_at, shares.updated_at").
+		Order("shares.created_at DESC").Offset(offset).Limit(limit).
+		Scan(&list).Error
+	return list, total, err
+}
+
+// FindShare Публикация по ID (без удалённых)
+func FindShare(id string) (*Share, error) {
+	var share Share
+	res := DB.Where("id = ?", id).Limit(1).Find(&share)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrShareNotFound
+	}
+	return &share, nil
+}
+
+// DeleteShareByID Мягкое удаление публикации любого пользователя вместе с публикациями её ссылаемых блоков;
+// окончательно удаляется фоновой очисткой через SWEEP_GRACE
+func DeleteShareByID(id string) (int64, error) {
+	res := DB.Where("id = ? OR parent_share_id = ?", id, id).Delete(&Share{})
+	if res.Error == nil && res.RowsAffected == 0 {
+		return 0, ErrShareNotFound
+	}
+	return res.RowsAffected, res.Error
+}
+
+// InstanceStats Статистика экземпляра
+type InstanceStats struct {
+	Users struct {
+		Total    int64 `json:"total"`
+		Active   int64 `json:"active"`
+		Admins   int64 `json:"admins"`
+		Verified int64 `json:"verified"`
+		TOTP     int64 `json:"totp"`
+	} `json:"users"`
+	Shares struct {
+		Total     int64 `json:"total"`
+		Public    int64 `json:"public"`
+		Protected int64 `json:"protected"` // С паролем
+		Expired   int64 `json:"expired"`
+		Deleted   int64 `json:"deleted"` // Ожидают окончательного удаления
+		Views     int64 `json:"views"`
+		Revisions int64 `json:"revisions"`
+	} `json:"shares"`
+	Tokens struct {
+		Active int64 `json:"active"`
+	} `json:"tokens"`
+	Sessions struct {
+		Active int64 `json:"active"`
+	} `json:"sessions"`
+	DatabaseBytes int64 `json:"databaseBytes"`
+}
+
+// GetInstanceStats Сбор статистики экземпляра
+func GetInstanceStats() (*InstanceStats, error) {
+	var st InstanceStats
+	now := time.Now()
+	counts := []struct {
+		q   *gorm.DB
+		dst *int64
+	}{
+		{DB.Model(&User{}), &st.Users.Total},
+		{DB.Model(&User{}).Where("is_active = ?", true), &st.Users.Active},
+		{DB.Model(&User{}).Where("is_admin = ?", true), &st.Users.Admins},
+		{DB.Model(&User{}).Where("email_verified_at IS NOT NULL"), &st.Users.Verified},
+		{DB.Model(&User{}).Where("totp_enabled = ?", true), &st.Users.TOTP},
+		{DB.Model(&Share{}), &st.Shares.Total},
+		{DB.Model(&Share{}).Where("is_public = ?", true), &st.Shares.Public},
+		{DB.Model(&Share{}).Where("require_password = ?", true), &st.Shares.Protected},
+		{DB.Model(&Share{}).Where("expire_at < ?", now), &st.Shares.Expired},
+		{DB.Unscoped().Model(&Share{}).Where("deleted_at IS NOT NULL"), &st.Shares.Deleted},
+		{DB.Model(&ShareRevision{}), &st.Shares.Revisions},
+		{DB.Model(&UserToken{}).Where("revoked = ? AND (expires_at IS NULL OR expires_at > ?)", false, now), &st.Tokens.Active},
+		{DB.Model(&Session{}).Where("revoked_at IS NULL AND expires_at > ?", now), &st.Sessions.Active},
+	}
+	for _, c := range counts {
+		if err := c.q.Count(c.dst).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := DB.Model(&Share{}).Select("COALESCE(SUM(view_count), 0)").Scan(&st.Shares.Views).Error; err != nil {
+		return nil, err
+	}
+	st.DatabaseBytes = databaseSize()
+	return &st, nil
+}
+
+// databaseSize Размер базы данных по страницам SQLite (без WAL)
+func databaseSize() int64 {
+	var size int64
+	DB.Raw("SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()").Scan(&size)
+	return size
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCreateUserDuplicate(t *testing.T) {
+	setupTestDB(t)
+	if err := CreateUser(&User{ID: NewUserID(), Username: "alice", Email: "alice@example.com", IsActive: true}); err != nil {
+		t.Fatal(err)
+	}
+	err := CreateUser(&User{ID: NewUserID(), Username: "alice", Email: "other@example.com", IsActive: true})
+	if !errors.Is(err, ErrUserExists) {
+		t.Fatalf("err = %v, want ErrUserExists", err)
+	}
+}
+
+func TestFindUser(t *testing.T) {
+	setupTestDB(t)
+	alice := User{ID: NewUserID(), Username: "alice", Email: "Alice@Example.com", IsActive: true}
+	if err := CreateUser(&alice); err != nil {
+		t.Fatal(err)
+	}
+	// Имя одного пользователя совпадает с email другого: имя важнее
+	bob := User{ID: NewUserID(), Username: "alice@example.com", Email: "bob@example.com", IsActive: true}
+	if err := CreateUser(&bob); err != nil {
+		t.Fatal(err)
+	}
+
+	for query, want := range map[string]string{
+		alice.ID:            alice.ID,
+		"alice":             alice.ID,
+		"ALICE@example.COM": alice.ID,
+		"alice@example.com": bob.ID,
+		"Bob@Example.com":   bob.ID,
+	} {
+		user, err := FindUser(query)
+		if err != nil {
+			t.Fatalf("FindUser(%q): %v", query, err)
+		}
+		if user.ID != want {
+			t.Fatalf("FindUser(%q) = %s, want %s", query, user.ID, want)
+		}
+	}
+	if _, err := FindUser("carol@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("unknown email: err = %v, want ErrUserNotFound", err)
+	}
+}
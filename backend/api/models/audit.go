@@ -0,0 +1,163 @@
+package models
+
+import (
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+// Действия журнала аудита
+const (
+	AuditLogin          = "auth.login"
+	AuditLoginFailed    = "auth.login_failed"
+	AuditRegister       = "auth.register"
+	AuditLogout         = "auth.logout"
+	AuditLogoutAll      = "auth.logout_all"
+	AuditSessionRevoke  = "session.revoke"
+	AuditRefreshReuse   = "session.refresh_reuse" // Повторное использование refresh токена
+	AuditPasswordChange = "user.password_change"
+	AuditPasswordReset  = "user.password_reset"
+	AuditProfileUpdate  = "user.profile_update"
+	AuditEmailVerify    = "user.email_verify"
+	AuditAccountDelete  = "user.delete"
+	AuditDataExport     = "user.export"
+	Audit2FAEnable      = "user.2fa_enable"
+	Audit2FADisable     = "user.2fa_disable"
+	Audit2FARecovery    = "user.2fa_recovery_regenerate"
+	AuditTokenCreate    = "token.create"
+	AuditTokenRevoke    = "token.revoke"
+	AuditTokenRefresh   = "token.refresh"
+	AuditTokenRotate    = "token.rotate"   // Автоматическая ротация при использовании
+	AuditTokenRejected  = "token.rejected" // Найденный токен отклонён политикой или областями действия
+	AuditShareDelete    = "share.delete"
+	AuditShareBatch     = "share.delete_batch"
+	AuditShareImport    = "share.import"
+	AuditUnlockFailed   = "share.unlock_failed"
+	AuditAdminUser      = "admin.user_update"
+	AuditAdminPassword  = "admin.password_reset"
+	AuditAdminToken     = "admin.token_revoke"
+	AuditAdminShare     = "admin.share_delete"
+	AuditInviteCreate   = "invite.create"
+	AuditInviteRevoke   = "invite.revoke"
+)
+
+// Результаты действий журнала аудита
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure" // Неверные учётные данные или ошибка выполнения
+	AuditDenied  = "denied"  // Отказ политики доступа
+)
+
+// Способы выполнения действия
+const (
+	AuditViaSession = "session"
+	AuditViaToken   = "token"
+	AuditViaCLI     = "cli"
+)
+
+// AuditEvent Запись журнала аудита. Таблица только дополняется: изменение записей запрещено
+// триггером, удаление выполняет лишь очистка по сроку хранения (AUDIT_RETENTION)
+type AuditEvent struct {
+	ID         int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	CreatedAt  time.Time `gorm:"index" json:"createdAt"`
+	UserID     string    `gorm:"index" json:"userId"`  // Аккаунт, к которому относится событие
+	ActorID    string    `gorm:"index" json:"actorId"` // Кто выполнил действие (пусто - аноним)
+	ActorName  string    `json:"actorName"`
+	Via        string    `json:"via,omitempty"`        // session, token или cli
+	Credential string    `json:"credential,omitempty"` // ID сессии или API токена
+	Action     string    `gorm:"index" json:"action"`
+	TargetType string    `json:"targetType,omitempty"` // user, token, session, share, invite
+	TargetID   string    `json:"targetId,omitempty"`
+	Result     string    `gorm:"index" json:"result"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `gorm:"size:255" json:"userAgent"`
+	Detail     string    `gorm:"size:1024" json:"detail,omitempty"`
+}
+
+// RecordAudit Запись события аудита. Ошибка записи только логируется и не прерывает запрос
+func RecordAudit(e AuditEvent) {
+	if e.Result == "" {
+		e.Result = AuditSuccess
+	}
+	if e.UserID == "" {
+		e.UserID = e.ActorID
+	}
+	e.ID = 0
+	e.CreatedAt = time.Now()
+	e.UserAgent = truncate(e.UserAgent, 255)
+	e.Detail = truncate(e.Detail, 1024)
+	if err := DB.Create(&e).Error; err != nil {
+		log.Printf("Failed to record audit event %s: %v", e.Action, err)
+	}
+}
+
+// truncate Обрезка строки до max байт без разрыва символа UTF-8
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	s = s[:max]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// AuditFilter Условия выборки журнала аудита
+type AuditFilter struct {
+	UserID  string // События аккаунта: относящиеся к нему или выполненные им
+	ActorID string
+	Action  string // Действие или группа действий с точкой в конце ("token.")
+	Result  string
+	Since   *time.Time
+	Until   *time.Time
+}
+
+// ListAuditEvents Страница событий аудита, новые первыми
+func ListAuditEvents(f AuditFilter, offset, limit int) ([]AuditEvent, int64, error) {
+	q := DB.Model(&AuditEvent{})
+	if f.UserID != "" {
+		q = q.Where("user_id = ? OR actor_id = ?", f.UserID, f.UserID)
+	}
+	if f.ActorID != "" {
+		q = q.Where("actor_id = ?", f.ActorID)
+	}
+	if f.Action != "" {
+		if f.Action[len(f.Action)-1] == '.' {
+			q = q.Where("action LIKE ?", f.Action+"%")
+		} else {
+			q = q.Where("action = ?", f.Action)
+		}
+	}
+	if f.Result != "" {
+		q = q.Where("result = ?", f.Result)
+	}
+	if f.Since != nil {
+		q = q.Where("created_at >= ?", *f.Since)
+	}
+	if f.Until != nil {
+		q = q.Where("created_at < ?", *f.Until)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	list := []AuditEvent{}
+	err := q.Order("id DESC").Offset(offset).Limit(limit).Find(&list).Error
+	return list, total, err
+}
+
+// PurgeAuditEvents Удаление событий аудита старше cutoff
+func PurgeAuditEvents(cutoff time.Time) (int64, error) {
+	res := DB.Where("created_at < ?", cutoff).Delete(&AuditEvent{})
+	return res.RowsAffected, res.Error
+}
+
+// ensureAuditAppendOnly Триггер, запрещающий изменение записей журнала аудита
+func ensureAuditAppendOnly() error {
+	return DB.Exec(`CREATE TRIGGER IF NOT EXISTS audit_events_append_only
+		BEFORE UPDATE ON audit_events
+		BEGIN
+			SELECT RAISE(ABORT, 'audit_events is append-only');
+		END`).Error
+}
@@ -33,8 +33,11 @@ func EnsureBootstrapToken() (*BootstrapToken, error) {
 
 	// Поиск неиспользованных и неистекших токенов
 	var bt BootstrapToken
-	err := DB.Where("used = ? AND expires_at > ?", false, time.Now()).First(&bt).Error
-	if err == nil {
+	res := DB.Where("used = ? AND expires_at > ?", false, time.Now()).Limit(1).Find(&bt)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected > 0 {
 		return &bt, nil
 	}
 
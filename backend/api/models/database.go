@@ -41,11 +41,16 @@ func InitDB() error {
 	default:
 		gormLogger = logger.Default.LogMode(logger.Warn)
 	}
-	config := &gorm.Config{Logger: gormLogger}
+	// TranslateError: нарушения уникальности возвращаются как gorm.ErrDuplicatedKey
+	config := &gorm.Config{Logger: gormLogger, TranslateError: true}
 
 	// Использование драйвера glebarez/sqlite для подключения
 	var err error
-	DB, err = gorm.Open(sqlite.Open(dbPath), config)
+	// busy_timeout: ожидание блокировки вместо немедленной ошибки SQLITE_BUSY;
+	// _txlock=immediate: транзакции сразу берут блокировку записи, поэтому параллельные
+	// публикации выполняются последовательно, а не конфликтуют при COMMIT
+	dsn := dbPath + "?_pragma=busy_timeout(5000)&_txlock=immediate"
+	DB, err = gorm.Open(sqlite.Open(dsn), config)
 	if err != nil {
 		return err
 	}
@@ -55,6 +60,36 @@ func InitDB() error {
 		return err
 	}
 
+	// Одна неудалённая публикация на документ пользователя
+	if err := ensureShareUniqueIndex(); err != nil {
+		return err
+	}
+
+	// Журнал аудита только дополняется
+	if err := ensureAuditAppendOnly(); err != nil {
+		return err
+	}
+
+	// Области действия для токенов, созданных до их появления
+	if err := migrateTokenScopes(); err != nil {
+		return err
+	}
+
+	// Администратор для экземпляров, созданных до появления ролей
+	if err := ensureAdmin(); err != nil {
+		return err
+	}
+
+	// Перенос ссылаемых блоков из JSON в таблицу share_references
+	if err := migrateLegacyReferences(); err != nil {
+		return err
+	}
+
+	// Перенос существующих публикаций в историю версий
+	if err := backfillShareRevisions(); err != nil {
+		return err
+	}
+
 	// Настройки PRAGMA для оптимизации производительности (SQLite)
 	applySQLiteOptimizations()
 
@@ -66,8 +101,20 @@ func InitDB() error {
 func autoMigrate() error {
 	return DB.AutoMigrate(
 		&Share{},
+		&ShareRevision{},
+		&ShareReference{},
 		&User{},
 		&UserToken{},
+		&Session{},
+		&RecoveryCode{},
+		&UserIdentity{},
+		&Invite{},
+		&EmailToken{},
+		&AuditEvent{},
+		&ShareViewEvent{},
+		&ShareViewDaily{},
+		&ShareViewBreakdown{},
+		&ViewSalt{},
 		&BootstrapToken{}, // Совместимость со старыми данными, может быть удалено позже
 	)
 }
@@ -0,0 +1,18 @@
+package models
+
+import "testing"
+
+// setupTestDB Чистая база в DATA_DIR во временном каталоге теста
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("SQLITE_LOG_MODE", "silent")
+	if err := InitDB(); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if db, err := DB.DB(); err == nil {
+			db.Close()
+		}
+	})
+}
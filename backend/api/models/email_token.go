@@ -0,0 +1,150 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Назначения одноразовых токенов из писем
+const (
+	PurposeVerifyEmail   = "verify-email"
+	PurposeResetPassword = "reset-password"
+)
+
+// ErrEmailTokenInvalid Токен не найден, уже использован, заменён новым или истёк
+var ErrEmailTokenInvalid = errors.New("token is invalid, used or expired")
+
+// EmailToken Одноразовый токен из письма; в письме передаётся подписанный JWT с jti = ID
+type EmailToken struct {
+	ID        string     `gorm:"primaryKey;size:64" json:"id"`
+	UserID    string     `gorm:"size:64;index" json:"userId"`
+	Purpose   string     `gorm:"size:32" json:"purpose"`
+	Email     string     `gorm:"size:255" json:"email"` // Адрес, на который отправлено письмо
+	ExpiresAt time.Time  `gorm:"index" json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// TableName Указание имени таблицы
+func (EmailToken) TableName() string {
+	return "email_tokens"
+}
+
+// EmailVerifyTTL Срок действия ссылки подтверждения email (EMAIL_VERIFY_TTL, по умолчанию 48h)
+func EmailVerifyTTL() time.Duration {
+	return envDuration("EMAIL_VERIFY_TTL", 48*time.Hour)
+}
+
+// PasswordResetTTL Срок действия ссылки сброса пароля (PASSWORD_RESET_TTL, по умолчанию 1h)
+func PasswordResetTTL() time.Duration {
+	return envDuration("PASSWORD_RESET_TTL", time.Hour)
+}
+
+// IssueEmailToken Новый токен; прежние неиспользованные токены того же назначения перестают действовать
+func IssueEmailToken(user *User, purpose string, ttl time.Duration) (*EmailToken, error) {
+	now := time.Now()
+	t := &EmailToken{
+		ID:        "et_" + randomHex(16),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		ExpiresAt: now.Add(ttl),
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&EmailToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, purpose).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(t).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// redeemEmailToken Использование токена (условное обновление: повторное использование невозможно)
+func redeemEmailToken(tx *gorm.DB, id, purpose string) (*EmailToken, error) {
+	now := time.Now()
+	res := tx.Model(&EmailToken{}).
+		Where("id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", id, purpose, now).
+		Update("used_at", now)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrEmailTokenInvalid
+	}
+	var t EmailToken
+	if err := tx.Where("id = ?", id).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// VerifyEmail Подтверждение email по токену; адрес пользователя не должен был измениться после отправки
+func VerifyEmail(tokenID string) (*User, error) {
+	var user User
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		t, err := redeemEmailToken(tx, tokenID, PurposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", t.UserID).First(&user).Error; err != nil {
+			return err
+		}
+		if !strings.EqualFold(user.Email, t.Email) {
+			return ErrEmailTokenInvalid
+		}
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		return tx.Model(&user).UpdateColumn("email_verified_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ResetPassword Смена пароля по токену из письма: все сессии пользователя завершаются, API токены
+// отзываются (как при ChangePassword), email считается подтверждённым (письмо получено).
+// Возвращает пользователя и число завершённых сессий и отозванных токенов
+func ResetPassword(tokenID, passwordHash string) (user *User, sessions, tokens int64, err error) {
+	user = &User{}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		t, err := redeemEmailToken(tx, tokenID, PurposeResetPassword)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("id = ? AND is_active = ?", t.UserID, true).First(user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEmailTokenInvalid
+			}
+			return err
+		}
+		now := time.Now()
+		updates := map[string]interface{}{"password_hash": passwordHash}
+		if user.EmailVerifiedAt == nil && strings.EqualFold(user.Email, t.Email) {
+			updates["email_verified_at"] = now
+		}
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		sessions, tokens, err = revokeCredentials(tx, user.ID, "")
+		return err
+	})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return user, sessions, tokens, nil
+}
+
+// PurgeEmailTokens Удаление токенов, истёкших раньше cutoff
+func PurgeEmailTokens(cutoff time.Time) (int64, error) {
+	res := DB.Where("expires_at < ?", cutoff).Delete(&EmailToken{})
+	return res.RowsAffected, res.Error
+}
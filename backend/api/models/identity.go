@@ -0,0 +1,143 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrExternalEmailNotVerified Провайдер не подтвердил email, связать учётную запись нельзя
+	ErrExternalEmailNotVerified = errors.New("email is not verified by the identity provider")
+	// ErrExternalAccountNotFound Нет учётной записи с таким email, а автосоздание отключено
+	ErrExternalAccountNotFound = errors.New("no account matches this identity")
+)
+
+// UserIdentity Связь пользователя с учётной записью внешнего провайдера (issuer + subject)
+type UserIdentity struct {
+	ID          string     `gorm:"primaryKey;size:64" json:"id"`
+	UserID      string     `gorm:"size:64;index" json:"userId"`
+	Issuer      string     `gorm:"size:255;uniqueIndex:idx_identity_subject,priority:1" json:"issuer"`
+	Subject     string     `gorm:"size:255;uniqueIndex:idx_identity_subject,priority:2" json:"subject"`
+	Email       string     `gorm:"size:255" json:"email"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// TableName Указание имени таблицы
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+// ExternalIdentity Проверенные данные пользователя от внешнего провайдера
+type ExternalIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string // Желаемое имя при автосоздании (preferred_username или часть email)
+}
+
+// usernameUnsafe Символы, недопустимые в имени пользователя при автосоздании
+var usernameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// LoginExternalIdentity Поиск пользователя по внешней учётной записи: сначала по связке issuer+subject,
+// затем по подтверждённому email (связка создаётся), иначе при autoProvision создаётся новый пользователь
+// без пароля по тем же правилам policy, что и регистрация: кода приглашения и токена инициализации
+// у провайдера нет, поэтому в режимах closed и invite, а также для первого пользователя при
+// неоткрытой регистрации автосоздание отклоняется. Неактивный пользователь возвращается как есть -
+// проверка на стороне вызывающего.
+func LoginExternalIdentity(policy RegistrationPolicy, ext ExternalIdentity, autoProvision bool) (*User, error) {
+	var user User
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var link UserIdentity
+		err := tx.Where("issuer = ? AND subject = ?", ext.Issuer, ext.Subject).First(&link).Error
+		if err == nil {
+			if err := tx.Where("id = ?", link.UserID).First(&user).Error; err != nil {
+				return err
+			}
+			return tx.Model(&link).Updates(map[string]interface{}{"last_login_at": now, "email": ext.Email}).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// Связывание и автосоздание только по подтверждённому email
+		if ext.Email == "" || !ext.EmailVerified {
+			return ErrExternalEmailNotVerified
+		}
+		err = tx.Where("LOWER(email) = ?", strings.ToLower(ext.Email)).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if !autoProvision {
+				return ErrExternalAccountNotFound
+			}
+			var users int64
+			if err := tx.Unscoped().Model(&User{}).Count(&users).Error; err != nil {
+				return err
+			}
+			if err := checkRegistration(tx, policy, users, RegisterInput{Email: ext.Email}); err != nil {
+				return err
+			}
+			username, err := availableUsername(tx, ext.Username, ext.Email)
+			if err != nil {
+				return err
+			}
+			user = User{ID: NewUserID(), Username: username, Email: ext.Email, IsActive: true, EmailVerifiedAt: &now}
+			if err := createUser(tx, &user); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case user.EmailVerifiedAt == nil:
+			// Провайдер подтвердил адрес
+			if err := tx.Model(&user).UpdateColumn("email_verified_at", now).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&UserIdentity{
+			ID:          "idn_" + randomHex(12),
+			UserID:      user.ID,
+			Issuer:      ext.Issuer,
+			Subject:     ext.Subject,
+			Email:       ext.Email,
+			LastLoginAt: &now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// availableUsername Свободное имя пользователя на основе желаемого имени или email
+func availableUsername(tx *gorm.DB, preferred, email string) (string, error) {
+	base := usernameUnsafe.ReplaceAllString(preferred, "")
+	if len(base) < 3 {
+		base = usernameUnsafe.ReplaceAllString(strings.SplitN(email, "@", 2)[0], "")
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+	if len(base) > 64 {
+		base = base[:64]
+	}
+	candidate := base
+	for i := 0; i < 5; i++ {
+		var count int64
+		if err := tx.Unscoped().Model(&User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = base + "-" + randomHex(2)
+	}
+	return base + "-" + randomHex(6), nil
+}
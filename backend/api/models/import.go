@@ -0,0 +1,194 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Причины конфликтов импорта публикаций
+const (
+	ConflictIDTaken         = "id_taken"         // ID занят другой публикацией, выдан новый ID
+	ConflictInvalidID       = "invalid_id"       // Недопустимый ID, выдан новый ID
+	ConflictDocExists       = "doc_exists"       // У пользователя уже есть публикация документа, импорт пропущен
+	ConflictAlreadyImported = "already_imported" // Та же публикация уже импортирована, импорт пропущен
+	ConflictDuplicateDoc    = "duplicate_doc"    // Документ повторяется в архиве, импорт пропущен
+)
+
+// shareIDPattern Допустимый ID импортируемой публикации (попадает в URL /s/:id)
+var shareIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// errImportDryRun Откат транзакции пробного импорта
+var errImportDryRun = errors.New("dry run")
+
+// ImportedShare Публикация из архива: ID, ParentShareID и ChildShareID ссылок - исходные,
+// из другого экземпляра; UserID и DeletedAt игнорируются
+type ImportedShare struct {
+	Share      Share
+	References []ShareReference
+	Revisions  []ShareRevision // References ревизий - JSON формата BlockReference, как в базе
+}
+
+// ImportConflict Конфликт при импорте публикации
+type ImportConflict struct {
+	ShareID    string `json:"shareId"` // Исходный ID из архива
+	DocID      string `json:"docId"`
+	Reason     string `json:"reason"`
+	NewID      string `json:"newId,omitempty"`      // Выданный ID (id_taken, invalid_id)
+	ExistingID string `json:"existingId,omitempty"` // Публикация, на которую теперь указывают ссылки (doc_exists, already_imported)
+}
+
+// ImportReport Итоги импорта публикаций
+type ImportReport struct {
+	Imported  int              `json:"imported"` // Создано публикаций, включая получившие новый ID
+	Remapped  int              `json:"remapped"` // Из них с новым ID
+	Skipped   int              `json:"skipped"`
+	Revisions int              `json:"revisions"`
+	DryRun    bool             `json:"dryRun"`
+	Conflicts []ImportConflict `json:"conflicts"`
+}
+
+// ImportShares Импорт публикаций для пользователя userID в одной транзакции. Исходные ID
+// сохраняются, если они свободны, иначе выдаются новые; ParentShareID и публикации ссылаемых
+// блоков переназначаются на итоговые ID. Публикации документов, которые у пользователя уже есть,
+// пропускаются, а ссылки на них указывают на существующую публикацию. dryRun - только отчёт
+func ImportShares(userID string, items []ImportedShare, dryRun bool) (*ImportReport, error) {
+	report := &ImportReport{DryRun: dryRun, Conflicts: []ImportConflict{}}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// Первый проход: итоговые ID; пропущенные публикации отображаются на существующие
+		idMap := make(map[string]string, len(items))
+		create := make([]bool, len(items))
+		seenDocs := map[string]bool{}
+		for i := range items {
+			s := &items[i].Share
+			conflict := ImportConflict{ShareID: s.ID, DocID: s.DocID}
+
+			var existing Share
+			res := tx.Where("user_id = ? AND doc_id = ?", userID, s.DocID).Limit(1).Find(&existing)
+			if res.Error != nil {
+				return res.Error
+			}
+			switch {
+			case seenDocs[s.DocID]:
+				conflict.Reason = ConflictDuplicateDoc
+			case res.RowsAffected > 0 && existing.ID == s.ID:
+				conflict.Reason, conflict.ExistingID = ConflictAlreadyImported, existing.ID
+			case res.RowsAffected > 0:
+				conflict.Reason, conflict.ExistingID = ConflictDocExists, existing.ID
+			}
+			seenDocs[s.DocID] = true
+			if conflict.Reason != "" {
+				if conflict.ExistingID != "" {
+					idMap[s.ID] = conflict.ExistingID
+				}
+				report.Skipped++
+				report.Conflicts = append(report.Conflicts, conflict)
+				continue
+			}
+
+			newID := s.ID
+			if !shareIDPattern.MatchString(s.ID) {
+				conflict.Reason = ConflictInvalidID
+			} else if _, dup := idMap[s.ID]; dup {
+				conflict.Reason = ConflictIDTaken
+			} else {
+				var taken int64
+				if err := tx.Unscoped().Model(&Share{}).Where("id = ?", s.ID).Count(&taken).Error; err != nil {
+					return err
+				}
+				if taken > 0 {
+					conflict.Reason = ConflictIDTaken
+				}
+			}
+			if conflict.Reason != "" {
+				newID = NewShareID()
+				conflict.NewID = newID
+				report.Remapped++
+				report.Conflicts = append(report.Conflicts, conflict)
+			}
+			if _, dup := idMap[s.ID]; !dup {
+				idMap[s.ID] = newID
+			}
+			create[i] = true
+			report.Imported++
+		}
+
+		// Второй проход: создание публикаций, ссылок и ревизий с переназначенными ID
+		for i := range items {
+			if !create[i] {
+				continue
+			}
+			item := &items[i]
+			share := item.Share
+			share.ID = idMap[share.ID]
+			share.UserID = userID
+			share.DeletedAt = gorm.DeletedAt{}
+			share.ParentShareID = remapShareID(tx, userID, idMap, share.ParentShareID)
+			if share.Revision < 1 {
+				share.Revision = 1
+			}
+			if share.CreatedAt.IsZero() {
+				share.CreatedAt = time.Now()
+			}
+			for _, rev := range item.Revisions {
+				share.Revision = max(share.Revision, rev.Version)
+			}
+			if err := tx.Create(&share).Error; err != nil {
+				return err
+			}
+
+			refs := make([]ShareReference, 0, len(item.References))
+			for _, r := range item.References {
+				r.ChildShareID = remapShareID(tx, userID, idMap, r.ChildShareID)
+				refs = append(refs, r)
+			}
+			if err := ReplaceShareReferences(tx, share.ID, refs); err != nil {
+				return err
+			}
+
+			if len(item.Revisions) == 0 {
+				// Архив без истории: текущее содержимое становится версией share.Revision
+				if _, err := CreateShareRevision(tx, &share, ReferencesJSON(refs), 0); err != nil {
+					return err
+				}
+				report.Revisions++
+				continue
+			}
+			for _, rev := range item.Revisions {
+				rev.ID = "rev_" + randomHex(12)
+				rev.ShareID = share.ID
+				if err := tx.Create(&rev).Error; err != nil {
+					return err
+				}
+				report.Revisions++
+			}
+		}
+		if dryRun {
+			return errImportDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportDryRun) {
+		return nil, err
+	}
+	return report, nil
+}
+
+// remapShareID Итоговый ID публикации, на которую ссылается импортируемая: из этого же импорта
+// или существующая публикация пользователя; иначе пусто
+func remapShareID(tx *gorm.DB, userID string, idMap map[string]string, id string) string {
+	if id == "" {
+		return ""
+	}
+	if mapped, ok := idMap[id]; ok {
+		return mapped
+	}
+	var found int64
+	tx.Model(&Share{}).Where("id = ? AND user_id = ?", id, userID).Count(&found)
+	if found > 0 {
+		return id
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInviteInvalid Код приглашения не найден, отозван, истёк или исчерпан
+var ErrInviteInvalid = errors.New("invite code is invalid, expired or used up")
+
+// Invite Приглашение к регистрации (хранится только хэш кода)
+type Invite struct {
+	ID        string     `gorm:"primaryKey;size:64" json:"id"`
+	CodeHash  string     `gorm:"size:255;uniqueIndex" json:"-"`
+	CreatedBy string     `gorm:"size:64;index" json:"createdBy"`
+	Note      string     `gorm:"size:255" json:"note"`
+	MaxUses   int        `gorm:"default:1" json:"maxUses"` // 0 - без ограничения
+	Uses      int        `gorm:"default:0" json:"uses"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// TableName Указание имени таблицы
+func (Invite) TableName() string {
+	return "invites"
+}
+
+// Usable Можно ли ещё зарегистрироваться по приглашению
+func (i *Invite) Usable(now time.Time) bool {
+	return i.RevokedAt == nil &&
+		(i.ExpiresAt == nil || i.ExpiresAt.After(now)) &&
+		(i.MaxUses == 0 || i.Uses < i.MaxUses)
+}
+
+// normalizeInviteCode Код без пробелов, в нижнем регистре
+func normalizeInviteCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+// CreateInvite Создание приглашения; код возвращается один раз
+func CreateInvite(createdBy, note string, maxUses int, expiresAt *time.Time) (*Invite, string, error) {
+	code := "inv-" + randomHex(10)
+	inv := &Invite{
+		ID:        "invite_" + randomHex(8),
+		CodeHash:  HashToken(code),
+		CreatedBy: createdBy,
+		Note:      note,
+		MaxUses:   maxUses,
+		ExpiresAt: expiresAt,
+	}
+	if err := DB.Create(inv).Error; err != nil {
+		return nil, "", err
+	}
+	return inv, code, nil
+}
+
+// ListInvites Все приглашения, новые первыми
+func ListInvites() ([]Invite, error) {
+	var list []Invite
+	err := DB.Order("created_at DESC").Find(&list).Error
+	return list, err
+}
+
+// RevokeInvite Отзыв приглашения; false, если приглашение не найдено или уже отозвано
+func RevokeInvite(id string) (bool, error) {
+	res := DB.Model(&Invite{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
+
+// redeemInvite Использование приглашения при регистрации: условное увеличение счётчика,
+// поэтому параллельные регистрации не превышают MaxUses
+func redeemInvite(tx *gorm.DB, code string) error {
+	now := time.Now()
+	res := tx.Model(&Invite{}).
+		Where("code_hash = ? AND revoked_at IS NULL", HashToken(normalizeInviteCode(code))).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Where("max_uses = 0 OR uses < max_uses").
+		UpdateColumn("uses", gorm.Expr("uses + 1"))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInviteInvalid
+	}
+	return nil
+}
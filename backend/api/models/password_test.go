@@ -0,0 +1,65 @@
+package models
+
+import "testing"
+
+// userWithCredentials Активный пользователь с одной сессией и одним API токеном
+func userWithCredentials(t *testing.T) *User {
+	t.Helper()
+	user := User{ID: NewUserID(), Username: "alice", Email: "alice@example.com", IsActive: true}
+	if err := CreateUser(&user); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := CreateSession(user.ID, "test", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateUserToken(TokenInput{UserID: user.ID, Name: "plugin", MaxScopes: AllScopes}); err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+// activeTokens Число неотозванных API токенов пользователя
+func activeTokens(t *testing.T, userID string) int64 {
+	t.Helper()
+	var n int64
+	if err := DB.Model(&UserToken{}).Where("user_id = ? AND revoked = ?", userID, false).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestResetPasswordRevokesTokens(t *testing.T) {
+	setupTestDB(t)
+	user := userWithCredentials(t)
+	link, err := IssueEmailToken(user, PurposeResetPassword, PasswordResetTTL())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, sessions, tokens, err := ResetPassword(link.ID, "new-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sessions != 1 || tokens != 1 {
+		t.Fatalf("revoked sessions = %d, tokens = %d, want 1 and 1", sessions, tokens)
+	}
+	if n := activeTokens(t, user.ID); n != 0 {
+		t.Fatalf("active tokens = %d, want 0", n)
+	}
+}
+
+func TestSetUserPasswordRevokesTokens(t *testing.T) {
+	setupTestDB(t)
+	user := userWithCredentials(t)
+
+	sessions, tokens, err := SetUserPassword(user.ID, "new-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sessions != 1 || tokens != 1 {
+		t.Fatalf("revoked sessions = %d, tokens = %d, want 1 and 1", sessions, tokens)
+	}
+	if n := activeTokens(t, user.ID); n != 0 {
+		t.Fatalf("active tokens = %d, want 0", n)
+	}
+}
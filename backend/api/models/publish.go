@@ -0,0 +1,266 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrSharePasswordRequired Для новой публикации с паролем пароль не передан
+var ErrSharePasswordRequired = errors.New("password must be provided for new share")
+
+// publishAttempts Число попыток публикации при конфликте уникального индекса (user_id, doc_id)
+const publishAttempts = 3
+
+// PublishInput Данные публикации документа вместе со ссылаемыми блоками
+type PublishInput struct {
+	UserID          string
+	DocID           string
+	DocTitle        string
+	Content         string
+	RequirePassword bool
+	PasswordHash    string // Пустая строка - сохранить пароль существующей публикации
+	IsPublic        bool
+	ExpireAt        time.Time
+	References      []PublishReference
+}
+
+// PublishReference Ссылаемый блок публикуемого документа
+type PublishReference struct {
+	BlockReference
+	Title string // Заголовок публикации блока
+}
+
+// PublishResult Результат публикации
+type PublishResult struct {
+	Share  *Share
+	Reused bool // Обновлена существующая публикация документа
+}
+
+// NewShareID Генерация случайного ID публикации
+func NewShareID() string {
+	return randomHex(16)
+}
+
+// PublishShare Создание или обновление публикации документа, публикаций ссылаемых блоков
+// и записей share_references в одной транзакции. Для пары (user_id, doc_id) существует не больше
+// одной неудалённой публикации (уникальный индекс), поэтому параллельные публикации одного
+// документа обновляют одну запись; при конфликте транзакция повторяется.
+func PublishShare(in PublishInput) (*PublishResult, error) {
+	var res *PublishResult
+	var err error
+	for attempt := 0; attempt < publishAttempts; attempt++ {
+		res, err = publishShareTx(in)
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			break
+		}
+	}
+	return res, err
+}
+
+func publishShareTx(in PublishInput) (*PublishResult, error) {
+	var res PublishResult
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		share, err := findLiveShareForPublish(tx, in.UserID, in.DocID)
+		if err != nil {
+			return err
+		}
+
+		if in.RequirePassword && in.PasswordHash == "" && (share == nil || share.PasswordHash == "") {
+			return ErrSharePasswordRequired
+		}
+
+		if share != nil {
+			res.Reused = true
+		} else {
+			share = &Share{ID: NewShareID(), UserID: in.UserID, DocID: in.DocID}
+		}
+
+		// Новая ревизия создаётся только при изменении содержимого
+		changed := !res.Reused || share.ContentChanged(in.DocTitle, in.Content)
+		if changed {
+			share.Revision++
+		}
+
+		share.DocTitle = in.DocTitle
+		share.Content = in.Content
+		share.RequirePassword = in.RequirePassword
+		share.IsPublic = in.IsPublic
+		share.ExpireAt = in.ExpireAt
+		if !in.RequirePassword {
+			share.PasswordHash = ""
+		} else if in.PasswordHash != "" {
+			share.PasswordHash = in.PasswordHash
+		}
+
+		if res.Reused {
+			err = tx.Save(share).Error
+		} else {
+			err = tx.Create(share).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		if changed {
+			if _, err := CreateShareRevision(tx, share, publishReferencesJSON(in.References), 0); err != nil {
+				return err
+			}
+		}
+
+		refs, err := publishBlockShares(tx, share, in.References)
+		if err != nil {
+			return err
+		}
+		if err := ReplaceShareReferences(tx, share.ID, refs); err != nil {
+			return err
+		}
+
+		res.Share = share
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// findLiveShareForPublish Поиск неудалённой публикации документа; истёкшая публикация
+// помечается удалённой, чтобы новая публикация получила новый ID
+func findLiveShareForPublish(tx *gorm.DB, userID, docID string) (*Share, error) {
+	var share Share
+	err := tx.Where("user_id = ? AND doc_id = ?", userID, docID).First(&share).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if share.IsExpired() {
+		if err := tx.Delete(&share).Error; err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	return &share, nil
+}
+
+// publishBlockShares Создание или обновление публикаций ссылаемых блоков (docId = blockId)
+func publishBlockShares(tx *gorm.DB, parent *Share, refs []PublishReference) ([]ShareReference, error) {
+	out := make([]ShareReference, 0, len(refs))
+	seen := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		if ref.BlockID == "" || ref.BlockID == parent.DocID || seen[ref.BlockID] {
+			continue
+		}
+		seen[ref.BlockID] = true
+
+		blockShare, err := findLiveShareForPublish(tx, parent.UserID, ref.BlockID)
+		if err != nil {
+			return nil, err
+		}
+
+		if blockShare != nil {
+			// Обновление существующей публикации блока
+			blockChanged := blockShare.ContentChanged(ref.Title, ref.Content)
+			if blockChanged {
+				blockShare.Revision++
+			}
+			blockShare.DocTitle = ref.Title
+			blockShare.Content = ref.Content
+			blockShare.ExpireAt = parent.ExpireAt
+			blockShare.ParentShareID = parent.ID
+			if err := tx.Save(blockShare).Error; err != nil {
+				return nil, err
+			}
+			if blockChanged {
+				if _, err := CreateShareRevision(tx, blockShare, "", 0); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			// Создание новой публикации блока
+			blockShare = &Share{
+				ID:            NewShareID(),
+				UserID:        parent.UserID,
+				DocID:         ref.BlockID,
+				DocTitle:      ref.Title,
+				Content:       ref.Content,
+				ParentShareID: parent.ID,
+				// Наследование пароля и срока действия от родительской публикации
+				RequirePassword: parent.RequirePassword,
+				PasswordHash:    parent.PasswordHash,
+				ExpireAt:        parent.ExpireAt,
+				IsPublic:        parent.IsPublic,
+				Revision:        1,
+			}
+			if err := tx.Create(blockShare).Error; err != nil {
+				return nil, err
+			}
+			if _, err := CreateShareRevision(tx, blockShare, "", 0); err != nil {
+				return nil, err
+			}
+		}
+
+		out = append(out, ShareReference{
+			BlockID:      ref.BlockID,
+			ChildShareID: blockShare.ID,
+			Content:      ref.Content,
+			DisplayText:  ref.DisplayText,
+			RefCount:     ref.RefCount,
+		})
+	}
+	return out, nil
+}
+
+// publishReferencesJSON Снимок ссылаемых блоков для ревизии
+func publishReferencesJSON(refs []PublishReference) string {
+	if len(refs) == 0 {
+		return ""
+	}
+	out := make([]BlockReference, 0, len(refs))
+	for _, r := range refs {
+		out = append(out, r.BlockReference)
+	}
+	data, _ := json.Marshal(out)
+	return string(data)
+}
+
+// dedupeActiveShares Удаление (мягкое) дубликатов публикаций одного документа, оставшихся
+// от параллельных публикаций: остаётся самая новая запись. Выполняется до создания уникального индекса.
+func dedupeActiveShares() error {
+	type dup struct {
+		UserID string
+		DocID  string
+	}
+	var dups []dup
+	if err := DB.Model(&Share{}).Select("user_id, doc_id").
+		Group("user_id, doc_id").Having("COUNT(*) > 1").
+		Scan(&dups).Error; err != nil {
+		return err
+	}
+	for _, d := range dups {
+		var keep Share
+		if err := DB.Where("user_id = ? AND doc_id = ?", d.UserID, d.DocID).
+			Order("created_at DESC").First(&keep).Error; err != nil {
+			return err
+		}
+		res := DB.Where("user_id = ? AND doc_id = ? AND id != ?", d.UserID, d.DocID, keep.ID).Delete(&Share{})
+		if res.Error != nil {
+			return res.Error
+		}
+		log.Printf("Duplicate shares removed for doc %s: %d", d.DocID, res.RowsAffected)
+	}
+	return nil
+}
+
+// ensureShareUniqueIndex Частичный уникальный индекс: одна неудалённая публикация на документ пользователя
+func ensureShareUniqueIndex() error {
+	if err := dedupeActiveShares(); err != nil {
+		return err
+	}
+	return DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_shares_user_doc_live ON shares(user_id, doc_id) WHERE deleted_at IS NULL").Error
+}
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// purgeBatchSize Размер пачки ID в одном запросе удаления (ограничение числа параметров SQLite)
+const purgeBatchSize = 500
+
+// PurgeReport Итоги окончательного удаления публикаций
+type PurgeReport struct {
+	Expired     int64 `json:"expired"`     // Публикации, истёкшие раньше порога
+	Deleted     int64 `json:"deleted"`     // Публикации, мягко удалённые раньше порога
+	ChildShares int64 `json:"childShares"` // Публикации ссылаемых блоков удалённых родителей
+	Revisions   int64 `json:"revisions"`   // Ревизии удалённых публикаций
+}
+
+// Total Общее число удалённых публикаций
+func (r PurgeReport) Total() int64 {
+	return r.Expired + r.Deleted + r.ChildShares
+}
+
+// PurgeShares Окончательное удаление публикаций, истёкших (ExpireAt) или мягко удалённых
+// (DeletedAt) раньше cutoff, вместе с публикациями их ссылаемых блоков (ParentShareID), ревизиями
+// и записями share_references. Публикации блоков, на которые ещё ссылаются остающиеся публикации,
+// не удаляются
+func PurgeShares(cutoff time.Time) (PurgeReport, error) {
+	var report PurgeReport
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var expired, deleted []string
+		if err := tx.Unscoped().Model(&Share{}).
+			Where("deleted_at IS NULL AND expire_at < ?", cutoff).
+			Pluck("id", &expired).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&Share{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Pluck("id", &deleted).Error; err != nil {
+			return err
+		}
+		report.Deleted = int64(len(deleted))
+
+		parents := append(expired, deleted...)
+		seen := make(map[string]bool, len(parents))
+		for _, id := range parents {
+			seen[id] = true
+		}
+
+		// Каскад на публикации ссылаемых блоков
+		var candidates []string
+		for _, batch := range chunkIDs(parents) {
+			var ids []string
+			if err := tx.Unscoped().Model(&Share{}).Where("parent_share_id IN ?", batch).Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			for _, id := range ids {
+				if !seen[id] {
+					seen[id] = true
+					candidates = append(candidates, id)
+				}
+			}
+		}
+		// Публикации блоков копируют срок действия родителя и попадают в expired вместе с ним,
+		// поэтому истёкшие публикации проходят ту же проверку ссылок, что и каскад
+		if _, err := unreferencedChildren(tx, append(expired[:len(expired):len(expired)], candidates...), seen); err != nil {
+			return err
+		}
+		expired = stillPurging(expired, seen)
+		children := stillPurging(candidates, seen)
+		report.Expired = int64(len(expired))
+		report.ChildShares = int64(len(children))
+
+		for _, batch := range chunkIDs(append(append(expired, deleted...), children...)) {
+			res := tx.Where("share_id IN ?", batch).Delete(&ShareRevision{})
+			if res.Error != nil {
+				return res.Error
+			}
+			report.Revisions += res.RowsAffected
+			if err := tx.Where("share_id IN ?", batch).Delete(&ShareReference{}).Error; err != nil {
+				return err
+			}
+			if err := deleteShareViews(tx, batch); err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("id IN ?", batch).Delete(&Share{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return report, err
+}
+
+// shareLink Ссылка публикации share_id на публикацию блока child_share_id
+type shareLink struct {
+	ShareID      string
+	ChildShareID string
+}
+
+// unreferencedChildren Публикации из candidates (ссылаемых блоков и истёкшие), на которые не ссылается
+// ни одна остающаяся публикация (share_references). purging - удаляемые публикации, включая
+// candidates; оставленные публикации блоков исключаются из него и переносятся к одной из
+// ссылающихся публикаций (ParentShareID), чтобы доступ по паролю родителя продолжал работать
+func unreferencedChildren(tx *gorm.DB, candidates []string, purging map[string]bool) ([]string, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	var links []shareLink
+	for _, batch := range chunkIDs(candidates) {
+		var part []shareLink
+		if err := tx.Table("share_references").Select("share_id, child_share_id").
+			Where("child_share_id IN ?", batch).Scan(&part).Error; err != nil {
+			return nil, err
+		}
+		links = append(links, part...)
+	}
+
+	// Оставленная публикация блока сама остаётся ссылающейся, поэтому проверка повторяется,
+	// пока набор оставленных не перестанет меняться
+	newParent := make(map[string]string)
+	for changed := true; changed; {
+		changed = false
+		for _, l := range links {
+			if purging[l.ChildShareID] && !purging[l.ShareID] && l.ShareID != l.ChildShareID {
+				purging[l.ChildShareID] = false
+				newParent[l.ChildShareID] = l.ShareID
+				changed = true
+			}
+		}
+	}
+
+	var children []string
+	for _, id := range candidates {
+		if parent, kept := newParent[id]; kept {
+			if err := tx.Unscoped().Model(&Share{}).Where("id = ?", id).Update("parent_share_id", parent).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+		children = append(children, id)
+	}
+	return children, nil
+}
+
+// stillPurging ID из ids, которые остаются в наборе удаляемых purging
+func stillPurging(ids []string, purging map[string]bool) []string {
+	kept := ids[:0:0]
+	for _, id := range ids {
+		if purging[id] {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+// chunkIDs Разбиение списка ID на пачки по purgeBatchSize
+func chunkIDs(ids []string) [][]string {
+	var chunks [][]string
+	for len(ids) > purgeBatchSize {
+		chunks = append(chunks, ids[:purgeBatchSize])
+		ids = ids[purgeBatchSize:]
+	}
+	if len(ids) > 0 {
+		chunks = append(chunks, ids)
+	}
+	return chunks
+}
+
+// VacuumDB Перенос WAL в основной файл и сжатие базы данных (SQLite)
+func VacuumDB() error {
+	if err := DB.Exec("PRAGMA wal_checkpoint(TRUNCATE);").Error; err != nil {
+		return err
+	}
+	return DB.Exec("VACUUM;").Error
+}
+
+// BackupDB Согласованная копия базы данных в новый файл path (VACUUM INTO, SQLite)
+func BackupDB(path string) error {
+	return DB.Exec("VACUUM INTO ?", path).Error
+}
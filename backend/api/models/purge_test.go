@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPurgeSharesKeepsReferencedExpiredBlocks(t *testing.T) {
+	setupTestDB(t)
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(24 * time.Hour)
+	shares := []Share{
+		{ID: "doc-a", UserID: "u1", DocID: "doc-a", ExpireAt: past},
+		// Публикации блоков копируют срок действия родителя
+		{ID: "block-b", UserID: "u1", DocID: "block-b", ParentShareID: "doc-a", ExpireAt: past},
+		{ID: "block-d", UserID: "u1", DocID: "block-d", ParentShareID: "doc-a", ExpireAt: past},
+		{ID: "doc-c", UserID: "u1", DocID: "doc-c", ExpireAt: future},
+	}
+	for i := range shares {
+		if err := DB.Create(&shares[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	refs := []ShareReference{
+		{ShareID: "doc-a", BlockID: "b", ChildShareID: "block-b"},
+		{ShareID: "doc-a", BlockID: "d", ChildShareID: "block-d"},
+		{ShareID: "doc-c", BlockID: "b", ChildShareID: "block-b"},
+	}
+	if err := DB.Create(&refs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := PurgeShares(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Expired != 2 || report.ChildShares != 0 {
+		t.Fatalf("report = %+v, want 2 expired", report)
+	}
+
+	var left []Share
+	if err := DB.Unscoped().Order("id").Find(&left).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 || left[0].ID != "block-b" || left[1].ID != "doc-c" {
+		t.Fatalf("remaining shares = %+v, want block-b and doc-c", left)
+	}
+	if left[0].ParentShareID != "doc-c" {
+		t.Fatalf("block-b parent = %q, want doc-c", left[0].ParentShareID)
+	}
+}
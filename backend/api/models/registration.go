@@ -0,0 +1,222 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Режимы регистрации (REGISTRATION_MODE)
+const (
+	RegistrationOpen   = "open"   // Любой желающий
+	RegistrationClosed = "closed" // Только администратор (CLI)
+	RegistrationInvite = "invite" // По коду приглашения
+	RegistrationDomain = "domain" // Только с email из разрешённых доменов
+)
+
+var (
+	ErrRegistrationClosed    = errors.New("registration is closed")
+	ErrInviteRequired        = errors.New("invite code is required")
+	ErrEmailDomainNotAllowed = errors.New("email domain is not allowed")
+	ErrUserExists            = errors.New("username or email already exists")
+	ErrBootstrapTokenInvalid = errors.New("bootstrap token is invalid or expired")
+)
+
+// RegistrationPolicy Правила регистрации новых пользователей
+type RegistrationPolicy struct {
+	Mode    string   `json:"mode"`
+	Domains []string `json:"domains,omitempty"` // Разрешённые домены email (пусто - любые)
+}
+
+// Registration Текущие правила регистрации (см. LoadRegistrationPolicy)
+var Registration = RegistrationPolicy{Mode: RegistrationOpen}
+
+// LoadRegistrationPolicy Правила из REGISTRATION_MODE и REGISTRATION_DOMAINS
+func LoadRegistrationPolicy() (RegistrationPolicy, error) {
+	p := RegistrationPolicy{Mode: strings.ToLower(strings.TrimSpace(os.Getenv("REGISTRATION_MODE")))}
+	if p.Mode == "" {
+		p.Mode = RegistrationOpen
+	}
+	for _, d := range strings.Split(os.Getenv("REGISTRATION_DOMAINS"), ",") {
+		if d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@")); d != "" {
+			p.Domains = append(p.Domains, d)
+		}
+	}
+	switch p.Mode {
+	case RegistrationOpen, RegistrationClosed, RegistrationInvite:
+	case RegistrationDomain:
+		if len(p.Domains) == 0 {
+			return p, errors.New("REGISTRATION_MODE=domain requires REGISTRATION_DOMAINS")
+		}
+	default:
+		return p, fmt.Errorf("invalid REGISTRATION_MODE %q (open, closed, invite, domain)", p.Mode)
+	}
+	return p, nil
+}
+
+// EmailAllowed Разрешён ли домен email (без списка доменов - любой)
+func (p RegistrationPolicy) EmailAllowed(email string) bool {
+	if len(p.Domains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, d := range p.Domains {
+		if domain == d {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterInput Данные регистрации (пароль уже захэширован)
+type RegisterInput struct {
+	Username       string
+	Email          string
+	PasswordHash   string
+	InviteCode     string
+	BootstrapToken string
+}
+
+// RegisterUser Регистрация по правилам policy в одной транзакции. Первый пользователь экземпляра
+// становится администратором; если регистрация не открыта, ему нужен токен инициализации
+// (DATA_DIR/bootstrap_token.txt, см. EnsureBootstrapToken). Разрешённые домены проверяются
+// во всех режимах, кроме первого пользователя.
+func RegisterUser(policy RegistrationPolicy, in RegisterInput) (*User, error) {
+	user := &User{
+		ID:           NewUserID(),
+		Username:     in.Username,
+		Email:        in.Email,
+		PasswordHash: in.PasswordHash,
+		IsActive:     true,
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var users int64
+		if err := tx.Unscoped().Model(&User{}).Count(&users).Error; err != nil {
+			return err
+		}
+
+		if err := checkRegistration(tx, policy, users, in); err != nil {
+			return err
+		}
+
+		var dup int64
+		if err := tx.Unscoped().Model(&User{}).Where("username = ? OR LOWER(email) = ?", in.Username, strings.ToLower(in.Email)).Count(&dup).Error; err != nil {
+			return err
+		}
+		if dup > 0 {
+			return ErrUserExists
+		}
+		if users > 0 && policy.Mode == RegistrationInvite {
+			if err := redeemInvite(tx, in.InviteCode); err != nil {
+				return err
+			}
+		}
+		return createUser(tx, user)
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrUserExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// checkRegistration Проверка правил policy для нового пользователя (users - число пользователей
+// экземпляра): первому пользователю при неоткрытой регистрации нужен токен инициализации,
+// остальным - открытый режим или код приглашения и разрешённый домен email
+func checkRegistration(tx *gorm.DB, policy RegistrationPolicy, users int64, in RegisterInput) error {
+	if users == 0 {
+		if policy.Mode == RegistrationOpen {
+			return nil
+		}
+		token := in.BootstrapToken
+		if token == "" {
+			token = in.InviteCode
+		}
+		return consumeBootstrapToken(tx, token)
+	}
+	switch policy.Mode {
+	case RegistrationClosed:
+		return ErrRegistrationClosed
+	case RegistrationInvite:
+		if strings.TrimSpace(in.InviteCode) == "" {
+			return ErrInviteRequired
+		}
+	}
+	if !policy.EmailAllowed(in.Email) {
+		return ErrEmailDomainNotAllowed
+	}
+	return nil
+}
+
+// createUser Создание пользователя; первый пользователь экземпляра становится администратором
+func createUser(tx *gorm.DB, user *User) error {
+	var users int64
+	if err := tx.Unscoped().Model(&User{}).Count(&users).Error; err != nil {
+		return err
+	}
+	if users == 0 {
+		user.IsAdmin = true
+		log.Printf("First user %s registered as administrator", user.Username)
+	}
+	return tx.Create(user).Error
+}
+
+// CreateUser Создание пользователя в транзакции (CLI и внешние провайдеры); первый пользователь -
+// администратор
+func CreateUser(user *User) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		return createUser(tx, user)
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		err = ErrUserExists
+	}
+	return err
+}
+
+// consumeBootstrapToken Использование одноразового токена инициализации
+func consumeBootstrapToken(tx *gorm.DB, token string) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ErrBootstrapTokenInvalid
+	}
+	res := tx.Model(&BootstrapToken{}).
+		Where("token = ? AND used = ? AND expires_at > ?", token, false, time.Now()).
+		Update("used", true)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrBootstrapTokenInvalid
+	}
+	return nil
+}
+
+// ensureAdmin Экземпляр без администратора (созданный до появления ролей): администратором
+// становится самый первый активный пользователь
+func ensureAdmin() error {
+	var admins int64
+	if err := DB.Model(&User{}).Where("is_admin = ?", true).Count(&admins).Error; err != nil {
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+	var first User
+	res := DB.Where("is_active = ?", true).Order("created_at").Limit(1).Find(&first)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+	log.Printf("No administrator found, promoting the first user %s", first.Username)
+	return DB.Model(&first).UpdateColumn("is_admin", true).Error
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Ошибки обновления сессии
+var (
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrSessionExpired      = errors.New("session expired or revoked")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrSessionUserInactive = errors.New("user inactive or not found")
+)
+
+// Session Сессия входа в Web: access JWT ссылается на неё через claim sid,
+// refresh токен хранится только в виде хэша и меняется при каждом обновлении
+type Session struct {
+	ID               string     `gorm:"primaryKey;size:64" json:"id"`
+	UserID           string     `gorm:"size:64;index" json:"userId"`
+	RefreshTokenHash string     `gorm:"size:255;uniqueIndex" json:"-"`
+	PrevRefreshHash  string     `gorm:"size:255;index" json:"-"` // Предыдущий refresh токен: его повторное использование означает кражу
+	UserAgent        string     `gorm:"size:255" json:"userAgent"`
+	IP               string     `gorm:"size:64" json:"ip"`
+	ExpiresAt        time.Time  `gorm:"index" json:"expiresAt"` // Срок действия refresh токена
+	LastSeenAt       time.Time  `json:"lastSeenAt"`
+	RevokedAt        *time.Time `gorm:"index" json:"revokedAt,omitempty"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	UpdatedAt        time.Time  `json:"updatedAt"`
+}
+
+// TableName Указание имени таблицы
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// lastSeenResolution Как часто обновляется LastSeenAt при запросах с access токеном
+const lastSeenResolution = time.Minute
+
+// envDuration Длительность из переменной окружения или значение по умолчанию
+func envDuration(name string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+// SessionAccessTTL Срок жизни access JWT (SESSION_ACCESS_TTL, по умолчанию 15m)
+func SessionAccessTTL() time.Duration {
+	return envDuration("SESSION_ACCESS_TTL", 15*time.Minute)
+}
+
+// SessionRefreshTTL Срок жизни refresh токена (SESSION_REFRESH_TTL, по умолчанию 720h)
+func SessionRefreshTTL() time.Duration {
+	return envDuration("SESSION_REFRESH_TTL", 30*24*time.Hour)
+}
+
+// IsActive Действует ли сессия
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// CreateSession Создание сессии; возвращается сессия и открытый текст refresh токена
+func CreateSession(userID, userAgent, ip string) (*Session, string, error) {
+	refresh := "rt_" + randomHex(32)
+	now := time.Now()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	s := &Session{
+		ID:               "ses_" + randomHex(12),
+		UserID:           userID,
+		RefreshTokenHash: HashToken(refresh),
+		UserAgent:        userAgent,
+		IP:               ip,
+		ExpiresAt:        now.Add(SessionRefreshTTL()),
+		LastSeenAt:       now,
+	}
+	if err := DB.Create(s).Error; err != nil {
+		return nil, "", err
+	}
+	return s, refresh, nil
+}
+
+// ValidateSession Проверка сессии access токена: сессия не отозвана и не истекла,
+// пользователь активен. Время последней активности обновляется не чаще раза в минуту.
+func ValidateSession(sessionID, userID string) (*User, error) {
+	var s Session
+	if err := DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&s).Error; err != nil {
+		return nil, ErrSessionNotFound
+	}
+	now := time.Now()
+	if !s.IsActive(now) {
+		return nil, ErrSessionExpired
+	}
+	var user User
+	if err := DB.Where("id = ? AND is_active = ?", userID, true).First(&user).Error; err != nil {
+		return nil, ErrSessionUserInactive
+	}
+	if now.Sub(s.LastSeenAt) >= lastSeenResolution {
+		DB.Model(&s).UpdateColumn("last_seen_at", now)
+	}
+	return &user, nil
+}
+
+// RefreshSession Обмен refresh токена на новый (ротация). Предъявление уже использованного
+// refresh токена отзывает сессию целиком; вместе с ErrRefreshTokenReused возвращается отозванная сессия.
+func RefreshSession(refresh string) (*Session, string, error) {
+	hash := HashToken(refresh)
+	var s Session
+	err := DB.Where("refresh_token_hash = ?", hash).First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if DB.Where("prev_refresh_hash = ?", hash).First(&s).Error == nil {
+			_ = RevokeSession(s.UserID, s.ID)
+			return &s, "", ErrRefreshTokenReused
+		}
+		return nil, "", ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	if !s.IsActive(now) {
+		return nil, "", ErrSessionExpired
+	}
+	var count int64
+	DB.Model(&User{}).Where("id = ? AND is_active = ?", s.UserID, true).Count(&count)
+	if count == 0 {
+		return nil, "", ErrSessionUserInactive
+	}
+
+	next := "rt_" + randomHex(32)
+	res := DB.Model(&Session{}).Where("id = ? AND refresh_token_hash = ?", s.ID, hash).Updates(map[string]interface{}{
+		"refresh_token_hash": HashToken(next),
+		"prev_refresh_hash":  hash,
+		"last_seen_at":       now,
+	})
+	if res.Error != nil {
+		return nil, "", res.Error
+	}
+	if res.RowsAffected == 0 {
+		// Параллельное обновление тем же токеном - то же повторное использование: сессия отзывается
+		_ = RevokeSession(s.UserID, s.ID)
+		return &s, "", ErrRefreshTokenReused
+	}
+	return &s, next, nil
+}
+
+// ListSessions Действующие сессии пользователя, последние активные первыми
+func ListSessions(userID string) ([]Session, error) {
+	var sessions []Session
+	err := DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession Отзыв сессии пользователя
+func RevokeSession(userID, sessionID string) error {
+	res := DB.Model(&Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeUserSessions Отзыв всех сессий пользователя (выход на всех устройствах),
+// exceptID - сессия, которую нужно сохранить (пусто - отозвать все)
+func RevokeUserSessions(userID, exceptID string) (int64, error) {
+	q := DB.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if exceptID != "" {
+		q = q.Where("id != ?", exceptID)
+	}
+	res := q.Update("revoked_at", time.Now())
+	return res.RowsAffected, res.Error
+}
+
+// PurgeSessions Удаление сессий, истёкших или отозванных раньше cutoff
+func PurgeSessions(cutoff time.Time) (int64, error) {
+	res := DB.Where("expires_at < ? OR revoked_at < ?", cutoff, cutoff).Delete(&Session{})
+	return res.RowsAffected, res.Error
+}
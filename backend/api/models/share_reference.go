@@ -0,0 +1,146 @@
+package models
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ShareReference Ссылаемый блок публикации: связь родительской публикации, ID блока
+// и публикации этого блока, вычисленная при публикации
+type ShareReference struct {
+	ShareID      string    `gorm:"primaryKey;size:64" json:"shareId"`
+	BlockID      string    `gorm:"primaryKey;size:64" json:"blockId"`
+	ChildShareID string    `gorm:"size:64;index" json:"childShareId"`
+	Content      string    `gorm:"type:text" json:"content"`
+	DisplayText  string    `gorm:"size:255" json:"displayText,omitempty"`
+	RefCount     int       `gorm:"default:0" json:"refCount,omitempty"`
+	Position     int       `gorm:"default:0" json:"-"` // Порядок в запросе публикации
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// TableName Указание имени таблицы
+func (ShareReference) TableName() string {
+	return "share_references"
+}
+
+// ResolvedReference Ссылаемый блок с проверенной публикацией блока
+type ResolvedReference struct {
+	ShareReference
+	LiveChildID string // ID публикации блока, если она существует и не удалена
+}
+
+// ReplaceShareReferences Замена набора ссылаемых блоков публикации
+func ReplaceShareReferences(db *gorm.DB, shareID string, refs []ShareReference) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("share_id = ?", shareID).Delete(&ShareReference{}).Error; err != nil {
+			return err
+		}
+		if len(refs) == 0 {
+			return nil
+		}
+		for i := range refs {
+			refs[i].ShareID = shareID
+			refs[i].Position = i
+		}
+		return tx.Create(&refs).Error
+	})
+}
+
+// ListShareReferences Ссылаемые блоки публикации вместе с публикациями блоков одним запросом
+func ListShareReferences(shareID string) ([]ResolvedReference, error) {
+	var refs []ResolvedReference
+	err := DB.Table("share_references AS r").
+		Select("r.*, s.id AS live_child_id").
+		Joins("LEFT JOIN shares AS s ON s.id = r.child_share_id AND s.deleted_at IS NULL").
+		Where("r.share_id = ?", shareID).
+		Order("r.position").
+		Scan(&refs).Error
+	return refs, err
+}
+
+// ReferencesJSON Сериализация ссылаемых блоков в формат BlockReference (для снимков ревизий)
+func ReferencesJSON(refs []ShareReference) string {
+	if len(refs) == 0 {
+		return ""
+	}
+	out := make([]BlockReference, 0, len(refs))
+	for _, r := range refs {
+		out = append(out, BlockReference{BlockID: r.BlockID, Content: r.Content, DisplayText: r.DisplayText, RefCount: r.RefCount})
+	}
+	data, _ := json.Marshal(out)
+	return string(data)
+}
+
+// ReferencesFromJSON Разбор ссылаемых блоков из JSON формата BlockReference;
+// публикации блоков ищутся среди публикаций пользователя по docId = blockId
+func ReferencesFromJSON(db *gorm.DB, userID, refsJSON string) ([]ShareReference, error) {
+	if refsJSON == "" {
+		return nil, nil
+	}
+	var refs []BlockReference
+	if err := json.Unmarshal([]byte(refsJSON), &refs); err != nil {
+		return nil, err
+	}
+	out := make([]ShareReference, 0, len(refs))
+	seen := map[string]bool{}
+	for _, r := range refs {
+		if r.BlockID == "" || seen[r.BlockID] {
+			continue
+		}
+		seen[r.BlockID] = true
+		var childID string
+		db.Model(&Share{}).Select("id").
+			Where("user_id = ? AND doc_id = ?", userID, r.BlockID).
+			Order("created_at DESC").Limit(1).
+			Scan(&childID)
+		out = append(out, ShareReference{
+			BlockID:      r.BlockID,
+			ChildShareID: childID,
+			Content:      r.Content,
+			DisplayText:  r.DisplayText,
+			RefCount:     r.RefCount,
+		})
+	}
+	return out, nil
+}
+
+// migrateLegacyReferences Перенос JSON из устаревшей колонки shares.references в share_references.
+// Колонка остаётся в таблице (AutoMigrate не удаляет колонки), но очищается после переноса.
+func migrateLegacyReferences() error {
+	if !DB.Migrator().HasColumn(&Share{}, "references") {
+		return nil
+	}
+
+	type legacyRow struct {
+		ID         string
+		UserID     string
+		References string
+	}
+	var rows []legacyRow
+	if err := DB.Raw(`SELECT id, user_id, "references" AS "references" FROM shares WHERE "references" IS NOT NULL AND "references" != ''`).
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			refs, err := ReferencesFromJSON(tx, row.UserID, row.References)
+			if err != nil {
+				log.Printf("Skipping invalid references of share %s: %v", row.ID, err)
+			} else if err := ReplaceShareReferences(tx, row.ID, refs); err != nil {
+				return err
+			}
+			if err := tx.Exec(`UPDATE shares SET "references" = '' WHERE id = ?`, row.ID).Error; err != nil {
+				return err
+			}
+		}
+		log.Printf("Share references migrated: %d shares", len(rows))
+		return nil
+	})
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ShareRevision Снимок содержимого публикации на момент очередной публикации
+type ShareRevision struct {
+	ID string `gorm:"primaryKey;size:64" json:"id"`
+	// Уникальная пара share+version, номер версии растёт с каждой публикацией
+	ShareID      string    `gorm:"size:64;uniqueIndex:idx_share_version,priority:1" json:"shareId"`
+	Version      int       `gorm:"uniqueIndex:idx_share_version,priority:2" json:"version"`
+	DocTitle     string    `gorm:"size:255" json:"docTitle"`
+	Content      string    `gorm:"type:text" json:"content"`
+	References   string    `gorm:"type:text" json:"references"`
+	RestoredFrom int       `gorm:"default:0" json:"restoredFrom,omitempty"` // Версия, из которой восстановлена ревизия (0 - обычная публикация)
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// TableName Указание имени таблицы
+func (ShareRevision) TableName() string {
+	return "share_revisions"
+}
+
+// ContentChanged Проверка, отличается ли новое содержимое от текущего содержимого публикации
+func (s *Share) ContentChanged(docTitle, content string) bool {
+	return s.DocTitle != docTitle || s.Content != content
+}
+
+// CreateShareRevision Сохранение снимка текущего состояния публикации под номером share.Revision;
+// references - ссылаемые блоки в JSON формата BlockReference
+func CreateShareRevision(db *gorm.DB, share *Share, references string, restoredFrom int) (*ShareRevision, error) {
+	rev := &ShareRevision{
+		ID:           "rev_" + randomHex(12),
+		ShareID:      share.ID,
+		Version:      share.Revision,
+		DocTitle:     share.DocTitle,
+		Content:      share.Content,
+		References:   references,
+		RestoredFrom: restoredFrom,
+	}
+	if err := db.Create(rev).Error; err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// RestoreShareRevision Публикация содержимого ревизии как новой ревизии (в одной транзакции
+// с восстановлением ссылаемых блоков); если содержимое не отличается, ничего не меняется
+func RestoreShareRevision(share *Share, rev *ShareRevision) error {
+	if !share.ContentChanged(rev.DocTitle, rev.Content) {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		refs, err := ReferencesFromJSON(tx, share.UserID, rev.References)
+		if err != nil {
+			return err
+		}
+		share.Revision++
+		share.DocTitle = rev.DocTitle
+		share.Content = rev.Content
+		if err := tx.Save(share).Error; err != nil {
+			return err
+		}
+		if err := ReplaceShareReferences(tx, share.ID, refs); err != nil {
+			return err
+		}
+		_, err = CreateShareRevision(tx, share, rev.References, rev.Version)
+		return err
+	})
+}
+
+// ListShareRevisions Список ревизий публикации (без содержимого), новые первыми
+func ListShareRevisions(shareID string) ([]ShareRevision, error) {
+	var revs []ShareRevision
+	err := DB.Select("id", "share_id", "version", "doc_title", "restored_from", "created_at").
+		Where("share_id = ?", shareID).
+		Order("version DESC").
+		Find(&revs).Error
+	return revs, err
+}
+
+// FindShareRevision Поиск ревизии публикации по номеру версии
+func FindShareRevision(shareID string, version int) (*ShareRevision, error) {
+	var rev ShareRevision
+	err := DB.Where("share_id = ? AND version = ?", shareID, version).First(&rev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// FindShareRevisionAt Поиск последней ревизии, созданной не позже указанного момента
+func FindShareRevisionAt(shareID string, at time.Time) (*ShareRevision, error) {
+	var rev ShareRevision
+	err := DB.Where("share_id = ? AND created_at <= ?", shareID, at).Order("version DESC").First(&rev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// backfillShareRevisions Создание первой ревизии для публикаций, созданных до появления истории версий
+func backfillShareRevisions() error {
+	var shares []Share
+	return DB.Where("revision = ?", 0).FindInBatches(&shares, 100, func(tx *gorm.DB, batch int) error {
+		for i := range shares {
+			share := &shares[i]
+			share.Revision = 1
+			var refs []ShareReference
+			if err := DB.Where("share_id = ?", share.ID).Order("position").Find(&refs).Error; err != nil {
+				return err
+			}
+			if _, err := CreateShareRevision(DB, share, ReferencesJSON(refs), 0); err != nil {
+				return err
+			}
+			if err := DB.Model(share).UpdateColumn("revision", 1).Error; err != nil {
+				return err
+			}
+		}
+		log.Printf("Share revisions backfilled: %d", len(shares))
+		return nil
+	}).Error
+}
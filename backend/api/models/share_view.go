@@ -0,0 +1,276 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DayLayout Формат суток (UTC) в событиях и суточных итогах просмотров
+const DayLayout = "2006-01-02"
+
+// Разрезы суточной статистики просмотров (ShareViewBreakdown.Kind)
+const (
+	ViewKindReferrer = "referrer" // Name - хост источника перехода, пусто - прямой переход
+	ViewKindUA       = "ua"       // Name - класс User-Agent
+	ViewKindBot      = "bot"      // Name - имя автоматического клиента (slack, google, prefetch, ...)
+)
+
+// ShareViewEvent Просмотр публикации. IP и User-Agent не сохраняются: посетитель представлен
+// хэшем с суточной солью, User-Agent - классом. Хранится VIEW_EVENT_RETENTION.
+// Bot - имя автоматического клиента, пусто - человек
+type ShareViewEvent struct {
+	ID           int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ShareID      string    `gorm:"size:64;index:idx_view_event_visitor,priority:1" json:"shareId"`
+	Day          string    `gorm:"size:10;index:idx_view_event_visitor,priority:2" json:"day"`
+	VisitorHash  string    `gorm:"size:32;index:idx_view_event_visitor,priority:3" json:"visitorHash"`
+	ReferrerHost string    `gorm:"size:255" json:"referrerHost"`
+	UAClass      string    `gorm:"size:16" json:"uaClass"`
+	Bot          string    `gorm:"size:32;not null;default:''" json:"bot,omitempty"`
+	CreatedAt    time.Time `gorm:"index" json:"createdAt"`
+}
+
+// ShareViewDaily Суточный итог просмотров публикации; Visitors - уникальные посетители за сутки,
+// BotViews - просмотры ботов, не вошедшие в Views
+type ShareViewDaily struct {
+	ShareID  string `gorm:"primaryKey;size:64"`
+	Day      string `gorm:"primaryKey;size:10"`
+	Views    int64
+	Visitors int64
+	BotViews int64 `gorm:"not null;default:0"`
+}
+
+// ShareViewBreakdown Суточное число просмотров публикации в разрезе источника, класса User-Agent
+// или имени бота
+type ShareViewBreakdown struct {
+	ShareID string `gorm:"primaryKey;size:64"`
+	Day     string `gorm:"primaryKey;size:10"`
+	Kind    string `gorm:"primaryKey;size:16"`
+	Name    string `gorm:"primaryKey;size:255"`
+	Views   int64
+}
+
+// ViewSalt Соль хэша посетителей за сутки. Соль прошедших суток удаляется, после чего хэши
+// нельзя сопоставить с IP-адресами перебором
+type ViewSalt struct {
+	Day       string `gorm:"primaryKey;size:10"`
+	Salt      string
+	CreatedAt time.Time
+}
+
+// ViewSaltFor Соль суток day (создаётся при первом обращении); соли прошедших суток удаляются
+func ViewSaltFor(day string) (string, error) {
+	var salt ViewSalt
+	res := DB.Where("day = ?", day).Limit(1).Find(&salt)
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if res.RowsAffected > 0 {
+		return salt.Salt, nil
+	}
+	// Параллельное создание: сохраняется первая соль, остальные перечитывают её
+	salt = ViewSalt{Day: day, Salt: randomHex(32), CreatedAt: time.Now()}
+	if err := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&salt).Error; err != nil {
+		return "", err
+	}
+	if err := DB.Where("day = ?", day).First(&salt).Error; err != nil {
+		return "", err
+	}
+	DB.Where("day < ?", day).Delete(&ViewSalt{})
+	return salt.Salt, nil
+}
+
+// viewBucket Итог пачки событий за сутки публикации
+type viewBucket struct {
+	views    int64
+	visitors int64
+	bots     int64
+}
+
+// RecordShareViews Запись пачки просмотров в одной транзакции: события, суточные итоги и разрезы,
+// увеличение счётчика ViewCount публикаций (атомарно, без чтения прежнего значения).
+// Просмотры ботов входят в разрез по имени бота; при countBots они считаются обычными просмотрами,
+// иначе - только в BotViews (отдельно от Views, посетителей, источников и ViewCount)
+func RecordShareViews(events []ShareViewEvent, countBots bool) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		buckets := map[[2]string]*viewBucket{}
+		breakdowns := map[ShareViewBreakdown]int64{}
+		counts := map[string]int64{}
+		seen := map[[3]string]bool{}
+		for _, e := range events {
+			key := [2]string{e.ShareID, e.Day}
+			b := buckets[key]
+			if b == nil {
+				b = &viewBucket{}
+				buckets[key] = b
+			}
+			breakdowns[ShareViewBreakdown{ShareID: e.ShareID, Day: e.Day, Kind: ViewKindUA, Name: e.UAClass}]++
+			if e.Bot != "" {
+				breakdowns[ShareViewBreakdown{ShareID: e.ShareID, Day: e.Day, Kind: ViewKindBot, Name: e.Bot}]++
+				if !countBots {
+					b.bots++
+					continue
+				}
+			}
+			b.views++
+			visitor := [3]string{e.ShareID, e.Day, e.VisitorHash}
+			if !seen[visitor] {
+				seen[visitor] = true
+				var known int64
+				if err := tx.Model(&ShareViewEvent{}).
+					Where("share_id = ? AND day = ? AND visitor_hash = ?", e.ShareID, e.Day, e.VisitorHash).
+					Limit(1).Count(&known).Error; err != nil {
+					return err
+				}
+				if known == 0 {
+					b.visitors++
+				}
+			}
+			breakdowns[ShareViewBreakdown{ShareID: e.ShareID, Day: e.Day, Kind: ViewKindReferrer, Name: e.ReferrerHost}]++
+			counts[e.ShareID]++
+		}
+
+		if err := tx.CreateInBatches(&events, 100).Error; err != nil {
+			return err
+		}
+		for key, b := range buckets {
+			row := ShareViewDaily{ShareID: key[0], Day: key[1], Views: b.views, Visitors: b.visitors, BotViews: b.bots}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "share_id"}, {Name: "day"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"views":     gorm.Expr("views + ?", b.views),
+					"visitors":  gorm.Expr("visitors + ?", b.visitors),
+					"bot_views": gorm.Expr("bot_views + ?", b.bots),
+				}),
+			}).Create(&row).Error; err != nil {
+				return err
+			}
+		}
+		for row, views := range breakdowns {
+			row.Views = views
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "share_id"}, {Name: "day"}, {Name: "kind"}, {Name: "name"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{"views": gorm.Expr("views + ?", views)}),
+			}).Create(&row).Error; err != nil {
+				return err
+			}
+		}
+		for shareID, n := range counts {
+			if err := tx.Unscoped().Model(&Share{}).Where("id = ?", shareID).
+				UpdateColumn("view_count", gorm.Expr("view_count + ?", n)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PurgeViewEvents Удаление событий просмотров старше cutoff (суточные итоги сохраняются)
+func PurgeViewEvents(cutoff time.Time) (int64, error) {
+	res := DB.Where("created_at < ?", cutoff).Delete(&ShareViewEvent{})
+	return res.RowsAffected, res.Error
+}
+
+// deleteShareViews Удаление просмотров публикаций shareIDs (при окончательном удалении публикаций)
+func deleteShareViews(tx *gorm.DB, shareIDs []string) error {
+	for _, model := range []interface{}{&ShareViewEvent{}, &ShareViewDaily{}, &ShareViewBreakdown{}} {
+		if err := tx.Where("share_id IN ?", shareIDs).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShareViewDay Просмотры публикации за сутки
+type ShareViewDay struct {
+	Day      string `json:"day"`
+	Views    int64  `json:"views"`
+	Visitors int64  `json:"visitors"`
+	BotViews int64  `json:"botViews"`
+}
+
+// ShareViewCount Число просмотров по значению разреза
+type ShareViewCount struct {
+	Name  string `json:"name"`
+	Views int64  `json:"views"`
+}
+
+// ShareStats Статистика просмотров публикации за период
+type ShareStats struct {
+	ShareID      string           `json:"shareId"`
+	ViewCount    int              `json:"viewCount"`    // Все просмотры за время существования публикации
+	BotViewCount int64            `json:"botViewCount"` // Все учтённые отдельно просмотры ботов
+	From         string           `json:"from"`
+	To           string           `json:"to"`
+	Views        int64            `json:"views"`
+	Visitors     int64            `json:"visitors"` // Сумма суточных уникальных посетителей
+	BotViews     int64            `json:"botViews"`
+	Series       []ShareViewDay   `json:"series"` // Все сутки периода, включая дни без просмотров
+	Referrers    []ShareViewCount `json:"referrers"`
+	UserAgents   []ShareViewCount `json:"userAgents"`
+	Bots         []ShareViewCount `json:"bots"`
+}
+
+// GetShareStats Статистика просмотров публикации за сутки from..to (UTC, включительно);
+// topReferrers - число источников в ответе
+func GetShareStats(share *Share, from, to time.Time, topReferrers int) (*ShareStats, error) {
+	st := &ShareStats{
+		ShareID:    share.ID,
+		ViewCount:  share.ViewCount,
+		From:       from.Format(DayLayout),
+		To:         to.Format(DayLayout),
+		Series:     []ShareViewDay{},
+		Referrers:  []ShareViewCount{},
+		UserAgents: []ShareViewCount{},
+		Bots:       []ShareViewCount{},
+	}
+
+	var rows []ShareViewDaily
+	if err := DB.Where("share_id = ? AND day BETWEEN ? AND ?", share.ID, st.From, st.To).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	byDay := make(map[string]ShareViewDaily, len(rows))
+	for _, r := range rows {
+		byDay[r.Day] = r
+	}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		day := d.Format(DayLayout)
+		r := byDay[day]
+		st.Series = append(st.Series, ShareViewDay{Day: day, Views: r.Views, Visitors: r.Visitors, BotViews: r.BotViews})
+		st.Views += r.Views
+		st.Visitors += r.Visitors
+		st.BotViews += r.BotViews
+	}
+	if err := DB.Model(&ShareViewDaily{}).Select("COALESCE(SUM(bot_views), 0)").
+		Where("share_id = ?", share.ID).Scan(&st.BotViewCount).Error; err != nil {
+		return nil, err
+	}
+
+	breakdown := func(kind string, limit int) ([]ShareViewCount, error) {
+		list := []ShareViewCount{}
+		q := DB.Model(&ShareViewBreakdown{}).
+			Select("name, SUM(views) AS views").
+			Where("share_id = ? AND kind = ? AND day BETWEEN ? AND ?", share.ID, kind, st.From, st.To).
+			Group("name").Order("views DESC, name")
+		if limit > 0 {
+			q = q.Limit(limit)
+		}
+		err := q.Scan(&list).Error
+		return list, err
+	}
+	var err error
+	if st.Referrers, err = breakdown(ViewKindReferrer, topReferrers); err != nil {
+		return nil, err
+	}
+	if st.UserAgents, err = breakdown(ViewKindUA, 0); err != nil {
+		return nil, err
+	}
+	if st.Bots, err = breakdown(ViewKindBot, 0); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
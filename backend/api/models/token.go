@@ -0,0 +1,184 @@
+package models
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrScopesNotGrantable Запрошены области действия, которые нельзя выдать
+var ErrScopesNotGrantable = errors.New("cannot grant scopes beyond those of the current token")
+
+// ErrTokenNotFound API токен не найден, отозван или принадлежит другому пользователю
+var ErrTokenNotFound = errors.New("token not found")
+
+// TokenInputError Некорректные параметры нового API токена (текст ошибки можно показать клиенту)
+type TokenInputError string
+
+func (e TokenInputError) Error() string { return string(e) }
+
+// TokenInput Параметры нового API токена
+type TokenInput struct {
+	UserID       string
+	Name         string
+	Scopes       []string   // nil - DefaultTokenScopes
+	MaxScopes    []string   // Области, которые разрешено выдать (токен не может выдать больше, чем есть у него)
+	ExpiresAt    *time.Time // nil - бессрочный (с учётом TOKEN_MAX_LIFETIME)
+	AllowedCIDRs []string
+	RotationDays int
+	// Parent API токен, которым выпускается новый (nil - сессия Web): новый токен не может
+	// действовать дольше него и из диапазонов IP за пределами его AllowedCIDRs
+	Parent *UserToken
+}
+
+// CreateUserToken Создание API токена по правилам политики токенов; текст токена возвращается
+// в PlainToken только здесь, в базе хранится хэш
+func CreateUserToken(in TokenInput) (*UserToken, error) {
+	scopes := DefaultTokenScopes
+	if in.Scopes != nil {
+		var err error
+		if scopes, err = NormalizeScopes(in.Scopes); err != nil {
+			return nil, TokenInputError("Invalid scopes: " + err.Error())
+		}
+		if len(scopes) == 0 {
+			return nil, TokenInputError("At least one scope is required")
+		}
+	}
+	if !HasScopes(in.MaxScopes, scopes...) {
+		return nil, ErrScopesNotGrantable
+	}
+
+	cidrs, err := ParseCIDRs(in.AllowedCIDRs)
+	if err != nil {
+		return nil, TokenInputError("Invalid allowedCidrs: " + err.Error())
+	}
+
+	expiresAt := in.ExpiresAt
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, TokenInputError("expiresAt must be in the future")
+	}
+	if p := in.Parent; p != nil {
+		if p.ExpiresAt != nil {
+			if expiresAt == nil {
+				expiresAt = p.ExpiresAt
+			} else if expiresAt.After(*p.ExpiresAt) {
+				return nil, TokenInputError("expiresAt must not be later than the expiry of the current token")
+			}
+		}
+		if parentCIDRs := p.CIDRList(); len(parentCIDRs) > 0 {
+			if len(cidrs) == 0 {
+				cidrs = parentCIDRs
+			} else if !cidrsWithin(cidrs, parentCIDRs) {
+				return nil, TokenInputError("allowedCidrs must be within the IP ranges of the current token")
+			}
+		}
+	}
+	// TOKEN_MAX_LIFETIME ограничивает срок действия и задаёт его для бессрочных токенов
+	if maxLifetime := TokenMaxLifetime(); maxLifetime > 0 {
+		limit := time.Now().Add(maxLifetime)
+		if expiresAt == nil {
+			expiresAt = &limit
+		} else if expiresAt.After(limit) {
+			return nil, TokenInputError("expiresAt exceeds the maximum token lifetime (" + maxLifetime.String() + ")")
+		}
+	}
+	if in.RotationDays < 0 {
+		return nil, TokenInputError("rotationDays must not be negative")
+	}
+
+	raw := randomHex(32)
+	ut := &UserToken{
+		ID:           "tok_" + randomHex(12),
+		UserID:       in.UserID,
+		Name:         in.Name,
+		TokenHash:    HashToken(raw),
+		ExpiresAt:    expiresAt,
+		AllowedCIDRs: strings.Join(cidrs, ","),
+		RotationDays: in.RotationDays,
+	}
+	ut.SetScopes(scopes)
+	if err := DB.Create(ut).Error; err != nil {
+		return nil, err
+	}
+	ut.PlainToken = raw
+	return ut, nil
+}
+
+// cidrsWithin Входит ли каждый диапазон cidrs целиком в один из диапазонов parents
+func cidrsWithin(cidrs, parents []string) bool {
+	for _, cidr := range cidrs {
+		_, child, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return false
+		}
+		childOnes, childBits := child.Mask.Size()
+		within := false
+		for _, pc := range parents {
+			_, parent, err := net.ParseCIDR(pc)
+			if err != nil {
+				continue
+			}
+			parentOnes, parentBits := parent.Mask.Size()
+			if childBits == parentBits && childOnes >= parentOnes && parent.Contains(child.IP) {
+				within = true
+				break
+			}
+		}
+		if !within {
+			return false
+		}
+	}
+	return true
+}
+
+// ListUserTokens Все API токены пользователя, включая отозванные и истёкшие, новые первыми
+func ListUserTokens(userID string) ([]UserToken, error) {
+	var tokens []UserToken
+	err := DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// RevokeUserToken Отзыв API токена; userID ограничивает владельца (пусто - любой, для администратора).
+// false, если токен не найден или уже отозван
+func RevokeUserToken(userID, tokenID string) (bool, error) {
+	q := DB.Model(&UserToken{}).Where("id = ? AND revoked = ?", tokenID, false)
+	if userID != "" {
+		q = q.Where("user_id = ?", userID)
+	}
+	res := q.Update("revoked", true)
+	return res.RowsAffected > 0, res.Error
+}
+
+// ReissueUserToken Ручное обновление API токена пользователя: новый текст (в PlainToken) при той же
+// записи и политике. Считается ротацией, но прежний текст сразу перестаёт действовать
+func ReissueUserToken(userID, tokenID string) (*UserToken, error) {
+	var ut UserToken
+	res := DB.Where("id = ? AND user_id = ? AND revoked = ?", tokenID, userID, false).Limit(1).Find(&ut)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrTokenNotFound
+	}
+	raw := randomHex(32)
+	now := time.Now()
+	res = DB.Model(&UserToken{}).Where("id = ? AND revoked = ?", ut.ID, false).Updates(map[string]interface{}{
+		"token_hash":       HashToken(raw),
+		"prev_token_hash":  "",
+		"prev_valid_until": nil,
+		"rotated_at":       now,
+	})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrTokenNotFound
+	}
+	ut.TokenHash = HashToken(raw)
+	ut.PrevTokenHash = ""
+	ut.PrevValidUntil = nil
+	ut.RotatedAt = &now
+	ut.PlainToken = raw
+	return &ut, nil
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// tokenRotationGrace Сколько после автоматической ротации принимается прежний текст токена
+// (TOKEN_ROTATION_GRACE, по умолчанию 24h), чтобы клиент успел сохранить новый
+func tokenRotationGrace() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("TOKEN_ROTATION_GRACE")); err == nil && d >= 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+// TokenMaxLifetime Максимальный срок жизни API токена (TOKEN_MAX_LIFETIME, 0 - без ограничения).
+// Если задан, токены без срока действия получают его автоматически.
+func TokenMaxLifetime() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("TOKEN_MAX_LIFETIME")); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
+// HashToken Хеш текста токена, который хранится в базе
+func HashToken(raw string) string {
+	h := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(h[:])
+}
+
+// ParseCIDRs Разбор списка разрешённых диапазонов; отдельный IP считается диапазоном /32 (/128)
+func ParseCIDRs(list []string) ([]string, error) {
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", item)
+			}
+			if ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", item)
+		}
+		out = append(out, ipNet.String())
+	}
+	return out, nil
+}
+
+// CIDRList Разрешённые диапазоны IP токена (пустой список - без ограничений)
+func (t *UserToken) CIDRList() []string {
+	if t.AllowedCIDRs == "" {
+		return []string{}
+	}
+	return strings.Split(t.AllowedCIDRs, ",")
+}
+
+// AllowsIP Разрешено ли использование токена с указанного IP
+func (t *UserToken) AllowsIP(clientIP string) bool {
+	if t.AllowedCIDRs == "" {
+		return true
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range t.CIDRList() {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExpired Истёк ли срок действия токена
+func (t *UserToken) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// RotationDue Пора ли автоматически сменить текст токена
+func (t *UserToken) RotationDue(now time.Time) bool {
+	if t.RotationDays <= 0 {
+		return false
+	}
+	last := t.CreatedAt
+	if t.RotatedAt != nil {
+		last = *t.RotatedAt
+	}
+	return !now.Before(last.AddDate(0, 0, t.RotationDays))
+}
+
+// FindUsableToken Поиск неотозванного токена по тексту; прежний текст после автоматической
+// ротации принимается до PrevValidUntil. usedPrev сообщает, что использован прежний текст.
+func FindUsableToken(raw string) (token *UserToken, usedPrev bool, err error) {
+	hash := HashToken(raw)
+	var ut UserToken
+	err = DB.Where("token_hash = ? AND revoked = ?", hash, false).First(&ut).Error
+	if err == nil {
+		return &ut, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+	err = DB.Where("prev_token_hash = ? AND revoked = ? AND prev_valid_until > ?", hash, false, time.Now()).First(&ut).Error
+	if err != nil {
+		return nil, false, err
+	}
+	return &ut, true, nil
+}
+
+// RotateToken Автоматическая ротация: новый текст токена, прежний действует ещё tokenRotationGrace.
+// Если токен уже сменён параллельным запросом, возвращается пустая строка.
+func RotateToken(t *UserToken) (string, error) {
+	raw := randomHex(32)
+	now := time.Now()
+	graceUntil := now.Add(tokenRotationGrace())
+	res := DB.Model(&UserToken{}).
+		Where("id = ? AND token_hash = ?", t.ID, t.TokenHash).
+		Updates(map[string]interface{}{
+			"token_hash":       HashToken(raw),
+			"prev_token_hash":  t.TokenHash,
+			"prev_valid_until": graceUntil,
+			"rotated_at":       now,
+		})
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if res.RowsAffected == 0 {
+		return "", nil
+	}
+	t.PrevTokenHash = t.TokenHash
+	t.TokenHash = HashToken(raw)
+	t.PrevValidUntil = &graceUntil
+	t.RotatedAt = &now
+	return raw, nil
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Области действия (scopes) API токенов
+const (
+	ScopeShareRead   = "share:read"   // Список публикаций и история версий
+	ScopeShareWrite  = "share:write"  // Создание/обновление публикаций, восстановление версий
+	ScopeShareDelete = "share:delete" // Удаление публикаций, в том числе массовое
+	ScopeTokenManage = "token:manage" // Управление API токенами
+	ScopeAdmin       = "admin"        // Администрирование (только для администраторов)
+)
+
+// AllScopes Все области действия; сессии Web (JWT) обладают всеми
+var AllScopes = []string{ScopeShareRead, ScopeShareWrite, ScopeShareDelete, ScopeTokenManage, ScopeAdmin}
+
+// DefaultTokenScopes Области действия нового токена, если они не указаны: всё, что нужно плагину SiYuan
+var DefaultTokenScopes = []string{ScopeShareRead, ScopeShareWrite, ScopeShareDelete}
+
+// UserScopes Области действия, доступные пользователю (сессии Web): admin - только администраторам
+func UserScopes(u *User) []string {
+	if u.IsAdmin {
+		return AllScopes
+	}
+	return legacyTokenScopes
+}
+
+// NormalizeScopes Проверка и упорядочивание списка областей действия (без дубликатов)
+func NormalizeScopes(scopes []string) ([]string, error) {
+	set := make(map[string]bool, len(scopes))
+	for _, s := range scopes {
+		s = strings.TrimSpace(s)
+		if !IsKnownScope(s) {
+			return nil, fmt.Errorf("unknown scope %q", s)
+		}
+		set[s] = true
+	}
+	out := make([]string, 0, len(set))
+	for _, s := range AllScopes {
+		if set[s] {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// IsKnownScope Проверка, что область действия существует
+func IsKnownScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScopes Все ли требуемые области действия есть в списке
+func HasScopes(granted []string, required ...string) bool {
+	for _, r := range required {
+		found := false
+		for _, g := range granted {
+			if g == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// ScopeList Области действия токена
+func (t *UserToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+// SetScopes Сохранение областей действия токена (через пробел, как в OAuth 2.0)
+func (t *UserToken) SetScopes(scopes []string) {
+	t.Scopes = strings.Join(scopes, " ")
+}
+
+// legacyTokenScopes Области действия, которые были у любого токена до их появления
+var legacyTokenScopes = []string{ScopeShareRead, ScopeShareWrite, ScopeShareDelete, ScopeTokenManage}
+
+// migrateTokenScopes Токены, созданные до появления областей действия, получают все прежние
+// возможности, чтобы существующие плагины продолжили работать
+func migrateTokenScopes() error {
+	return DB.Model(&UserToken{}).
+		Where("scopes IS NULL OR scopes = ''").
+		Update("scopes", strings.Join(legacyTokenScopes, " ")).Error
+}
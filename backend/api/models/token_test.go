@@ -0,0 +1,115 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCreateUserTokenLimitedByParentExpiry(t *testing.T) {
+	setupTestDB(t)
+	parentExpiry := time.Now().Add(time.Hour)
+	parent, err := CreateUserToken(TokenInput{UserID: "u1", Name: "parent", MaxScopes: AllScopes, ExpiresAt: &parentExpiry})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Без срока - наследуется срок родителя
+	child, err := CreateUserToken(TokenInput{UserID: "u1", Name: "child", MaxScopes: parent.ScopeList(), Parent: parent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.ExpiresAt == nil || !child.ExpiresAt.Equal(parentExpiry) {
+		t.Fatalf("child expiresAt = %v, want %v", child.ExpiresAt, parentExpiry)
+	}
+
+	// Срок позже родителя отклоняется
+	later := parentExpiry.Add(24 * time.Hour)
+	_, err = CreateUserToken(TokenInput{UserID: "u1", Name: "later", MaxScopes: parent.ScopeList(), ExpiresAt: &later, Parent: parent})
+	var inputErr TokenInputError
+	if !errors.As(err, &inputErr) {
+		t.Fatalf("later expiresAt: err = %v, want TokenInputError", err)
+	}
+
+	// Более ранний срок допустим
+	earlier := parentExpiry.Add(-30 * time.Minute)
+	if _, err := CreateUserToken(TokenInput{UserID: "u1", Name: "earlier", MaxScopes: parent.ScopeList(), ExpiresAt: &earlier, Parent: parent}); err != nil {
+		t.Fatalf("earlier expiresAt: %v", err)
+	}
+}
+
+func TestCreateUserTokenLimitedByParentCIDRs(t *testing.T) {
+	setupTestDB(t)
+	parent, err := CreateUserToken(TokenInput{UserID: "u1", Name: "parent", MaxScopes: AllScopes, AllowedCIDRs: []string{"10.0.0.0/16", "2001:db8::/32"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	child, err := CreateUserToken(TokenInput{UserID: "u1", Name: "inherit", MaxScopes: parent.ScopeList(), Parent: parent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := child.AllowedCIDRs; got != parent.AllowedCIDRs {
+		t.Fatalf("child allowedCidrs = %q, want %q", got, parent.AllowedCIDRs)
+	}
+	if child.AllowsIP("192.0.2.1") {
+		t.Fatal("child token allowed outside the parent ranges")
+	}
+
+	tests := []struct {
+		cidrs []string
+		ok    bool
+	}{
+		{[]string{"10.0.5.0/24"}, true},
+		{[]string{"10.0.1.7", "2001:db8:1::/48"}, true},
+		{[]string{"10.0.0.0/16"}, true},
+		{[]string{"10.0.0.0/8"}, false},
+		{[]string{"10.1.0.0/24"}, false},
+		{[]string{"10.0.5.0/24", "0.0.0.0/0"}, false},
+		{[]string{"::/0"}, false},
+	}
+	for _, tt := range tests {
+		_, err := CreateUserToken(TokenInput{UserID: "u1", Name: "child", MaxScopes: parent.ScopeList(), AllowedCIDRs: tt.cidrs, Parent: parent})
+		if (err == nil) != tt.ok {
+			t.Errorf("allowedCidrs %v: err = %v, want ok = %v", tt.cidrs, err, tt.ok)
+		}
+	}
+
+	// Без родителя (сессия Web) диапазоны не ограничены
+	if _, err := CreateUserToken(TokenInput{UserID: "u1", Name: "web", MaxScopes: AllScopes, AllowedCIDRs: []string{"0.0.0.0/0"}}); err != nil {
+		t.Fatalf("web session token: %v", err)
+	}
+}
+
+func TestReissueUserToken(t *testing.T) {
+	setupTestDB(t)
+	ut, err := CreateUserToken(TokenInput{UserID: "u1", Name: "plugin", MaxScopes: AllScopes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReissueUserToken("u2", ut.ID); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("other user: err = %v, want ErrTokenNotFound", err)
+	}
+
+	next, err := ReissueUserToken("u1", ut.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != ut.ID || next.PlainToken == "" || next.PlainToken == ut.PlainToken {
+		t.Fatalf("unexpected reissued token %+v", next)
+	}
+	// Прежний текст сразу перестаёт действовать
+	if _, _, err := FindUsableToken(ut.PlainToken); err == nil {
+		t.Fatal("previous token text still accepted")
+	}
+	if found, _, err := FindUsableToken(next.PlainToken); err != nil || found.ID != ut.ID {
+		t.Fatalf("new token text: %v, %v", found, err)
+	}
+
+	if _, err := RevokeUserToken("u1", ut.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReissueUserToken("u1", ut.ID); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("revoked token: err = %v, want ErrTokenNotFound", err)
+	}
+}
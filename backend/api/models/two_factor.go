@@ -0,0 +1,117 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mihazzz123/siyuan-share/totp"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount Число резервных кодов, выдаваемых за раз
+const recoveryCodeCount = 10
+
+// RecoveryCode Резервный код двухфакторной аутентификации (одноразовый, хранится только хэш)
+type RecoveryCode struct {
+	ID        string     `gorm:"primaryKey;size:64" json:"id"`
+	UserID    string     `gorm:"size:64;index" json:"userId"`
+	CodeHash  string     `gorm:"size:255;index" json:"-"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// TableName Указание имени таблицы
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
+
+// normalizeRecoveryCode Приведение кода к каноническому виду: без пробелов и дефисов, нижний регистр
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// GenerateRecoveryCodes Замена резервных кодов пользователя новым набором; коды возвращаются один раз
+func GenerateRecoveryCodes(userID string) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := randomHex(5)
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		rows = append(rows, RecoveryCode{
+			ID:       "rc_" + randomHex(12),
+			UserID:   userID,
+			CodeHash: HashToken(normalizeRecoveryCode(code)),
+		})
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode Погашение резервного кода; false, если код неверный или уже использован
+func UseRecoveryCode(userID, code string) bool {
+	res := DB.Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, HashToken(normalizeRecoveryCode(code))).
+		Limit(1).
+		Update("used_at", time.Now())
+	return res.Error == nil && res.RowsAffected > 0
+}
+
+// CountRecoveryCodes Число неиспользованных резервных кодов
+func CountRecoveryCodes(userID string) int64 {
+	var n int64
+	DB.Model(&RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&n)
+	return n
+}
+
+// VerifyTOTP Проверка кода TOTP пользователя с защитой от повторного использования кода
+func VerifyTOTP(user *User, code string) bool {
+	if !user.TOTPEnabled || user.TOTPSecret == "" {
+		return false
+	}
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return false
+	}
+	// Условное обновление: параллельный запрос с тем же кодом не пройдёт
+	res := DB.Model(&User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		UpdateColumn("totp_last_step", step)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false
+	}
+	user.TOTPLastStep = step
+	return true
+}
+
+// EnableTOTP Включение 2FA с подтверждённым секретом; step - шаг подтверждающего кода
+func EnableTOTP(user *User, secret string, step int64) error {
+	user.TOTPSecret = secret
+	user.TOTPPendingSecret = ""
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	return DB.Model(user).Select("totp_secret", "totp_pending_secret", "totp_enabled", "totp_last_step").Updates(user).Error
+}
+
+// DisableTOTP Отключение 2FA и удаление резервных кодов
+func DisableTOTP(user *User) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		user.TOTPSecret = ""
+		user.TOTPPendingSecret = ""
+		user.TOTPEnabled = false
+		user.TOTPLastStep = 0
+		if err := tx.Model(user).Select("totp_secret", "totp_pending_secret", "totp_enabled", "totp_last_step").Updates(user).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&RecoveryCode{}).Error
+	})
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -17,6 +18,19 @@ type User struct {
 	UpdatedAt    time.Time      `json:"updatedAt"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 	Tokens       []UserToken    `json:"-"` // Связанные API токены
+
+	// Двухфакторная аутентификация (TOTP): секрет, ожидающий подтверждения при настройке,
+	// и последний использованный шаг времени (защита от повторного использования кода)
+	TOTPSecret        string `gorm:"column:totp_secret;size:64" json:"-"`
+	TOTPPendingSecret string `gorm:"column:totp_pending_secret;size:64" json:"-"`
+	TOTPEnabled       bool   `gorm:"column:totp_enabled;default:false" json:"totpEnabled"`
+	TOTPLastStep      int64  `gorm:"column:totp_last_step;default:0" json:"-"`
+
+	// Администратор экземпляра (первый пользователь становится администратором автоматически)
+	IsAdmin bool `gorm:"default:false" json:"isAdmin"`
+
+	// Время подтверждения email (ссылкой из письма или провайдером OIDC), nil - не подтверждён
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
 }
 
 // TableName Указание имени таблицы
@@ -24,18 +38,45 @@ func (User) TableName() string {
 	return "users"
 }
 
+// MinPasswordLength Минимальная длина пароля
+const MinPasswordLength = 6
+
+// NewUserID Новый ID пользователя
+func NewUserID() string {
+	return "user_" + randomHex(16)
+}
+
+// HashPassword Хэш пароля (bcrypt) для хранения в PasswordHash
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// CheckPassword Совпадает ли пароль с сохранённым хэшем (у пользователей без пароля - никогда)
+func (u *User) CheckPassword(password string) bool {
+	return u.PasswordHash != "" && bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
 // UserToken API токены пользователя (поддержка нескольких токенов)
 type UserToken struct {
-	ID         string         `gorm:"primaryKey;size:64" json:"id"`
-	UserID     string         `gorm:"index;size:64" json:"userId"`
-	Name       string         `gorm:"size:100" json:"name"`          // Псевдоним токена для идентификации
-	TokenHash  string         `gorm:"size:255;uniqueIndex" json:"-"` // Хранение хэша во избежание утечки открытого текста
-	PlainToken string         `gorm:"-" json:"token,omitempty"`      // Возвращается только при создании/обновлении, не сохраняется в БД
-	Revoked    bool           `gorm:"default:false" json:"revoked"`  // Отозван ли
-	LastUsedAt *time.Time     `json:"lastUsedAt,omitempty"`
-	CreatedAt  time.Time      `json:"createdAt"`
-	UpdatedAt  time.Time      `json:"updatedAt"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	ID         string     `gorm:"primaryKey;size:64" json:"id"`
+	UserID     string     `gorm:"index;size:64" json:"userId"`
+	Name       string     `gorm:"size:100" json:"name"`          // Псевдоним токена для идентификации
+	TokenHash  string     `gorm:"size:255;uniqueIndex" json:"-"` // Хранение хэша во избежание утечки открытого текста
+	PlainToken string     `gorm:"-" json:"token,omitempty"`      // Возвращается только при создании/обновлении, не сохраняется в БД
+	Revoked    bool       `gorm:"default:false" json:"revoked"`  // Отозван ли
+	Scopes     string     `gorm:"size:255" json:"-"`             // Области действия через пробел (см. ScopeList)
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	// Политика токена (см. token_policy.go)
+	ExpiresAt      *time.Time     `json:"expiresAt,omitempty"`           // Срок действия, nil - бессрочный
+	AllowedCIDRs   string         `gorm:"size:1024" json:"-"`            // Разрешённые диапазоны IP через запятую, пусто - любые
+	RotationDays   int            `gorm:"default:0" json:"rotationDays"` // Период автоматической ротации, 0 - без ротации
+	RotatedAt      *time.Time     `json:"rotatedAt,omitempty"`           // Время последней смены текста токена
+	PrevTokenHash  string         `gorm:"size:255;index" json:"-"`       // Хэш прежнего текста после ротации
+	PrevValidUntil *time.Time     `json:"-"`                             // До какого времени принимается прежний текст
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (UserToken) TableName() string { return "user_tokens" }
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// ListUserIdentities Связи пользователя с учётными записями внешних провайдеров
+func ListUserIdentities(userID string) ([]UserIdentity, error) {
+	var identities []UserIdentity
+	err := DB.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+// ListSessionHistory Все сохранённые сессии пользователя, включая завершённые (до их удаления
+// фоновой очисткой), новые первыми
+func ListSessionHistory(userID string) ([]Session, error) {
+	var sessions []Session
+	err := DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// UserSharesInBatches Обход публикаций пользователя (без удалённых) пачками по size в порядке ID
+func UserSharesInBatches(userID string, size int, fn func(shares []Share) error) error {
+	var shares []Share
+	return DB.Where("user_id = ?", userID).
+		FindInBatches(&shares, size, func(tx *gorm.DB, batch int) error {
+			return fn(shares)
+		}).Error
+}
+
+// ReferencesByShares Ссылаемые блоки публикаций shareIDs, сгруппированные по публикации
+func ReferencesByShares(shareIDs []string) (map[string][]ShareReference, error) {
+	var refs []ShareReference
+	if err := DB.Where("share_id IN ?", shareIDs).Order("share_id, position").Find(&refs).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[string][]ShareReference, len(shareIDs))
+	for _, r := range refs {
+		out[r.ShareID] = append(out[r.ShareID], r)
+	}
+	return out, nil
+}
+
+// RevisionsByShares Ревизии публикаций shareIDs с содержимым, сгруппированные по публикации,
+// по возрастанию версии
+func RevisionsByShares(shareIDs []string) (map[string][]ShareRevision, error) {
+	var revs []ShareRevision
+	if err := DB.Where("share_id IN ?", shareIDs).Order("share_id, version").Find(&revs).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[string][]ShareRevision, len(shareIDs))
+	for _, r := range revs {
+		out[r.ShareID] = append(out[r.ShareID], r)
+	}
+	return out, nil
+}
@@ -0,0 +1,416 @@
+// Package oidc Вход через внешний провайдер OpenID Connect (authorization code + PKCE)
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// keysRefreshInterval Минимальный интервал повторной загрузки JWKS при неизвестном kid
+const keysRefreshInterval = time.Minute
+
+// Config Настройки провайдера (OIDC_CONFIG_FILE и/или переменные окружения)
+type Config struct {
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"clientId"`
+	ClientSecret string   `json:"clientSecret"`
+	RedirectURL  string   `json:"redirectUrl"` // https://share.example.com/api/auth/oidc/callback
+	Scopes       []string `json:"scopes"`
+	Name         string   `json:"name"` // Название провайдера на кнопке входа
+	// AutoProvision Создавать пользователя при первом входе, если нет учётной записи с таким email
+	AutoProvision bool `json:"autoProvision"`
+	// DisablePasswordLogin Отключить вход и регистрацию по паролю (действует только при настроенном OIDC)
+	DisablePasswordLogin bool `json:"disablePasswordLogin"`
+}
+
+// Enabled Заданы ли обязательные параметры провайдера
+func (c Config) Enabled() bool {
+	return c.Issuer != "" && c.ClientID != "" && c.RedirectURL != ""
+}
+
+// LoadConfig Чтение настроек: сначала JSON-файл OIDC_CONFIG_FILE, затем переменные окружения
+// OIDC_* (непустые значения переопределяют файл)
+func LoadConfig() (Config, error) {
+	var cfg Config
+	if path := os.Getenv("OIDC_CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("read OIDC_CONFIG_FILE: %w", err)
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("parse OIDC_CONFIG_FILE: %w", err)
+		}
+	}
+	for env, dst := range map[string]*string{
+		"OIDC_ISSUER":        &cfg.Issuer,
+		"OIDC_CLIENT_ID":     &cfg.ClientID,
+		"OIDC_CLIENT_SECRET": &cfg.ClientSecret,
+		"OIDC_REDIRECT_URL":  &cfg.RedirectURL,
+		"OIDC_NAME":          &cfg.Name,
+	} {
+		if v := strings.TrimSpace(os.Getenv(env)); v != "" {
+			*dst = v
+		}
+	}
+	if v := os.Getenv("OIDC_SCOPES"); v != "" {
+		cfg.Scopes = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	for env, dst := range map[string]*bool{
+		"OIDC_AUTO_PROVISION":     &cfg.AutoProvision,
+		"PASSWORD_LOGIN_DISABLED": &cfg.DisablePasswordLogin,
+	} {
+		if v := os.Getenv(env); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid %s: %q", env, v)
+			}
+			*dst = b
+		}
+	}
+
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	if cfg.Name == "" {
+		cfg.Name = "SSO"
+	}
+	return cfg, nil
+}
+
+// Identity Проверенные данные пользователя из ID токена (и userinfo)
+type Identity struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// metadata Документ discovery провайдера (/.well-known/openid-configuration)
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider Клиент провайдера OIDC: discovery и ключи JWKS кэшируются
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu          sync.Mutex
+	meta        *metadata
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+// Default Провайдер приложения, nil если OIDC не настроен (см. Init)
+var Default *Provider
+
+// Init Инициализация провайдера по умолчанию; без обязательных параметров OIDC отключён
+func Init(cfg Config) {
+	if !cfg.Enabled() {
+		Default = nil
+		if cfg.DisablePasswordLogin {
+			log.Printf("PASSWORD_LOGIN_DISABLED ignored: OIDC is not configured")
+		}
+		return
+	}
+	Default = NewProvider(cfg)
+	log.Printf("OIDC login enabled: issuer=%s auto-provision=%v password-login=%v",
+		cfg.Issuer, cfg.AutoProvision, !cfg.DisablePasswordLogin)
+}
+
+// NewProvider Создание провайдера (discovery выполняется при первом обращении)
+func NewProvider(cfg Config) *Provider {
+	return &Provider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Enabled Настроен ли вход через OIDC
+func Enabled() bool {
+	return Default != nil
+}
+
+// PasswordLoginAllowed Разрешён ли вход по паролю
+func PasswordLoginAllowed() bool {
+	return Default == nil || !Default.cfg.DisablePasswordLogin
+}
+
+// Config Настройки провайдера
+func (p *Provider) Config() Config {
+	return p.cfg
+}
+
+// RandomString Случайная строка для state, nonce и PKCE verifier
+func RandomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// discover Загрузка и кэширование документа discovery
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+	var meta metadata
+	if err := p.getJSON(ctx, p.cfg.Issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch %q", meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: incomplete provider metadata")
+	}
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// AuthCodeURL Адрес перехода к провайдеру для входа
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// tokenResponse Ответ token endpoint
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// idClaims Утверждения ID токена
+type idClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string      `json:"nonce"`
+	Email             string      `json:"email"`
+	EmailVerified     interface{} `json:"email_verified"` // bool или "true" у некоторых провайдеров
+	Name              string      `json:"name"`
+	PreferredUsername string      `json:"preferred_username"`
+}
+
+// Exchange Обмен кода авторизации на токены и проверка ID токена (подпись, issuer, audience, срок, nonce)
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token request: %w", err)
+	}
+	defer resp.Body.Close()
+	var tok tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("oidc token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || tok.Error != "" {
+		return nil, fmt.Errorf("oidc token request failed: %s %s", tok.Error, tok.ErrorDescription)
+	}
+	if tok.IDToken == "" {
+		return nil, errors.New("oidc token response has no id_token")
+	}
+
+	var claims idClaims
+	_, err = jwt.ParseWithClaims(tok.IDToken, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.verifyKey(ctx, meta, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("oidc id_token: %w", err)
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("oidc id_token: nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("oidc id_token: empty subject")
+	}
+
+	id := &Identity{
+		Issuer:            p.cfg.Issuer,
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     truthy(claims.EmailVerified),
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}
+	// Некоторые провайдеры не включают email в ID токен: запрос userinfo
+	if id.Email == "" && meta.UserinfoEndpoint != "" && tok.AccessToken != "" {
+		p.fillUserinfo(ctx, meta.UserinfoEndpoint, tok.AccessToken, id)
+	}
+	return id, nil
+}
+
+// fillUserinfo Дополнение данных из userinfo (только при совпадении subject)
+func (p *Provider) fillUserinfo(ctx context.Context, endpoint, accessToken string, id *Identity) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	var info struct {
+		Subject           string      `json:"sub"`
+		Email             string      `json:"email"`
+		EmailVerified     interface{} `json:"email_verified"`
+		Name              string      `json:"name"`
+		PreferredUsername string      `json:"preferred_username"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&info) != nil {
+		return
+	}
+	if info.Subject != id.Subject {
+		return
+	}
+	id.Email, id.EmailVerified = info.Email, truthy(info.EmailVerified)
+	if id.Name == "" {
+		id.Name = info.Name
+	}
+	if id.PreferredUsername == "" {
+		id.PreferredUsername = info.PreferredUsername
+	}
+}
+
+// verifyKey Открытый ключ провайдера по kid; при неизвестном kid JWKS загружается заново
+func (p *Provider) verifyKey(ctx context.Context, meta *metadata, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	lookup := func() interface{} {
+		if key, ok := p.keys[kid]; ok {
+			return key
+		}
+		// Без kid допустим единственный ключ
+		if kid == "" && len(p.keys) == 1 {
+			for _, key := range p.keys {
+				return key
+			}
+		}
+		return nil
+	}
+	if key := lookup(); key != nil {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < keysRefreshInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, meta.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	p.keys, p.keysFetched = keys, time.Now()
+	if key := lookup(); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// getJSON GET-запрос с разбором JSON
+func (p *Provider) getJSON(ctx context.Context, rawURL string, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: HTTP %d", rawURL, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dst)
+}
+
+// truthy email_verified как bool или строка
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case string:
+		b, _ := strconv.ParseBool(x)
+		return b
+	}
+	return false
+}
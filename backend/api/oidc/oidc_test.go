@@ -0,0 +1,97 @@
+package oidc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/mihazzz123/siyuan-share/oidc/oidctest"
+)
+
+const testClientID = "siyuan-share"
+
+// exchange Вход у тестового провайдера и обмен кода; nonce - ожидаемый при проверке ID токена
+func exchange(t *testing.T, iss *oidctest.Issuer, nonce string) (*Identity, error) {
+	t.Helper()
+	p := NewProvider(Config{Issuer: iss.URL, ClientID: testClientID, RedirectURL: "http://app.test/api/auth/oidc/callback"})
+	ctx := context.Background()
+	verifier := RandomString()
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce-1", verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, state, err := iss.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if state != "state" {
+		t.Fatalf("state = %q, want %q", state, "state")
+	}
+	return p.Exchange(ctx, code, verifier, nonce)
+}
+
+func TestExchange(t *testing.T) {
+	iss := oidctest.NewIssuer(testClientID)
+	defer iss.Close()
+
+	id, err := exchange(t, iss, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if id.Issuer != iss.URL || id.Subject != "subject-1" || id.Email != "user@example.com" || !id.EmailVerified {
+		t.Fatalf("unexpected identity %+v", id)
+	}
+}
+
+func TestExchangeRejectsInvalidIDToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		nonce  string
+		tamper func(jwt.MapClaims)
+		want   string
+	}{
+		{name: "nonce", nonce: "other-nonce", want: "nonce mismatch"},
+		{name: "missing nonce", nonce: "nonce-1", tamper: func(c jwt.MapClaims) { delete(c, "nonce") }, want: "nonce mismatch"},
+		{name: "audience", nonce: "nonce-1", tamper: func(c jwt.MapClaims) { c["aud"] = "other-client" }, want: "audience"},
+		{name: "issuer", nonce: "nonce-1", tamper: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, want: "issuer"},
+		{name: "expired", nonce: "nonce-1", tamper: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-2 * time.Minute).Unix() }, want: "expired"},
+		{name: "no expiry", nonce: "nonce-1", tamper: func(c jwt.MapClaims) { delete(c, "exp") }, want: "exp"},
+		{name: "empty subject", nonce: "nonce-1", tamper: func(c jwt.MapClaims) { c["sub"] = "" }, want: "empty subject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iss := oidctest.NewIssuer(testClientID)
+			defer iss.Close()
+			iss.SetTamper(tt.tamper)
+
+			id, err := exchange(t, iss, tt.nonce)
+			if err == nil {
+				t.Fatalf("Exchange accepted invalid token: %+v", id)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	iss := oidctest.NewIssuer(testClientID)
+	defer iss.Close()
+
+	p := NewProvider(Config{Issuer: iss.URL, ClientID: testClientID, RedirectURL: "http://app.test/cb"})
+	ctx := context.Background()
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce-1", RandomString())
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, _, err := iss.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if _, err := p.Exchange(ctx, code, RandomString(), "nonce-1"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("error = %v, want invalid_grant", err)
+	}
+}
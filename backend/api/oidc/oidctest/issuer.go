@@ -0,0 +1,215 @@
+// Package oidctest Провайдер OpenID Connect для тестов: discovery, JWKS, authorization и token
+// endpoint на httptest.Server. Коды авторизации выдаются без входа пользователя, ID токены
+// подписываются ключом RS256 провайдера
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// KeyID kid ключа подписи провайдера
+const KeyID = "test-key"
+
+// User Пользователь провайдера, для которого выдаются коды авторизации
+type User struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+}
+
+// authRequest Запрос авторизации, сохранённый до обмена кода
+type authRequest struct {
+	clientID  string
+	redirect  string
+	nonce     string
+	challenge string
+	user      User
+}
+
+// Issuer Тестовый провайдер для одного клиента
+type Issuer struct {
+	*httptest.Server
+	ClientID string
+
+	mu     sync.Mutex
+	key    *rsa.PrivateKey
+	codes  map[string]authRequest
+	user   User                       // Пользователь следующих запросов авторизации
+	tamper func(claims jwt.MapClaims) // Изменение утверждений ID токена перед подписью
+}
+
+// NewIssuer Запуск провайдера для клиента clientID; сервер останавливается вызовом Close
+func NewIssuer(clientID string) *Issuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	iss := &Issuer{
+		ClientID: clientID,
+		key:      key,
+		codes:    make(map[string]authRequest),
+		user:     User{Subject: "subject-1", Email: "user@example.com", EmailVerified: true, PreferredUsername: "user"},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", iss.discovery)
+	mux.HandleFunc("/jwks", iss.jwks)
+	mux.HandleFunc("/authorize", iss.authorize)
+	mux.HandleFunc("/token", iss.token)
+	iss.Server = httptest.NewServer(mux)
+	return iss
+}
+
+// SetUser Пользователь следующих запросов авторизации
+func (iss *Issuer) SetUser(u User) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	iss.user = u
+}
+
+// SetTamper Изменение утверждений следующих ID токенов перед подписью: неверные aud, iss, exp,
+// nonce (nil - без изменений)
+func (iss *Issuer) SetTamper(fn func(claims jwt.MapClaims)) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	iss.tamper = fn
+}
+
+// Authorize Переход по адресу входа authURL: код авторизации и state из перенаправления на redirect_uri
+func (iss *Issuer) Authorize(authURL string) (code, state string, err error) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return "", "", fmt.Errorf("authorize: HTTP %d", resp.StatusCode)
+	}
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", "", err
+	}
+	return loc.Query().Get("code"), loc.Query().Get("state"), nil
+}
+
+func (iss *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 iss.URL,
+		"authorization_endpoint": iss.URL + "/authorize",
+		"token_endpoint":         iss.URL + "/token",
+		"jwks_uri":               iss.URL + "/jwks",
+	})
+}
+
+func (iss *Issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := iss.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": KeyID,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+// authorize Выдача кода без входа пользователя: перенаправление на redirect_uri с code и state
+func (iss *Issuer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != iss.ClientID || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	code := randomString()
+	iss.mu.Lock()
+	iss.codes[code] = authRequest{
+		clientID:  q.Get("client_id"),
+		redirect:  q.Get("redirect_uri"),
+		nonce:     q.Get("nonce"),
+		challenge: q.Get("code_challenge"),
+		user:      iss.user,
+	}
+	iss.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token Обмен одноразового кода на ID токен с проверкой redirect_uri и PKCE verifier
+func (iss *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	iss.mu.Lock()
+	req, ok := iss.codes[r.PostForm.Get("code")]
+	delete(iss.codes, r.PostForm.Get("code"))
+	tamper := iss.tamper
+	iss.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != req.redirect || r.PostForm.Get("client_id") != req.clientID ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != req.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                iss.URL,
+		"aud":                req.clientID,
+		"sub":                req.user.Subject,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              req.nonce,
+		"email":              req.user.Email,
+		"email_verified":     req.user.EmailVerified,
+		"preferred_username": req.user.PreferredUsername,
+	}
+	if tamper != nil {
+		tamper(claims)
+	}
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = KeyID
+	signed, err := tok.SignedString(iss.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": randomString(), "token_type": "Bearer", "id_token": signed})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
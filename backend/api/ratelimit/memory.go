@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore Хранилище в памяти процесса, состояние теряется при перезапуске
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// NewMemoryStore Создание хранилища в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]Entry{}}
+}
+
+func (s *MemoryStore) Get(key string) (Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	return e, ok, nil
+}
+
+func (s *MemoryStore) Incr(key string, now, resetBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || e.LastFailure.Before(resetBefore) {
+		e = Entry{}
+	}
+	e.Failures++
+	e.LastFailure = now
+	s.entries[key] = e
+	return e.Failures, nil
+}
+
+func (s *MemoryStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok && e.LockedUntil.Before(until) {
+		e.LockedUntil = until
+		s.entries[key] = e
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) Cleanup(before time.Time) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, e := range s.entries {
+		if e.LastFailure.Before(before) && !e.LockedUntil.After(now) {
+			delete(s.entries, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Package ratelimit Защита от перебора паролей: учёт неудачных попыток по ключам
+// (публикация, имя пользователя, IP клиента) и блокировка с экспоненциальной задержкой
+package ratelimit
+
+import (
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Entry Состояние ключа: число неудачных попыток и время окончания блокировки
+type Entry struct {
+	Failures    int
+	LockedUntil time.Time
+	LastFailure time.Time
+}
+
+// Store Хранилище состояний ключей; реализации: MemoryStore и SQLStore
+type Store interface {
+	Get(key string) (Entry, bool, error)
+	// Incr Атомарный учёт неудачной попытки в now; если прошлая попытка была раньше resetBefore,
+	// счётчик и блокировка начинаются заново. Возвращает новое число попыток
+	Incr(key string, now, resetBefore time.Time) (int, error)
+	// Lock Блокировка ключа до until; более поздняя действующая блокировка не сокращается
+	Lock(key string, until time.Time) error
+	Delete(key string) error
+	// Cleanup Удаление ключей без неудачных попыток после before и без активной блокировки
+	Cleanup(before time.Time) error
+}
+
+// Policy Параметры блокировки
+type Policy struct {
+	Threshold   int           // Число неудачных попыток без блокировки
+	IPThreshold int           // То же для ключей IP (клиенты за NAT делят один адрес)
+	BaseDelay   time.Duration // Блокировка после первой попытки сверх порога, далее удваивается
+	MaxDelay    time.Duration // Максимальная длительность блокировки
+	ResetAfter  time.Duration // Счётчик сбрасывается, если попыток не было дольше этого времени
+}
+
+// DefaultPolicy Политика по умолчанию: 5 попыток (20 для IP), блокировка от 30 секунд до 1 часа
+func DefaultPolicy() Policy {
+	return Policy{
+		Threshold:   5,
+		IPThreshold: 20,
+		BaseDelay:   30 * time.Second,
+		MaxDelay:    time.Hour,
+		ResetAfter:  time.Hour,
+	}
+}
+
+func (p Policy) threshold(key string) int {
+	if strings.HasPrefix(key, "ip:") || strings.HasPrefix(key, "mail-ip:") {
+		return p.IPThreshold
+	}
+	return p.Threshold
+}
+
+// lockFor Длительность блокировки после failures неудачных попыток
+func (p Policy) lockFor(key string, failures int) time.Duration {
+	over := failures - p.threshold(key)
+	if over <= 0 {
+		return 0
+	}
+	d := float64(p.BaseDelay) * math.Pow(2, float64(over-1))
+	if d > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	return time.Duration(d)
+}
+
+// Limiter Учёт неудачных попыток поверх Store
+type Limiter struct {
+	store  Store
+	policy Policy
+
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+// NewLimiter Создание ограничителя с указанным хранилищем и политикой
+func NewLimiter(store Store, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy, lastCleanup: time.Now()}
+}
+
+// Check Оставшееся время блокировки (максимум по всем ключам), 0 - попытка разрешена
+func (l *Limiter) Check(keys ...string) time.Duration {
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range keys {
+		e, ok, err := l.store.Get(key)
+		if err != nil {
+			log.Printf("ratelimit: get %s: %v", key, err)
+			continue
+		}
+		if ok && e.LockedUntil.After(now) {
+			if d := e.LockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+// Fail Регистрация неудачной попытки; возвращает время блокировки, если она наступила
+func (l *Limiter) Fail(keys ...string) time.Duration {
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range keys {
+		// Счётчик увеличивается атомарно: параллельные попытки не теряют друг друга
+		failures, err := l.store.Incr(key, now, now.Add(-l.policy.ResetAfter))
+		if err != nil {
+			log.Printf("ratelimit: incr %s: %v", key, err)
+			continue
+		}
+		if d := l.policy.lockFor(key, failures); d > 0 {
+			if err := l.store.Lock(key, now.Add(d)); err != nil {
+				log.Printf("ratelimit: lock %s: %v", key, err)
+			}
+			if d > wait {
+				wait = d
+			}
+		}
+	}
+	l.maybeCleanup(now)
+	return wait
+}
+
+// Success Сброс счётчиков после успешной попытки. Ключи IP сбрасывать не следует,
+// иначе перебор можно чередовать с входом в собственный аккаунт.
+func (l *Limiter) Success(keys ...string) {
+	for _, key := range keys {
+		if err := l.store.Delete(key); err != nil {
+			log.Printf("ratelimit: delete %s: %v", key, err)
+		}
+	}
+}
+
+// maybeCleanup Периодическая очистка устаревших ключей (не чаще раза в 10 минут)
+func (l *Limiter) maybeCleanup(now time.Time) {
+	l.mu.Lock()
+	if now.Sub(l.lastCleanup) < 10*time.Minute {
+		l.mu.Unlock()
+		return
+	}
+	l.lastCleanup = now
+	l.mu.Unlock()
+
+	if err := l.store.Cleanup(now.Add(-l.policy.ResetAfter)); err != nil {
+		log.Printf("ratelimit: cleanup: %v", err)
+	}
+}
+
+// ShareKey Ключ пароля публикации
+func ShareKey(shareID string) string { return "share:" + shareID }
+
+// UserKey Ключ входа пользователя
+func UserKey(username string) string { return "user:" + strings.ToLower(username) }
+
+// EmailKey Ключ запросов писем на адрес (сброс пароля, повторное подтверждение)
+func EmailKey(email string) string { return "email:" + strings.ToLower(email) }
+
+// MailIPKey Ключ отправки писем по запросам с IP клиента; отдельно от IPKey, чтобы запросы
+// сброса пароля не блокировали вход и ввод паролей публикаций
+func MailIPKey(ip string) string { return "mail-ip:" + ip }
+
+// IPKey Ключ IP клиента
+func IPKey(ip string) string { return "ip:" + ip }
+
+// Default Глобальный ограничитель, настраивается Init
+var Default = NewLimiter(NewMemoryStore(), DefaultPolicy())
+
+// Init Настройка глобального ограничителя из переменных окружения:
+// RATE_LIMIT_STORE=memory|sqlite, RATE_LIMIT_THRESHOLD, RATE_LIMIT_IP_THRESHOLD,
+// RATE_LIMIT_BASE_DELAY, RATE_LIMIT_MAX_DELAY, RATE_LIMIT_RESET_AFTER
+func Init(db *gorm.DB) error {
+	policy := DefaultPolicy()
+	if v, err := strconv.Atoi(os.Getenv("RATE_LIMIT_THRESHOLD")); err == nil && v > 0 {
+		policy.Threshold = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("RATE_LIMIT_IP_THRESHOLD")); err == nil && v > 0 {
+		policy.IPThreshold = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("RATE_LIMIT_BASE_DELAY")); err == nil && v > 0 {
+		policy.BaseDelay = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("RATE_LIMIT_MAX_DELAY")); err == nil && v > 0 {
+		policy.MaxDelay = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("RATE_LIMIT_RESET_AFTER")); err == nil && v > 0 {
+		policy.ResetAfter = v
+	}
+
+	var store Store = NewMemoryStore()
+	if strings.ToLower(os.Getenv("RATE_LIMIT_STORE")) == "sqlite" {
+		s, err := NewSQLStore(db)
+		if err != nil {
+			return err
+		}
+		store = s
+	}
+	Default = NewLimiter(store, policy)
+	return nil
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// rateLimitEntry Строка таблицы rate_limits
+type rateLimitEntry struct {
+	Key         string    `gorm:"primaryKey;size:255"`
+	Failures    int       `gorm:"default:0"`
+	LockedUntil time.Time `gorm:"index"`
+	LastFailure time.Time `gorm:"index"`
+}
+
+func (rateLimitEntry) TableName() string { return "rate_limits" }
+
+// SQLStore Хранилище в базе данных: блокировки переживают перезапуск сервиса
+type SQLStore struct {
+	db *gorm.DB
+}
+
+// NewSQLStore Создание хранилища в БД с миграцией таблицы rate_limits
+func NewSQLStore(db *gorm.DB) (*SQLStore, error) {
+	if err := db.AutoMigrate(&rateLimitEntry{}); err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db}, nil
+}
+
+func (s *SQLStore) Get(key string) (Entry, bool, error) {
+	var row rateLimitEntry
+	err := s.db.Where("key = ?", key).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, err
+	}
+	return Entry{Failures: row.Failures, LockedUntil: row.LockedUntil, LastFailure: row.LastFailure}, true, nil
+}
+
+// Incr Вставка или увеличение счётчика одним запросом INSERT ... ON CONFLICT DO UPDATE ... RETURNING
+func (s *SQLStore) Incr(key string, now, resetBefore time.Time) (int, error) {
+	var failures int
+	err := s.db.Raw(`INSERT INTO rate_limits ("key", failures, locked_until, last_failure) VALUES (?, 1, ?, ?)
+		ON CONFLICT ("key") DO UPDATE SET
+			failures = CASE WHEN rate_limits.last_failure < ? THEN 1 ELSE rate_limits.failures + 1 END,
+			locked_until = CASE WHEN rate_limits.last_failure < ? THEN excluded.locked_until ELSE rate_limits.locked_until END,
+			last_failure = excluded.last_failure
+		RETURNING failures`,
+		key, time.Time{}, now, resetBefore, resetBefore).Scan(&failures).Error
+	return failures, err
+}
+
+func (s *SQLStore) Lock(key string, until time.Time) error {
+	return s.db.Model(&rateLimitEntry{}).Where("key = ? AND locked_until < ?", key, until).
+		Update("locked_until", until).Error
+}
+
+func (s *SQLStore) Delete(key string) error {
+	return s.db.Where("key = ?", key).Delete(&rateLimitEntry{}).Error
+}
+
+func (s *SQLStore) Cleanup(before time.Time) error {
+	return s.db.Where("last_failure < ? AND locked_until <= ?", before, time.Now()).Delete(&rateLimitEntry{}).Error
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"embed"
 	"io/fs"
+	"log"
 	"mime"
 	"net/http"
 	"os"
@@ -27,6 +28,18 @@ func SetupRouter(staticFiles *embed.FS) *gin.Engine {
 		r.Use(gin.Logger())
 	}
 
+	// Доверенные прокси для определения IP клиента (TRUSTED_PROXIES через запятую).
+	// Без настройки Gin доверяет X-Forwarded-For от любого источника.
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		list := strings.Split(proxies, ",")
+		for i := range list {
+			list[i] = strings.TrimSpace(list[i])
+		}
+		if err := r.SetTrustedProxies(list); err != nil {
+			log.Printf("Invalid TRUSTED_PROXIES: %v", err)
+		}
+	}
+
 	// Отключение автоматического редиректа, чтобы избежать 301 на корневом пути
 	r.RedirectTrailingSlash = false
 	r.RedirectFixedPath = false
@@ -99,6 +112,14 @@ func SetupRouter(staticFiles *embed.FS) *gin.Engine {
 					}
 				}
 
+				// Страница публикации: index.html с метаданными Open Graph/SEO
+				if shareID, ok := strings.CutPrefix(cleaned, "s/"); ok && shareID != "" && !strings.Contains(shareID, "/") {
+					if index, err := fs.ReadFile(distFS, "index.html"); err == nil {
+						controllers.ServeSharePage(c, shareID, index)
+						return
+					}
+				}
+
 				// все остальные путивозврат index.html（SPA маршрут）
 				serveFile("index.html")
 			})
@@ -123,6 +144,31 @@ func SetupRouter(staticFiles *embed.FS) *gin.Engine {
 		// Регистрация и вход (без аутентификации)
 		api.POST("/auth/register", controllers.Register)
 		api.POST("/auth/login", controllers.Login)
+		api.POST("/auth/login/2fa", controllers.LoginTwoFactor)
+		api.POST("/auth/refresh", controllers.RefreshSession)
+		api.GET("/auth/jwks", controllers.JWKS)
+
+		// Подтверждение email и сброс пароля по ссылкам из писем
+		api.POST("/auth/verify", controllers.VerifyEmail)
+		api.POST("/auth/forgot", controllers.ForgotPassword)
+		api.POST("/auth/reset", controllers.ResetPassword)
+
+		// Вход через внешний провайдер OpenID Connect
+		api.GET("/auth/methods", controllers.AuthMethods)
+		api.GET("/auth/oidc/login", controllers.OIDCLogin)
+		api.GET("/auth/oidc/callback", controllers.OIDCCallback)
+
+		// Управление сессиями Web; кроме выхода - только из сессии Web, не по API токену
+		auth := api.Group("/auth")
+		auth.Use(middleware.AuthMiddleware())
+		{
+			auth.POST("/logout", controllers.Logout)
+
+			web := auth.Group("", middleware.SessionRequired())
+			web.POST("/logout-all", controllers.LogoutAll)
+			web.GET("/sessions", controllers.ListSessions)
+			web.DELETE("/sessions/:id", controllers.RevokeSession)
+		}
 
 		// Проверка здоровья (требуется аутентификация, для тестирования API токена)
 		api.GET("/auth/health", middleware.AuthMiddleware(), func(c *gin.Context) {
@@ -138,25 +184,71 @@ func SetupRouter(staticFiles *embed.FS) *gin.Engine {
 			})
 		})
 
-		// Интерфейсы управления публикациями с аутентификацией
+		// Интерфейсы управления публикациями с аутентификацией;
+		// API токену нужны области действия share:read / share:write / share:delete
 		share := api.Group("/share")
-		share.Use(middleware.AuthMiddleware())
 		{
-			share.POST("/create", controllers.CreateShare)
-			share.GET("/list", controllers.ListShares)
-			share.DELETE("/batch", controllers.DeleteSharesBatch)
-			share.DELETE(":id", controllers.DeleteShare)
+			shareRead := share.Group("", middleware.AuthMiddleware(models.ScopeShareRead))
+			shareRead.GET("/list", controllers.ListShares)
+			shareRead.GET("/:id/stats", controllers.GetShareStats)
+			shareRead.GET("/:id/revisions", controllers.ListShareRevisions)
+			shareRead.GET("/:id/revisions/:rev", controllers.GetShareRevision)
+
+			shareWrite := share.Group("", middleware.AuthMiddleware(models.ScopeShareWrite))
+			shareWrite.POST("/create", controllers.CreateShare)
+			shareWrite.POST("/import", controllers.ImportShares)
+			shareWrite.POST("/:id/revisions/:rev/restore", controllers.RestoreShareRevision)
+
+			shareDelete := share.Group("", middleware.AuthMiddleware(models.ScopeShareDelete))
+			shareDelete.DELETE("/batch", controllers.DeleteSharesBatch)
+			shareDelete.DELETE(":id", controllers.DeleteShare)
+		}
+
+		// Администрирование: сессия администратора или API токен с областью admin
+		admin := api.Group("/admin", middleware.AuthMiddleware(models.ScopeAdmin), middleware.AdminRequired())
+		{
+			admin.GET("/invites", controllers.ListInvites)
+			admin.POST("/invites", controllers.CreateInvite)
+			admin.DELETE("/invites/:id", controllers.RevokeInvite)
+
+			admin.GET("/stats", controllers.AdminStats)
+			admin.GET("/users", controllers.AdminListUsers)
+			admin.GET("/users/:id", controllers.AdminGetUser)
+			admin.PUT("/users/:id", controllers.AdminUpdateUser)
+			admin.POST("/users/:id/password", controllers.AdminResetPassword)
+			admin.GET("/users/:id/tokens", controllers.AdminListUserTokens)
+			admin.DELETE("/tokens/:id", controllers.AdminRevokeToken)
+			admin.GET("/shares", controllers.AdminListShares)
+			admin.GET("/shares/:id", controllers.AdminGetShare)
+			admin.DELETE("/shares/:id", controllers.AdminDeleteShare)
+			admin.GET("/audit", controllers.AdminListAudit)
 		}
 
 		user := api.Group("/user")
 		user.Use(middleware.AuthMiddleware())
 		{
 			user.GET("/me", controllers.Me)
+			user.POST("/verify-email", controllers.ResendVerification)
+			user.GET("/2fa", controllers.GetTwoFactorStatus)
+
+			// Настройки аккаунта, выгрузка данных и журнал - только из сессии Web, не по API токену
+			web := user.Group("", middleware.SessionRequired())
+			web.PUT("/me", controllers.UpdateProfile)
+			web.DELETE("/me", controllers.DeleteAccount)
+			web.POST("/password", controllers.ChangePassword)
+			web.GET("/export", controllers.ExportUserData)
+			web.GET("/audit", controllers.ListUserAudit)
+
+			// Двухфакторная аутентификация
+			web.POST("/2fa/setup", controllers.SetupTwoFactor)
+			web.POST("/2fa/enable", controllers.EnableTwoFactor)
+			web.POST("/2fa/disable", controllers.DisableTwoFactor)
+			web.POST("/2fa/recovery-codes", controllers.RegenerateRecoveryCodes)
 		}
 
-		// Конечные точки управления токенами (требуется аутентификация)
+		// Конечные точки управления токенами (сессия Web или API токен с token:manage)
 		token := api.Group("/token")
-		token.Use(middleware.AuthMiddleware())
+		token.Use(middleware.AuthMiddleware(models.ScopeTokenManage))
 		{
 			token.GET("/list", controllers.ListTokens)
 			token.POST("/create", controllers.CreateToken)
@@ -166,6 +258,8 @@ func SetupRouter(staticFiles *embed.FS) *gin.Engine {
 
 		// Публичный интерфейс просмотра публикаций
 		api.GET("/s/:id", controllers.GetShare)
+		api.GET("/s/:id/diff", controllers.GetShareDiff)
+		api.POST("/s/:id/unlock", controllers.UnlockShare)
 	}
 
 	return r
@@ -0,0 +1,175 @@
+package userdata
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+// exportBatchSize Число публикаций, загружаемых за один запрос при экспорте
+const exportBatchSize = 100
+
+// Export Запись архива данных пользователя в w. Содержимое публикаций читается из базы
+// пачками и сразу пишется в архив, манифест записывается последним
+func Export(w io.Writer, userID string) error {
+	user, err := models.FindUser(userID)
+	if err != nil {
+		return err
+	}
+	manifest := Manifest{
+		Format:     Format,
+		Version:    Version,
+		ExportedAt: time.Now().UTC(),
+		User: Profile{
+			ID:              user.ID,
+			Username:        user.Username,
+			Email:           user.Email,
+			EmailVerifiedAt: user.EmailVerifiedAt,
+			IsAdmin:         user.IsAdmin,
+			TOTPEnabled:     user.TOTPEnabled,
+			CreatedAt:       user.CreatedAt,
+			UpdatedAt:       user.UpdatedAt,
+		},
+		Identities: []Identity{},
+		Sessions:   []Session{},
+		Tokens:     []Token{},
+		Shares:     []Share{},
+	}
+
+	identities, err := models.ListUserIdentities(user.ID)
+	if err != nil {
+		return err
+	}
+	for _, i := range identities {
+		manifest.Identities = append(manifest.Identities, Identity{
+			Issuer: i.Issuer, Subject: i.Subject, Email: i.Email, LastLoginAt: i.LastLoginAt, CreatedAt: i.CreatedAt,
+		})
+	}
+	sessions, err := models.ListSessionHistory(user.ID)
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		manifest.Sessions = append(manifest.Sessions, Session{
+			ID: s.ID, UserAgent: s.UserAgent, IP: s.IP, CreatedAt: s.CreatedAt, LastSeenAt: s.LastSeenAt, ExpiresAt: s.ExpiresAt, RevokedAt: s.RevokedAt,
+		})
+	}
+	tokens, err := models.ListUserTokens(user.ID)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		manifest.Tokens = append(manifest.Tokens, Token{
+			ID: t.ID, Name: t.Name, Scopes: t.ScopeList(), Revoked: t.Revoked, ExpiresAt: t.ExpiresAt, AllowedCIDRs: t.CIDRList(),
+			RotationDays: t.RotationDays, RotatedAt: t.RotatedAt, LastUsedAt: t.LastUsedAt, CreatedAt: t.CreatedAt,
+		})
+	}
+
+	zw := zip.NewWriter(w)
+	err = models.UserSharesInBatches(user.ID, exportBatchSize, func(shares []models.Share) error {
+		ids := make([]string, 0, len(shares))
+		for _, s := range shares {
+			ids = append(ids, s.ID)
+		}
+		refs, err := models.ReferencesByShares(ids)
+		if err != nil {
+			return err
+		}
+		revs, err := models.RevisionsByShares(ids)
+		if err != nil {
+			return err
+		}
+		for _, s := range shares {
+			entry, err := exportShare(zw, &s, refs[s.ID], revs[s.ID])
+			if err != nil {
+				return err
+			}
+			manifest.Shares = append(manifest.Shares, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: ManifestName, Method: zip.Deflate, Modified: manifest.ExportedAt})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// exportShare Запись содержимого публикации и её версий в архив, возвращает запись манифеста
+func exportShare(zw *zip.Writer, s *models.Share, refs []models.ShareReference, revs []models.ShareRevision) (Share, error) {
+	entry := Share{
+		ID:              s.ID,
+		DocID:           s.DocID,
+		DocTitle:        s.DocTitle,
+		ContentFile:     shareContentFile(s.ID),
+		ParentShareID:   s.ParentShareID,
+		RequirePassword: s.RequirePassword,
+		PasswordHash:    s.PasswordHash,
+		IsPublic:        s.IsPublic,
+		ExpireAt:        s.ExpireAt,
+		ViewCount:       s.ViewCount,
+		Revision:        s.Revision,
+		CreatedAt:       s.CreatedAt,
+		UpdatedAt:       s.UpdatedAt,
+	}
+	if err := writeFile(zw, entry.ContentFile, s.Content, s.UpdatedAt); err != nil {
+		return entry, err
+	}
+	for _, r := range refs {
+		entry.References = append(entry.References, Reference{
+			BlockID: r.BlockID, ChildShareID: r.ChildShareID, Content: r.Content, DisplayText: r.DisplayText, RefCount: r.RefCount,
+		})
+	}
+	for _, rev := range revs {
+		item := Revision{
+			Version:      rev.Version,
+			DocTitle:     rev.DocTitle,
+			ContentFile:  revisionContentFile(s.ID, rev.Version),
+			References:   revisionReferences(rev.References),
+			RestoredFrom: rev.RestoredFrom,
+			CreatedAt:    rev.CreatedAt,
+		}
+		if err := writeFile(zw, item.ContentFile, rev.Content, rev.CreatedAt); err != nil {
+			return entry, err
+		}
+		entry.Revisions = append(entry.Revisions, item)
+	}
+	return entry, nil
+}
+
+// revisionReferences Ссылаемые блоки снимка ревизии (JSON формата BlockReference)
+func revisionReferences(refsJSON string) []Reference {
+	if refsJSON == "" {
+		return nil
+	}
+	var blocks []models.BlockReference
+	if err := json.Unmarshal([]byte(refsJSON), &blocks); err != nil {
+		return nil
+	}
+	out := make([]Reference, 0, len(blocks))
+	for _, b := range blocks {
+		out = append(out, Reference{BlockID: b.BlockID, Content: b.Content, DisplayText: b.DisplayText, RefCount: b.RefCount})
+	}
+	return out
+}
+
+func writeFile(zw *zip.Writer, name, content string, modified time.Time) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, content)
+	return err
+}
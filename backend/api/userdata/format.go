@@ -0,0 +1,123 @@
+// Package userdata Архив данных пользователя (zip): manifest.json с профилем, метаданными
+// API токенов и публикаций и файлы содержимого публикаций в Markdown. Архив выдаёт экспорт
+// данных (GET /api/user/export) и принимает импорт публикаций
+package userdata
+
+import (
+	"strconv"
+	"time"
+)
+
+// Формат архива
+const (
+	Format       = "siyuan-share-export" // Значение поля format манифеста
+	Version      = 1                     // Текущая версия формата
+	ManifestName = "manifest.json"       // Имя файла манифеста в корне архива
+)
+
+// Manifest Описание архива; пути файлов (contentFile) указаны относительно корня архива
+type Manifest struct {
+	Format     string     `json:"format"`
+	Version    int        `json:"version"`
+	ExportedAt time.Time  `json:"exportedAt"`
+	User       Profile    `json:"user"`
+	Identities []Identity `json:"identities"`
+	Sessions   []Session  `json:"sessions"`
+	Tokens     []Token    `json:"tokens"`
+	Shares     []Share    `json:"shares"`
+}
+
+// Profile Профиль пользователя
+type Profile struct {
+	ID              string     `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	IsAdmin         bool       `json:"isAdmin"`
+	TOTPEnabled     bool       `json:"totpEnabled"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+// Identity Связанная учётная запись внешнего провайдера (OIDC)
+type Identity struct {
+	Issuer      string     `json:"issuer"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// Session Сессия входа в Web (без refresh токенов)
+type Session struct {
+	ID         string     `json:"id"`
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Token Метаданные API токена; текст и хэш токена в архив не попадают
+type Token struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Scopes       []string   `json:"scopes"`
+	Revoked      bool       `json:"revoked"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+	AllowedCIDRs []string   `json:"allowedCidrs,omitempty"`
+	RotationDays int        `json:"rotationDays,omitempty"`
+	RotatedAt    *time.Time `json:"rotatedAt,omitempty"`
+	LastUsedAt   *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// Share Публикация; содержимое текущей версии - в файле contentFile (shares/<id>.md)
+type Share struct {
+	ID              string      `json:"id"`
+	DocID           string      `json:"docId"`
+	DocTitle        string      `json:"docTitle"`
+	ContentFile     string      `json:"contentFile"`
+	ParentShareID   string      `json:"parentShareId,omitempty"` // Публикация, для которой опубликован ссылаемый блок
+	RequirePassword bool        `json:"requirePassword"`
+	PasswordHash    string      `json:"passwordHash,omitempty"` // bcrypt-хэш пароля публикации, чтобы импорт сохранил защиту
+	IsPublic        bool        `json:"isPublic"`
+	ExpireAt        time.Time   `json:"expireAt"`
+	ViewCount       int         `json:"viewCount"`
+	Revision        int         `json:"revision"`
+	CreatedAt       time.Time   `json:"createdAt"`
+	UpdatedAt       time.Time   `json:"updatedAt"`
+	References      []Reference `json:"references,omitempty"`
+	Revisions       []Revision  `json:"revisions,omitempty"`
+}
+
+// Reference Ссылаемый блок публикации и публикация этого блока
+type Reference struct {
+	BlockID      string `json:"blockId"`
+	ChildShareID string `json:"childShareId,omitempty"`
+	Content      string `json:"content"`
+	DisplayText  string `json:"displayText,omitempty"`
+	RefCount     int    `json:"refCount,omitempty"`
+}
+
+// Revision Версия из истории публикации; содержимое - в файле contentFile
+// (shares/<id>/v<version>.md)
+type Revision struct {
+	Version      int         `json:"version"`
+	DocTitle     string      `json:"docTitle"`
+	ContentFile  string      `json:"contentFile"`
+	References   []Reference `json:"references,omitempty"`
+	RestoredFrom int         `json:"restoredFrom,omitempty"`
+	CreatedAt    time.Time   `json:"createdAt"`
+}
+
+// shareContentFile Путь файла содержимого публикации в архиве
+func shareContentFile(shareID string) string {
+	return "shares/" + shareID + ".md"
+}
+
+// revisionContentFile Путь файла содержимого версии публикации в архиве
+func revisionContentFile(shareID string, version int) string {
+	return "shares/" + shareID + "/v" + strconv.Itoa(version) + ".md"
+}
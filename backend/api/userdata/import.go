@@ -0,0 +1,165 @@
+package userdata
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mihazzz123/siyuan-share/models"
+)
+
+// MaxArchiveSize Максимальный размер загружаемого архива импорта (в сжатом виде)
+const MaxArchiveSize = 100 << 20
+
+// Ограничения размера распакованных файлов архива (защита от zip-бомб). Общий объём -
+// небольшое кратное размера архива: всё содержимое держится в памяти до записи в базу
+const (
+	maxManifestSize = 64 << 20
+	maxContentSize  = 16 << 20
+	maxArchiveTotal = 4 * MaxArchiveSize
+)
+
+// ErrInvalidArchive Файл не является архивом экспорта поддерживаемой версии
+var ErrInvalidArchive = errors.New("invalid export archive")
+
+// archiveReader Чтение файлов архива с учётом ограничений размера. Каждый файл читается
+// не больше одного раза: иначе манифест со ссылками на один большой файл обходил бы
+// ограничение размера архива
+type archiveReader struct {
+	files map[string]*zip.File
+	used  map[string]bool
+	total int64
+}
+
+func (a *archiveReader) read(name string, limit int64) ([]byte, error) {
+	f, ok := a.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing file %s", ErrInvalidArchive, name)
+	}
+	if a.used[name] {
+		return nil, fmt.Errorf("%w: file %s is referenced more than once", ErrInvalidArchive, name)
+	}
+	a.used[name] = true
+	if f.UncompressedSize64 > uint64(limit) {
+		return nil, fmt.Errorf("%w: file %s is too large", ErrInvalidArchive, name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer rc.Close()
+	// Заявленный размер может не совпадать с фактическим, поэтому чтение тоже ограничено
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: file %s is too large", ErrInvalidArchive, name)
+	}
+	a.total += int64(len(data))
+	if a.total > maxArchiveTotal {
+		return nil, fmt.Errorf("%w: archive is too large", ErrInvalidArchive)
+	}
+	return data, nil
+}
+
+// ReadShares Разбор архива экспорта: публикации с содержимым, ссылками и историей версий
+// для models.ImportShares. Остальные разделы манифеста (профиль, токены, сессии) не импортируются
+func ReadShares(r io.ReaderAt, size int64) ([]models.ImportedShare, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	archive := &archiveReader{files: make(map[string]*zip.File, len(zr.File)), used: make(map[string]bool, len(zr.File))}
+	for _, f := range zr.File {
+		archive.files[f.Name] = f
+	}
+
+	data, err := archive.read(ManifestName, maxManifestSize)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: manifest: %v", ErrInvalidArchive, err)
+	}
+	if manifest.Format != Format {
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidArchive, manifest.Format)
+	}
+	if manifest.Version < 1 || manifest.Version > Version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidArchive, manifest.Version)
+	}
+
+	items := make([]models.ImportedShare, 0, len(manifest.Shares))
+	for _, s := range manifest.Shares {
+		if s.ID == "" || s.DocID == "" {
+			return nil, fmt.Errorf("%w: share without id or docId", ErrInvalidArchive)
+		}
+		content, err := archive.read(s.ContentFile, maxContentSize)
+		if err != nil {
+			return nil, err
+		}
+		item := models.ImportedShare{
+			Share: models.Share{
+				ID:              s.ID,
+				DocID:           s.DocID,
+				DocTitle:        s.DocTitle,
+				Content:         string(content),
+				ParentShareID:   s.ParentShareID,
+				RequirePassword: s.RequirePassword && s.PasswordHash != "",
+				PasswordHash:    s.PasswordHash,
+				ExpireAt:        s.ExpireAt,
+				IsPublic:        s.IsPublic,
+				ViewCount:       s.ViewCount,
+				Revision:        s.Revision,
+				CreatedAt:       s.CreatedAt,
+				UpdatedAt:       s.UpdatedAt,
+			},
+		}
+		for _, r := range s.References {
+			if r.BlockID == "" {
+				continue
+			}
+			item.References = append(item.References, models.ShareReference{
+				BlockID: r.BlockID, ChildShareID: r.ChildShareID, Content: r.Content, DisplayText: r.DisplayText, RefCount: r.RefCount,
+			})
+		}
+		versions := map[int]bool{}
+		for _, rev := range s.Revisions {
+			if rev.Version < 1 || versions[rev.Version] {
+				continue
+			}
+			versions[rev.Version] = true
+			revContent, err := archive.read(rev.ContentFile, maxContentSize)
+			if err != nil {
+				return nil, err
+			}
+			createdAt := rev.CreatedAt
+			if createdAt.IsZero() {
+				createdAt = time.Now()
+			}
+			item.Revisions = append(item.Revisions, models.ShareRevision{
+				Version:      rev.Version,
+				DocTitle:     rev.DocTitle,
+				Content:      string(revContent),
+				References:   revisionReferencesJSON(rev.References),
+				RestoredFrom: rev.RestoredFrom,
+				CreatedAt:    createdAt,
+			})
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// revisionReferencesJSON Ссылаемые блоки версии в JSON формата BlockReference (как в базе)
+func revisionReferencesJSON(refs []Reference) string {
+	out := make([]models.ShareReference, 0, len(refs))
+	for _, r := range refs {
+		out = append(out, models.ShareReference{BlockID: r.BlockID, Content: r.Content, DisplayText: r.DisplayText, RefCount: r.RefCount})
+	}
+	return models.ReferencesJSON(out)
+}
@@ -1,10 +1,13 @@
 import { Route, Routes } from 'react-router-dom'
 import './App.css'
+import Admin from './pages/Admin'
 import Dashboard from './pages/Dashboard'
 import Home from './pages/Home'
 import NotFound from './pages/NotFound.tsx'
+import ResetPassword from './pages/ResetPassword'
 import ShareList from './pages/ShareList'
 import ShareView from './pages/ShareView'
+import VerifyEmail from './pages/VerifyEmail'
 
 function App() {
   return (
@@ -14,6 +17,9 @@ function App() {
         <Route path="/s/:shareId" element={<ShareView />} />
         <Route path="/dashboard" element={<Dashboard />} />
         <Route path="/shares" element={<ShareList />} />
+        <Route path="/admin" element={<Admin />} />
+        <Route path="/verify-email" element={<VerifyEmail />} />
+        <Route path="/reset-password" element={<ResetPassword />} />
         <Route path="*" element={<NotFound />} />
       </Routes>
     </div>
@@ -0,0 +1,78 @@
+import api from './index'
+
+export interface AuditEvent {
+  id: number
+  createdAt: string
+  userId: string
+  actorId: string
+  actorName: string
+  via?: 'session' | 'token' | 'cli'
+  credential?: string
+  action: string
+  targetType?: string
+  targetId?: string
+  result: 'success' | 'failure' | 'denied'
+  ip: string
+  userAgent: string
+  detail?: string
+}
+
+export interface AuditFilter {
+  userId?: string
+  actor?: string
+  action?: string
+  result?: string
+  since?: string
+  until?: string
+}
+
+export interface AuditListResponse {
+  code: number
+  msg: string
+  data: {
+    items: AuditEvent[]
+    page: number
+    size: number
+    total: number
+  }
+}
+
+// Журнал аудита своего аккаунта (admin = false) или всего экземпляра
+export const listAuditEvents = async (admin: boolean, filter: AuditFilter, page = 1, size = 20): Promise<AuditListResponse> => {
+  return api.get(admin ? '/api/admin/audit' : '/api/user/audit', { params: { ...filter, page, size } })
+}
+
+// Названия действий журнала аудита
+export const auditActionLabels: Record<string, string> = {
+  'auth.login': 'Вход',
+  'auth.login_failed': 'Неудачный вход',
+  'auth.register': 'Регистрация',
+  'auth.logout': 'Выход',
+  'auth.logout_all': 'Выход на всех устройствах',
+  'session.revoke': 'Завершение сессии',
+  'session.refresh_reuse': 'Повторный refresh токен',
+  'user.password_change': 'Смена пароля',
+  'user.password_reset': 'Сброс пароля',
+  'user.profile_update': 'Изменение профиля',
+  'user.email_verify': 'Подтверждение email',
+  'user.delete': 'Удаление аккаунта',
+  'user.export': 'Выгрузка данных',
+  'user.2fa_enable': 'Включение 2FA',
+  'user.2fa_disable': 'Отключение 2FA',
+  'user.2fa_recovery_regenerate': 'Новые резервные коды',
+  'token.create': 'Создание токена',
+  'token.revoke': 'Отзыв токена',
+  'token.refresh': 'Обновление токена',
+  'token.rotate': 'Ротация токена',
+  'token.rejected': 'Отказ по токену',
+  'share.delete': 'Удаление публикации',
+  'share.delete_batch': 'Массовое удаление',
+  'share.import': 'Импорт публикаций',
+  'share.unlock_failed': 'Неверный пароль публикации',
+  'admin.user_update': 'Изменение пользователя',
+  'admin.password_reset': 'Сброс пароля администратором',
+  'admin.token_revoke': 'Отзыв токена администратором',
+  'admin.share_delete': 'Удаление публикации администратором',
+  'invite.create': 'Создание приглашения',
+  'invite.revoke': 'Отзыв приглашения',
+}
@@ -12,7 +12,8 @@ api.interceptors.request.use(
     // Автоматическое добавление сессионного токена (для дашборда/управления API)
     try {
       const token = localStorage.getItem('session_token')
-      if (token) {
+      // Явно заданный заголовок (например, токен просмотра публикации) не перезаписывается
+      if (token && !(config.headers as any)?.['Authorization']) {
         config.headers = config.headers || {}
         ;(config.headers as any)['Authorization'] = `Bearer ${token}`
       }
@@ -24,12 +25,49 @@ api.interceptors.request.use(
   }
 )
 
+// Обновление сессии по refresh токену; параллельные запросы ждут одно обновление
+let refreshing: Promise<string | null> | null = null
+
+export function refreshSession(): Promise<string | null> {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem('refresh_token')
+    refreshing = (refreshToken
+      ? axios.post(`${api.defaults.baseURL || ''}/api/auth/refresh`, { refreshToken })
+          .then((res) => {
+            const data = res.data?.data
+            if (res.data?.code !== 0 || !data?.token) throw new Error(res.data?.msg)
+            localStorage.setItem('session_token', data.token)
+            localStorage.setItem('refresh_token', data.refreshToken)
+            return data.token as string
+          })
+          .catch(() => {
+            localStorage.removeItem('session_token')
+            localStorage.removeItem('refresh_token')
+            return null
+          })
+      : Promise.resolve(null)
+    ).finally(() => { refreshing = null })
+  }
+  return refreshing
+}
+
 // Перехватчик ответов
 api.interceptors.response.use(
   (response) => {
     return response.data
   },
-  (error) => {
+  async (error) => {
+    // Истёкший access токен сессии: одно обновление и повтор запроса
+    const config = error.config
+    const sentSession = config?.headers?.['Authorization'] === `Bearer ${localStorage.getItem('session_token')}`
+    if (error.response?.status === 401 && config && !config._retried && sentSession && localStorage.getItem('refresh_token')) {
+      config._retried = true
+      const token = await refreshSession()
+      if (token) {
+        config.headers['Authorization'] = `Bearer ${token}`
+        return api(config)
+      }
+    }
     return Promise.reject(error)
   }
 )
@@ -7,7 +7,9 @@ export interface ShareData {
   requirePassword: boolean
   expireAt: string
   viewCount: number
+  revision: number
   createdAt: string
+  updatedAt: string
 }
 
 export interface ShareResponse {
@@ -39,12 +41,75 @@ export interface ShareListResponse {
   }
 }
 
+export interface UnlockResponse {
+  code: number
+  msg: string
+  data?: {
+    token: string
+    expiresAt: string | null
+  }
+}
+
+const viewerTokenKey = (shareId: string) => `share_viewer_token_${shareId}`
+
+// Заголовок с токеном просмотра, полученным при вводе пароля (сервер также ставит cookie)
+const viewerHeaders = (shareId: string): Record<string, string> => {
+  try {
+    const token = sessionStorage.getItem(viewerTokenKey(shareId))
+    if (token) return { Authorization: `Bearer ${token}` }
+  } catch {}
+  return {}
+}
+
+/**
+ * Проверка пароля публикации и получение токена просмотра
+ */
+export const unlockShare = async (shareId: string, password: string): Promise<UnlockResponse> => {
+  const res: UnlockResponse = await api.post(`/api/s/${shareId}/unlock`, { password })
+  if (res.code === 0 && res.data?.token) {
+    try {
+      sessionStorage.setItem(viewerTokenKey(shareId), res.data.token)
+    } catch {}
+  }
+  return res
+}
+
 /**
  * Получение содержимого публикации
  */
-export const getShare = async (shareId: string, password?: string): Promise<ShareResponse> => {
-  const params = password ? { password } : {}
-  return api.get(`/api/s/${shareId}`, { params })
+export const getShare = async (shareId: string): Promise<ShareResponse> => {
+  // Запрос выполняется XHR, поэтому источник перехода на страницу передаётся явно
+  const headers = viewerHeaders(shareId)
+  if (document.referrer) headers['X-Referrer'] = document.referrer
+  return api.get(`/api/s/${shareId}`, { headers })
+}
+
+export interface ShareDiffSummary {
+  linesAdded: number
+  linesRemoved: number
+  blocksAdded: number
+  blocksRemoved: number
+  blocksModified: number
+  replaced?: boolean // Документ переписан целиком, подробные изменения не вычислялись
+}
+
+export interface ShareDiffResponse {
+  code: number
+  msg: string
+  data?: {
+    from: number
+    to: number
+    changed: boolean
+    titleChanged: boolean
+    summary: ShareDiffSummary
+  }
+}
+
+/**
+ * Получение сводки изменений публикации между ревизиями
+ */
+export const getShareDiffSummary = async (shareId: string, from: number): Promise<ShareDiffResponse> => {
+  return api.get(`/api/s/${shareId}/diff`, { params: { from, mode: 'summary' }, headers: viewerHeaders(shareId) })
 }
 
 /**
@@ -54,9 +119,87 @@ export const listShares = async (page = 1, size = 10): Promise<ShareListResponse
   return api.get('/api/share/list', { params: { page, size } })
 }
 
+export interface ShareViewDay {
+  day: string
+  views: number
+  visitors: number
+  botViews: number
+}
+
+export interface ShareViewCount {
+  name: string
+  views: number
+}
+
+export interface ShareStats {
+  shareId: string
+  viewCount: number
+  botViewCount: number
+  from: string
+  to: string
+  views: number
+  visitors: number
+  botViews: number
+  series: ShareViewDay[]
+  referrers: ShareViewCount[]
+  userAgents: ShareViewCount[]
+  bots: ShareViewCount[]
+}
+
+export interface ShareStatsResponse {
+  code: number
+  msg: string
+  data?: ShareStats
+}
+
+/**
+ * Статистика просмотров публикации за последние days суток
+ */
+export const getShareStats = async (id: string, days = 30): Promise<ShareStatsResponse> => {
+  return api.get(`/api/share/${id}/stats`, { params: { days } })
+}
+
+export const uaClassLabels: Record<string, string> = {
+  desktop: 'Компьютер',
+  mobile: 'Телефон',
+  tablet: 'Планшет',
+  bot: 'Бот',
+  prefetch: 'Предзагрузка',
+  other: 'Другое',
+}
+
+export const botLabels: Record<string, string> = {
+  prefetch: 'Предзагрузка браузером',
+  other: 'Прочие боты',
+}
+
 /**
  * Удаление публикации
  */
 export const deleteShare = async (id: string): Promise<{ code: number; msg: string }> => {
   return api.delete(`/api/share/${id}`)
 }
+
+export interface ImportConflict {
+  shareId: string
+  docId: string
+  reason: 'id_taken' | 'invalid_id' | 'doc_exists' | 'already_imported' | 'duplicate_doc'
+  newId?: string
+  existingId?: string
+}
+
+export interface ImportReport {
+  imported: number
+  remapped: number
+  skipped: number
+  revisions: number
+  dryRun: boolean
+  conflicts: ImportConflict[]
+}
+
+// Импорт публикаций из архива экспорта (dryRun - только отчёт о конфликтах)
+export const importShares = async (file: File, dryRun = false): Promise<{ code: number; msg: string; data: ImportReport }> => {
+  const form = new FormData()
+  form.append('file', file)
+  return api.post(`/api/share/import${dryRun ? '?dryRun=true' : ''}`, form, { timeout: 0 })
+}
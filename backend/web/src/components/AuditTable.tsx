@@ -0,0 +1,113 @@
+import { ReloadOutlined } from '@ant-design/icons'
+import { Button, Input, message, Select, Space, Table, Tag, Tooltip } from 'antd'
+import type { ColumnsType } from 'antd/es/table'
+import { useEffect, useState, type ReactNode } from 'react'
+import { auditActionLabels, listAuditEvents, type AuditEvent, type AuditFilter } from '../api/audit'
+
+const pageSize = 20
+
+const resultTags: Record<string, ReactNode> = {
+  success: <Tag color="success">успех</Tag>,
+  failure: <Tag color="error">ошибка</Tag>,
+  denied: <Tag color="warning">отказ</Tag>,
+}
+
+const groupOptions = [
+  { label: 'Все действия', value: '' },
+  { label: 'Вход и сессии', value: 'auth.' },
+  { label: 'Аккаунт', value: 'user.' },
+  { label: 'API токены', value: 'token.' },
+  { label: 'Публикации', value: 'share.' },
+  { label: 'Администрирование', value: 'admin.' },
+]
+
+const resultOptions = [
+  { label: 'Любой результат', value: '' },
+  { label: 'Успех', value: 'success' },
+  { label: 'Ошибка', value: 'failure' },
+  { label: 'Отказ', value: 'denied' },
+]
+
+// Журнал аудита: своего аккаунта или (admin) всего экземпляра с фильтром по аккаунту
+function AuditTable({ admin = false }: { admin?: boolean }) {
+  const [filter, setFilter] = useState<AuditFilter>({})
+  const [data, setData] = useState<{ items: AuditEvent[]; page: number; total: number }>({ items: [], page: 1, total: 0 })
+  const [loading, setLoading] = useState(false)
+
+  const load = async (page = 1, f = filter) => {
+    setLoading(true)
+    try {
+      const res = await listAuditEvents(admin, f, page, pageSize)
+      if (res.code === 0) setData(res.data)
+    } catch (e: any) {
+      message.error(e.response?.data?.msg || e.message || 'Ошибка загрузки журнала')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  useEffect(() => {
+    load()
+  }, [])
+
+  const applyFilter = (values: AuditFilter) => {
+    const next = { ...filter, ...values }
+    setFilter(next)
+    load(1, next)
+  }
+
+  const columns: ColumnsType<AuditEvent> = [
+    { title: 'Время', dataIndex: 'createdAt', key: 'createdAt', width: 170, render: (t: string) => new Date(t).toLocaleString('ru-RU') },
+    {
+      title: 'Действие',
+      key: 'action',
+      width: 230,
+      render: (r: AuditEvent) => <Tooltip title={r.action}>{auditActionLabels[r.action] || r.action}</Tooltip>
+    },
+    { title: 'Результат', dataIndex: 'result', key: 'result', width: 100, render: (v: string) => resultTags[v] || <Tag>{v}</Tag> },
+    {
+      title: 'Исполнитель',
+      key: 'actor',
+      width: 160,
+      render: (r: AuditEvent) => (
+        <Space size={4}>
+          <span>{r.actorName || r.actorId || '—'}</span>
+          {r.via && <Tag>{r.via}</Tag>}
+        </Space>
+      )
+    },
+    ...(admin ? [{ title: 'Аккаунт', dataIndex: 'userId', key: 'userId', width: 200, ellipsis: true }] : []),
+    { title: 'Объект', key: 'target', width: 200, ellipsis: true, render: (r: AuditEvent) => r.targetType ? `${r.targetType}: ${r.targetId || '—'}` : '—' },
+    { title: 'IP', dataIndex: 'ip', key: 'ip', width: 130, render: (v: string) => v || '—' },
+    {
+      title: 'Подробности',
+      key: 'detail',
+      ellipsis: true,
+      render: (r: AuditEvent) => <Tooltip title={r.userAgent}>{r.detail || '—'}</Tooltip>
+    },
+  ]
+
+  return (
+    <>
+      <Space wrap style={{ marginBottom: 16 }}>
+        <Select style={{ width: 200 }} options={groupOptions} value={filter.action || ''} onChange={v => applyFilter({ action: v })} />
+        <Select style={{ width: 170 }} options={resultOptions} value={filter.result || ''} onChange={v => applyFilter({ result: v })} />
+        {admin && (
+          <Input.Search placeholder="ID пользователя" allowClear style={{ width: 260 }} onSearch={v => applyFilter({ userId: v.trim() })} />
+        )}
+        <Button icon={<ReloadOutlined />} loading={loading} onClick={() => load(data.page)}>Обновить</Button>
+      </Space>
+      <Table
+        dataSource={data.items}
+        columns={columns}
+        rowKey="id"
+        size="small"
+        loading={loading}
+        pagination={{ current: data.page, total: data.total, pageSize, showSizeChanger: false, onChange: p => load(p) }}
+        scroll={{ x: admin ? 1300 : 1100 }}
+      />
+    </>
+  )
+}
+
+export default AuditTable
@@ -0,0 +1,123 @@
+import { Col, Empty, message, Modal, Row, Segmented, Spin, Statistic, Table, Tooltip, Typography } from 'antd'
+import { useEffect, useState } from 'react'
+import { botLabels, getShareStats, uaClassLabels, type ShareStats, type ShareViewCount } from '../api/share'
+
+const { Text } = Typography
+
+const periodOptions = [
+  { label: '7 дней', value: 7 },
+  { label: '30 дней', value: 30 },
+  { label: '90 дней', value: 90 },
+]
+
+// Столбчатый график просмотров по суткам
+function ViewsChart({ stats }: { stats: ShareStats }) {
+  const max = Math.max(1, ...stats.series.map((d) => d.views))
+  return (
+    <div style={{ display: 'flex', alignItems: 'flex-end', gap: 2, height: 120, padding: '8px 0' }}>
+      {stats.series.map((d) => (
+        <Tooltip key={d.day} title={`${d.day}: просмотров ${d.views}, посетителей ${d.visitors}, ботов ${d.botViews}`}>
+          <div style={{ flex: 1, height: '100%', display: 'flex', alignItems: 'flex-end' }}>
+            <div style={{ width: '100%', height: `${(d.views / max) * 100}%`, minHeight: d.views ? 2 : 0, background: '#1677ff', borderRadius: 2 }} />
+          </div>
+        </Tooltip>
+      ))}
+    </div>
+  )
+}
+
+// Статистика просмотров публикации: график по суткам, источники переходов, типы устройств и боты
+function ShareStatsModal({ share, onClose }: { share: { id: string; docTitle: string } | null; onClose: () => void }) {
+  const [days, setDays] = useState(30)
+  const [stats, setStats] = useState<ShareStats | null>(null)
+  const [loading, setLoading] = useState(false)
+
+  useEffect(() => {
+    if (!share) return
+    setLoading(true)
+    getShareStats(share.id, days)
+      .then((res) => {
+        if (res.code === 0 && res.data) setStats(res.data)
+        else message.error(res.msg || 'Ошибка загрузки статистики')
+      })
+      .catch((e: any) => message.error(e.response?.data?.msg || e.message || 'Ошибка загрузки статистики'))
+      .finally(() => setLoading(false))
+  }, [share, days])
+
+  const countColumns = (title: string, label: (name: string) => string) => [
+    { title, dataIndex: 'name', key: 'name', ellipsis: true, render: (name: string) => label(name) },
+    { title: 'Просмотры', dataIndex: 'views', key: 'views', width: 110, align: 'right' as const },
+  ]
+
+  return (
+    <Modal
+      open={!!share}
+      title={`Статистика: ${share?.docTitle || ''}`}
+      width={760}
+      footer={null}
+      onCancel={() => {
+        setStats(null)
+        onClose()
+      }}
+    >
+      <Segmented options={periodOptions} value={days} onChange={(v) => setDays(v as number)} style={{ marginBottom: 16 }} />
+      <Spin spinning={loading}>
+        {stats ? (
+          <>
+            <Row gutter={16}>
+              <Col span={6}><Statistic title="Просмотры за период" value={stats.views} /></Col>
+              <Col span={6}>
+                <Statistic title={<Tooltip title="Сумма уникальных посетителей по суткам">Посетители</Tooltip>} value={stats.visitors} />
+              </Col>
+              <Col span={6}>
+                <Statistic
+                  title={<Tooltip title="Предпросмотр ссылок, поисковые роботы и предзагрузка; не входят в просмотры">Боты за период</Tooltip>}
+                  value={stats.botViews}
+                />
+              </Col>
+              <Col span={6}><Statistic title="Всего просмотров" value={stats.viewCount} /></Col>
+            </Row>
+            <ViewsChart stats={stats} />
+            <Text type="secondary">{stats.from} — {stats.to} (UTC)</Text>
+            <Row gutter={16} style={{ marginTop: 16 }}>
+              <Col span={14}>
+                <Table<ShareViewCount>
+                  size="small"
+                  rowKey="name"
+                  pagination={false}
+                  dataSource={stats.referrers}
+                  columns={countColumns('Источник', (name) => name || 'Прямой переход')}
+                  locale={{ emptyText: <Empty image={Empty.PRESENTED_IMAGE_SIMPLE} description="Нет данных" /> }}
+                />
+              </Col>
+              <Col span={10}>
+                <Table<ShareViewCount>
+                  size="small"
+                  rowKey="name"
+                  pagination={false}
+                  dataSource={stats.userAgents}
+                  columns={countColumns('Устройство', (name) => uaClassLabels[name] || name)}
+                  locale={{ emptyText: <Empty image={Empty.PRESENTED_IMAGE_SIMPLE} description="Нет данных" /> }}
+                />
+              </Col>
+            </Row>
+            {stats.bots.length > 0 && (
+              <Table<ShareViewCount>
+                size="small"
+                rowKey="name"
+                pagination={false}
+                style={{ marginTop: 16 }}
+                dataSource={stats.bots}
+                columns={countColumns('Бот', (name) => botLabels[name] || name)}
+              />
+            )}
+          </>
+        ) : (
+          <Empty />
+        )}
+      </Spin>
+    </Modal>
+  )
+}
+
+export default ShareStatsModal
@@ -0,0 +1,287 @@
+import { ArrowLeftOutlined, DeleteOutlined, KeyOutlined, ReloadOutlined } from '@ant-design/icons'
+import { Button, Card, Input, message, Modal, Space, Statistic, Switch, Table, Tabs, Tag, Typography } from 'antd'
+import type { ColumnsType } from 'antd/es/table'
+import { useEffect, useState } from 'react'
+import { useNavigate } from 'react-router-dom'
+import api from '../api'
+import AuditTable from '../components/AuditTable'
+
+const { Title, Text } = Typography
+
+interface ApiResp<T = any> { code: number; msg: string; data: T }
+interface Page<T> { items: T[]; page: number; size: number; total: number }
+interface AdminUser {
+  id: string
+  username: string
+  email: string
+  isActive: boolean
+  isAdmin: boolean
+  totpEnabled: boolean
+  emailVerifiedAt?: string
+  shareCount: number
+  tokenCount: number
+  createdAt: string
+}
+interface AdminShare {
+  id: string
+  userId: string
+  username: string
+  docTitle: string
+  requirePassword: boolean
+  isPublic: boolean
+  expireAt: string
+  viewCount: number
+  createdAt: string
+}
+
+const pageSize = 20
+
+// Администрирование: пользователи, публикации, журнал аудита и статистика экземпляра
+function Admin() {
+  const navigate = useNavigate()
+  const [stats, setStats] = useState<any>(null)
+  const [users, setUsers] = useState<Page<AdminUser>>({ items: [], page: 1, size: pageSize, total: 0 })
+  const [shares, setShares] = useState<Page<AdminShare>>({ items: [], page: 1, size: pageSize, total: 0 })
+  const [userQuery, setUserQuery] = useState('')
+  const [shareQuery, setShareQuery] = useState('')
+  const [loading, setLoading] = useState(false)
+
+  const loadStats = async () => {
+    try {
+      const res = await api.get('/api/admin/stats') as ApiResp
+      if (res.code === 0) setStats(res.data)
+    } catch (e: any) {
+      message.error(e.response?.data?.msg || e.message || 'Ошибка загрузки')
+    }
+  }
+
+  const loadUsers = async (page = 1, q = userQuery) => {
+    setLoading(true)
+    try {
+      const res = await api.get('/api/admin/users', { params: { page, size: pageSize, q } }) as ApiResp<Page<AdminUser>>
+      if (res.code === 0) setUsers(res.data)
+    } catch (e: any) {
+      message.error(e.response?.data?.msg || e.message || 'Ошибка загрузки')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  const loadShares = async (page = 1, q = shareQuery) => {
+    setLoading(true)
+    try {
+      const res = await api.get('/api/admin/shares', { params: { page, size: pageSize, q } }) as ApiResp<Page<AdminShare>>
+      if (res.code === 0) setShares(res.data)
+    } catch (e: any) {
+      message.error(e.response?.data?.msg || e.message || 'Ошибка загрузки')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  useEffect(() => {
+    loadStats()
+    loadUsers()
+    loadShares()
+  }, [])
+
+  const updateUser = async (user: AdminUser, values: { isActive?: boolean; isAdmin?: boolean }) => {
+    try {
+      const res = await api.put(`/api/admin/users/${user.id}`, values) as ApiResp
+      if (res.code === 0) {
+        message.success('Сохранено')
+        loadUsers(users.page)
+      } else {
+        message.error(res.msg || 'Ошибка сохранения')
+      }
+    } catch (e: any) {
+      message.error(e.response?.data?.msg || e.message || 'Ошибка сохранения')
+    }
+  }
+
+  const resetPassword = (user: AdminUser) => {
+    Modal.confirm({
+      title: `Сброс пароля: ${user.username}`,
+      content: `На ${user.email} будет отправлено письмо со ссылкой для смены пароля.`,
+      okText: 'Отправить',
+      cancelText: 'Отмена',
+      onOk: async () => {
+        try {
+          const res = await api.post(`/api/admin/users/${user.id}/password`, {}) as ApiResp
+          if (res.code === 0) message.success('Письмо отправлено')
+          else message.error(res.msg || 'Не удалось отправить письмо')
+        } catch (e: any) {
+          message.error(e.response?.data?.msg || e.message || 'Не удалось отправить письмо')
+        }
+      }
+    })
+  }
+
+  const deleteShare = (share: AdminShare) => {
+    Modal.confirm({
+      title: 'Подтвердите удаление',
+      content: `Удалить публикацию "${share.docTitle}" пользователя ${share.username}?`,
+      okText: 'Удалить',
+      okType: 'danger',
+      cancelText: 'Отмена',
+      onOk: async () => {
+        try {
+          const res = await api.delete(`/api/admin/shares/${share.id}`) as ApiResp
+          if (res.code === 0) {
+            message.success('Успешно удалено')
+            loadShares(shares.page)
+            loadStats()
+          } else {
+            message.error(res.msg || 'Ошибка удаления')
+          }
+        } catch (e: any) {
+          message.error(e.response?.data?.msg || e.message || 'Ошибка удаления')
+        }
+      }
+    })
+  }
+
+  const userColumns: ColumnsType<AdminUser> = [
+    {
+      title: 'Пользователь',
+      key: 'user',
+      render: (r: AdminUser) => (
+        <Space direction="vertical" size={0}>
+          <Text strong>{r.username}</Text>
+          <Text type="secondary">{r.email} {r.emailVerifiedAt ? <Tag color="success">подтверждён</Tag> : null}</Text>
+        </Space>
+      )
+    },
+    { title: 'Публикации', dataIndex: 'shareCount', key: 'shareCount', width: 110, align: 'center' },
+    { title: 'Токены', dataIndex: 'tokenCount', key: 'tokenCount', width: 90, align: 'center' },
+    { title: '2FA', key: 'totp', width: 70, render: (r: AdminUser) => r.totpEnabled ? <Tag color="success">да</Tag> : <Tag>нет</Tag> },
+    { title: 'Активен', key: 'isActive', width: 90, render: (r: AdminUser) => <Switch size="small" checked={r.isActive} onChange={v => updateUser(r, { isActive: v })} /> },
+    { title: 'Администратор', key: 'isAdmin', width: 130, render: (r: AdminUser) => <Switch size="small" checked={r.isAdmin} onChange={v => updateUser(r, { isAdmin: v })} /> },
+    { title: 'Создан', dataIndex: 'createdAt', key: 'createdAt', width: 180, render: (t: string) => new Date(t).toLocaleString('ru-RU') },
+    {
+      title: 'Действия',
+      key: 'action',
+      width: 140,
+      render: (r: AdminUser) => <Button type="link" size="small" icon={<KeyOutlined />} onClick={() => resetPassword(r)}>Сброс пароля</Button>
+    }
+  ]
+
+  const shareColumns: ColumnsType<AdminShare> = [
+    { title: 'Заголовок заметки', key: 'docTitle', ellipsis: true, render: (r: AdminShare) => <a href={`/s/${r.id}`} target="_blank" rel="noreferrer">{r.docTitle}</a> },
+    { title: 'Владелец', dataIndex: 'username', key: 'username', width: 140 },
+    {
+      title: 'Доступ',
+      key: 'access',
+      width: 140,
+      render: (r: AdminShare) => r.requirePassword ? <Tag color="orange">Защита паролем</Tag> : r.isPublic ? <Tag color="blue">Публичная</Tag> : <Tag>По ссылке</Tag>
+    },
+    { title: 'Views', dataIndex: 'viewCount', key: 'viewCount', width: 90, align: 'center' },
+    { title: 'Истекает', dataIndex: 'expireAt', key: 'expireAt', width: 180, render: (t: string) => new Date(t).toLocaleString('ru-RU') },
+    {
+      title: 'Действия',
+      key: 'action',
+      width: 110,
+      render: (r: AdminShare) => <Button type="link" size="small" danger icon={<DeleteOutlined />} onClick={() => deleteShare(r)}>Удалить</Button>
+    }
+  ]
+
+  return (
+    <div style={{ maxWidth: 1400, margin: '60px auto', padding: '0 24px' }}>
+      <Card>
+        <div style={{ display: 'flex', alignItems: 'center', justifyContent: 'space-between', marginBottom: 24 }}>
+          <div style={{ display: 'flex', alignItems: 'center', gap: 16 }}>
+            <Button icon={<ArrowLeftOutlined />} onClick={() => navigate('/dashboard')}>Вернуться в кабинет</Button>
+            <Title level={3} style={{ margin: 0 }}>Администрирование</Title>
+          </div>
+          <Button type="primary" icon={<ReloadOutlined />} loading={loading} onClick={() => { loadStats(); loadUsers(users.page); loadShares(shares.page) }}>
+            Обновить
+          </Button>
+        </div>
+
+        {stats && (
+          <Space size={48} wrap style={{ marginBottom: 24 }}>
+            <Statistic title="Пользователи" value={stats.users.total} suffix={`/ ${stats.users.active} акт.`} />
+            <Statistic title="Публикации" value={stats.shares.total} />
+            <Statistic title="Просмотры" value={stats.shares.views} />
+            <Statistic title="API токены" value={stats.tokens.active} />
+            <Statistic title="Сессии" value={stats.sessions.active} />
+            <Statistic title="База данных, МБ" value={(stats.databaseBytes / 1048576).toFixed(1)} />
+          </Space>
+        )}
+        {stats && (
+          <div style={{ marginBottom: 24 }}>
+            {stats.lastSweep ? (
+              <Text type={stats.lastSweep.error ? 'danger' : 'secondary'}>
+                Последняя очистка: {new Date(stats.lastSweep.at).toLocaleString('ru-RU')}, удалено публикаций:{' '}
+                {stats.lastSweep.report.expired + stats.lastSweep.report.deleted + stats.lastSweep.report.childShares}
+                {stats.lastSweep.error && `, ошибка: ${stats.lastSweep.error}`}
+              </Text>
+            ) : (
+              <Text type="secondary">Фоновая очистка после запуска сервера ещё не выполнялась</Text>
+            )}
+          </div>
+        )}
+
+        <Tabs
+          items={[
+            {
+              key: 'users',
+              label: 'Пользователи',
+              children: (
+                <>
+                  <Input.Search
+                    placeholder="Имя пользователя или email"
+                    allowClear
+                    style={{ maxWidth: 360, marginBottom: 16 }}
+                    value={userQuery}
+                    onChange={e => setUserQuery(e.target.value)}
+                    onSearch={q => loadUsers(1, q)}
+                  />
+                  <Table
+                    dataSource={users.items}
+                    columns={userColumns}
+                    rowKey="id"
+                    loading={loading}
+                    pagination={{ current: users.page, total: users.total, pageSize, showSizeChanger: false, onChange: p => loadUsers(p) }}
+                    scroll={{ x: 1100 }}
+                  />
+                </>
+              )
+            },
+            {
+              key: 'shares',
+              label: 'Публикации',
+              children: (
+                <>
+                  <Input.Search
+                    placeholder="Заголовок или ID публикации"
+                    allowClear
+                    style={{ maxWidth: 360, marginBottom: 16 }}
+                    value={shareQuery}
+                    onChange={e => setShareQuery(e.target.value)}
+                    onSearch={q => loadShares(1, q)}
+                  />
+                  <Table
+                    dataSource={shares.items}
+                    columns={shareColumns}
+                    rowKey="id"
+                    loading={loading}
+                    pagination={{ current: shares.page, total: shares.total, pageSize, showSizeChanger: false, onChange: p => loadShares(p) }}
+                    scroll={{ x: 1000 }}
+                  />
+                </>
+              )
+            },
+            {
+              key: 'audit',
+              label: 'Журнал аудита',
+              children: <AuditTable admin />
+            }
+          ]}
+        />
+      </Card>
+    </div>
+  )
+}
+
+export default Admin
@@ -1,13 +1,52 @@
-import { ApiOutlined, CopyOutlined, DeleteOutlined, HomeOutlined, PlusOutlined, ReloadOutlined, ShareAltOutlined, UserOutlined } from '@ant-design/icons'
-import { Button, Card, Divider, Form, Input, message, Modal, Space, Table, Tag, Typography } from 'antd'
+import { ApiOutlined, AuditOutlined, CopyOutlined, DeleteOutlined, DownloadOutlined, EditOutlined, HomeOutlined, KeyOutlined, LogoutOutlined, PlusOutlined, ReloadOutlined, SafetyOutlined, ShareAltOutlined, TeamOutlined, UserOutlined } from '@ant-design/icons'
+import { Button, Card, Checkbox, Divider, Form, Input, InputNumber, message, Modal, QRCode, Select, Space, Table, Tag, Typography } from 'antd'
 import { useEffect, useState } from 'react'
 import { useNavigate } from 'react-router-dom'
 import api from '../api'
+import AuditTable from '../components/AuditTable'
 
 const { Title, Text, Paragraph } = Typography
 
 interface ApiResp<T = any> { code: number; msg: string; data: T }
-interface TokenItem { id: string; name: string; revoked: boolean; createdAt: string; lastUsedAt?: string }
+interface InviteItem {
+  id: string
+  note: string
+  maxUses: number
+  uses: number
+  expiresAt?: string
+  revoked: boolean
+  usable: boolean
+  createdAt: string
+}
+interface TokenItem {
+  id: string
+  name: string
+  scopes?: string[]
+  revoked: boolean
+  expired?: boolean
+  expiresAt?: string
+  allowedCidrs?: string[]
+  rotationDays?: number
+  createdAt: string
+  lastUsedAt?: string
+}
+
+const scopeOptions = [
+  { label: 'Чтение публикаций (share:read)', value: 'share:read' },
+  { label: 'Создание публикаций (share:write)', value: 'share:write' },
+  { label: 'Удаление публикаций (share:delete)', value: 'share:delete' },
+  { label: 'Управление токенами (token:manage)', value: 'token:manage' },
+  { label: 'Администрирование (admin)', value: 'admin' },
+]
+const defaultScopes = ['share:read', 'share:write', 'share:delete']
+
+const expireOptions = [
+  { label: 'Бессрочно', value: 0 },
+  { label: '30 дней', value: 30 },
+  { label: '90 дней', value: 90 },
+  { label: '180 дней', value: 180 },
+  { label: '365 дней', value: 365 },
+]
 
 function Dashboard() {
   const navigate = useNavigate()
@@ -18,6 +57,19 @@ function Dashboard() {
   const [createModalOpen, setCreateModalOpen] = useState(false)
   const [newTokenData, setNewTokenData] = useState<{ name: string; token: string } | null>(null)
   const [form] = Form.useForm()
+  const [twoFactor, setTwoFactor] = useState<{ enabled: boolean; recoveryCodesLeft: number } | null>(null)
+  const [twoFactorSetup, setTwoFactorSetup] = useState<{ secret: string; provisioningUri: string } | null>(null)
+  const [recoveryCodes, setRecoveryCodes] = useState<string[] | null>(null)
+  const [disableModalOpen, setDisableModalOpen] = useState(false)
+  const [twoFactorForm] = Form.useForm()
+  const [disableForm] = Form.useForm()
+  const [invites, setInvites] = useState<InviteItem[]>([])
+  const [registrationMode, setRegistrationMode] = useState('')
+  const [inviteForm] = Form.useForm()
+  const [accountModal, setAccountModal] = useState<'' | 'profile' | 'password' | 'delete'>('')
+  const [profileForm] = Form.useForm()
+  const [passwordForm] = Form.useForm()
+  const [deleteForm] = Form.useForm()
 
   const loadAll = async () => {
     setLoading(true)
@@ -26,6 +78,15 @@ function Dashboard() {
       if (me.code === 0) setUser(me.data)
       const list = await api.get('/api/token/list') as ApiResp<{ items: TokenItem[] }>
       if (list.code === 0) setTokens(list.data.items || [])
+      if (me.code === 0 && me.data.isAdmin) {
+        const inv = await api.get('/api/admin/invites') as ApiResp<{ items: InviteItem[]; registration: { mode: string } }>
+        if (inv.code === 0) {
+          setInvites(inv.data.items || [])
+          setRegistrationMode(inv.data.registration?.mode || '')
+        }
+      }
+      const tf = await api.get('/api/user/2fa') as ApiResp<{ enabled: boolean; recoveryCodesLeft: number }>
+      if (tf.code === 0) setTwoFactor(tf.data)
     } catch (e: any) {
       message.error(e.message || 'Ошибка загрузки')
     } finally {
@@ -35,10 +96,104 @@ function Dashboard() {
 
   useEffect(() => { loadAll() }, [])
 
+  const resendVerification = async () => {
+    setActionLoading('verify-email')
+    try {
+      const res = await api.post('/api/user/verify-email', {}) as ApiResp
+      if (res.code === 0) message.success('Письмо со ссылкой отправлено на ' + user.email)
+      else message.error(res.msg || 'Не удалось отправить письмо')
+    } catch (e: any) {
+      message.error(e.response?.data?.msg || e.message || 'Не удалось отправить письмо')
+    } finally {
+      setActionLoading('')
+    }
+  }
+
+  // Управление аккаунтом: профиль, смена пароля, удаление
+  const accountRequest = async (key: string, request: () => Promise<any>) => {
+    setActionLoading(key)
+    try {
+      const res = await request() as ApiResp<any>
+      if (res.code !== 0) {
+        message.error(res.msg || 'Ошибка')
+        return null
+      }
+      return res.data
+    } catch (e: any) {
+      message.error(e.response?.data?.msg || e.message || 'Ошибка')
+      return null
+    } finally {
+      setActionLoading('')
+    }
+  }
+
+  const closeAccountModal = () => {
+    setAccountModal('')
+    profileForm.resetFields()
+    passwordForm.resetFields()
+    deleteForm.resetFields()
+  }
+
+  const updateProfile = async (values: any) => {
+    const data = await accountRequest('profile', () => api.put('/api/user/me', { username: values.username, email: values.email }))
+    if (data) {
+      message.success(data.email !== user.email ? 'Профиль сохранён, на новый email отправлено письмо подтверждения' : 'Профиль сохранён')
+      closeAccountModal()
+      loadAll()
+    }
+  }
+
+  const changePassword = async (values: any) => {
+    const data = await accountRequest('password', () => api.post('/api/user/password', { oldPassword: values.oldPassword, newPassword: values.newPassword }))
+    if (data) {
+      Modal.success({
+        title: 'Пароль изменён',
+        content: `Завершено сессий на других устройствах: ${data.revokedSessions}, отозвано API токенов: ${data.revokedTokens}. Создайте новый токен для плагина.`,
+      })
+      closeAccountModal()
+      loadAll()
+    }
+  }
+
+  // Архив всех данных аккаунта (zip); запрос с авторизацией, поэтому скачивание через Blob
+  const exportData = async () => {
+    setActionLoading('export')
+    try {
+      const blob = await api.get('/api/user/export', { responseType: 'blob', timeout: 0 }) as unknown as Blob
+      const url = URL.createObjectURL(blob)
+      const link = document.createElement('a')
+      link.href = url
+      link.download = `siyuan-share-export-${new Date().toISOString().slice(0, 10)}.zip`
+      link.click()
+      URL.revokeObjectURL(url)
+    } catch (e: any) {
+      message.error(e.message || 'Не удалось выгрузить данные')
+    } finally {
+      setActionLoading('')
+    }
+  }
+
+  const deleteAccount = async (values: any) => {
+    const data = await accountRequest('delete', () => api.delete('/api/user/me', { data: { password: values.password } }))
+    if (data) {
+      localStorage.removeItem('session_token')
+      localStorage.removeItem('refresh_token')
+      message.success('Аккаунт удалён')
+      navigate('/')
+    }
+  }
+
   const createToken = async (values: any) => {
     setActionLoading('create')
     try {
-      const res = await api.post('/api/token/create', values) as ApiResp<any>
+      const { expireDays, allowedCidrs, ...rest } = values
+      const payload = {
+        ...rest,
+        expiresAt: expireDays ? new Date(Date.now() + expireDays * 86400000).toISOString() : undefined,
+        allowedCidrs: String(allowedCidrs || '').split(/[\s,]+/).filter(Boolean),
+        rotationDays: values.rotationDays || 0,
+      }
+      const res = await api.post('/api/token/create', payload) as ApiResp<any>
       if (res.code === 0) {
         setNewTokenData({ name: res.data.name, token: res.data.token })
         message.success('Token успешно создан')
@@ -99,6 +254,134 @@ function Dashboard() {
     })
   }
 
+  // Двухфакторная аутентификация: настройка -> подтверждение кодом -> резервные коды
+  const twoFactorRequest = async (key: string, url: string, body: any) => {
+    setActionLoading(key)
+    try {
+      const res = await api.post(url, body) as ApiResp<any>
+      if (res.code !== 0) {
+        message.error(res.msg || 'Ошибка')
+        return null
+      }
+      return res.data
+    } catch (e: any) {
+      message.error(e.response?.data?.msg || e.message || 'Ошибка')
+      return null
+    } finally {
+      setActionLoading('')
+    }
+  }
+
+  const startTwoFactorSetup = async () => {
+    const data = await twoFactorRequest('2fa-setup', '/api/user/2fa/setup', {})
+    if (data) setTwoFactorSetup(data)
+  }
+
+  const enableTwoFactor = async (values: any) => {
+    const data = await twoFactorRequest('2fa-enable', '/api/user/2fa/enable', { code: values.code })
+    if (data) {
+      message.success('Двухфакторная аутентификация включена')
+      setTwoFactorSetup(null)
+      setRecoveryCodes(data.recoveryCodes)
+      twoFactorForm.resetFields()
+      loadAll()
+    }
+  }
+
+  const regenerateRecoveryCodes = async (values: any) => {
+    const data = await twoFactorRequest('2fa-codes', '/api/user/2fa/recovery-codes', { code: values.code })
+    if (data) {
+      setRecoveryCodes(data.recoveryCodes)
+      twoFactorForm.resetFields()
+      loadAll()
+    }
+  }
+
+  const disableTwoFactor = async (values: any) => {
+    const code = String(values.code || '').trim()
+    const body = /^\d{6}$/.test(code) ? { password: values.password, code } : { password: values.password, recoveryCode: code }
+    const data = await twoFactorRequest('2fa-disable', '/api/user/2fa/disable', body)
+    if (data !== null) {
+      message.success('Двухфакторная аутентификация отключена')
+      setDisableModalOpen(false)
+      disableForm.resetFields()
+      loadAll()
+    }
+  }
+
+  // Приглашения к регистрации (только для администратора)
+  const createInvite = async (values: any) => {
+    setActionLoading('invite-create')
+    try {
+      const res = await api.post('/api/admin/invites', {
+        note: values.note || '',
+        maxUses: values.maxUses ?? 1,
+        expiresInDays: values.expiresInDays || 0,
+      }) as ApiResp<any>
+      if (res.code === 0) {
+        Modal.success({
+          title: 'Приглашение создано',
+          content: <Paragraph copyable={{ text: res.data.code }}><Text code>{res.data.code}</Text><br />Код показывается только один раз.</Paragraph>,
+        })
+        inviteForm.resetFields()
+        loadAll()
+      } else {
+        message.error(res.msg || 'Ошибка создания')
+      }
+    } catch (e: any) {
+      message.error(e.response?.data?.msg || e.message || 'Ошибка создания')
+    } finally {
+      setActionLoading('')
+    }
+  }
+
+  const revokeInvite = async (id: string) => {
+    setActionLoading(id)
+    try {
+      const res = await api.delete(`/api/admin/invites/${id}`) as ApiResp<any>
+      if (res.code === 0) {
+        message.success('Приглашение отозвано')
+        loadAll()
+      } else {
+        message.error(res.msg || 'Ошибка отзыва')
+      }
+    } catch (e: any) {
+      message.error(e.response?.data?.msg || e.message || 'Ошибка отзыва')
+    } finally {
+      setActionLoading('')
+    }
+  }
+
+  const inviteColumns = [
+    { title: 'Заметка', dataIndex: 'note', key: 'note', render: (v: string) => v || <Text type="secondary">—</Text> },
+    { title: 'Использовано', key: 'uses', render: (_: any, r: InviteItem) => `${r.uses} / ${r.maxUses || '∞'}` },
+    { title: 'Действует до', dataIndex: 'expiresAt', key: 'expiresAt', render: (v?: string) => v ? new Date(v).toLocaleString('ru-RU') : 'Бессрочно' },
+    { title: 'Статус', key: 'status', render: (_: any, r: InviteItem) => r.revoked ? <Tag color="error">Отозвано</Tag> : r.usable ? <Tag color="success">Активно</Tag> : <Tag>Недействительно</Tag> },
+    {
+      title: 'Действия', key: 'actions', render: (_: any, r: InviteItem) => !r.revoked && (
+        <Button size="small" danger icon={<DeleteOutlined />} loading={actionLoading === r.id} onClick={() => revokeInvite(r.id)}>Отозвать</Button>
+      )
+    },
+  ]
+
+  const logoutAll = () => {
+    Modal.confirm({
+      title: 'Выйти на всех устройствах?',
+      content: 'Все сессии входа, включая текущую, будут завершены. API токены продолжат работать.',
+      okText: 'Выйти везде',
+      cancelText: 'Отмена',
+      okType: 'danger',
+      onOk: async () => {
+        try {
+          await api.post('/api/auth/logout-all', {})
+        } catch {}
+        localStorage.removeItem('session_token')
+        localStorage.removeItem('refresh_token')
+        navigate('/')
+      }
+    })
+  }
+
   const copyToken = (token: string) => {
     navigator.clipboard.writeText(token)
     message.success('Token скопирован в буфер обмена')
@@ -115,12 +398,34 @@ function Dashboard() {
       title: 'статус',
       dataIndex: 'revoked',
       key: 'revoked',
-      render: (revoked: boolean) => (
-        <Tag color={revoked ? 'default' : 'success'}>
-          {revoked ? 'Отозватьd' : 'Активен'}
+      render: (revoked: boolean, record: TokenItem) => (
+        <Tag color={revoked || record.expired ? 'default' : 'success'}>
+          {revoked ? 'Отозватьd' : record.expired ? 'Истёк' : 'Активен'}
         </Tag>
       )
     },
+    {
+      title: 'Действует до',
+      dataIndex: 'expiresAt',
+      key: 'expiresAt',
+      render: (time: string | undefined, record: TokenItem) => (
+        <Space direction="vertical" size={0}>
+          <span>{time ? new Date(time).toLocaleString('ru-RU') : 'Бессрочно'}</span>
+          {!!record.rotationDays && <Text type="secondary">Ротация: {record.rotationDays} дн.</Text>}
+          {!!record.allowedCidrs?.length && <Text type="secondary">IP: {record.allowedCidrs.join(', ')}</Text>}
+        </Space>
+      )
+    },
+    {
+      title: 'Права',
+      dataIndex: 'scopes',
+      key: 'scopes',
+      render: (scopes?: string[]) => (
+        <Space size={[0, 4]} wrap>
+          {(scopes || []).map(s => <Tag key={s}>{s}</Tag>)}
+        </Space>
+      )
+    },
     {
       title: 'Дата создания',
       dataIndex: 'createdAt',
@@ -196,7 +501,13 @@ function Dashboard() {
             <Button icon={<ShareAltOutlined />} onClick={() => navigate('/shares')}>
               Управление ссылками
             </Button>
+            {user?.isAdmin && (
+              <Button icon={<TeamOutlined />} onClick={() => navigate('/admin')}>
+                Администрирование
+              </Button>
+            )}
             <Button icon={<HomeOutlined />} href="/">На главную</Button>
+            <Button danger icon={<LogoutOutlined />} onClick={logoutAll}>Выйти на всех устройствах</Button>
           </Space>
         </Space>
       </div>
@@ -213,11 +524,172 @@ function Dashboard() {
       >
         <Space direction="vertical" size="small">
           <Text><Text strong>Имя пользователя: </Text>{user.username}</Text>
-          <Text><Text strong>Email: </Text>{user.email}</Text>
+          <Space>
+            <Text><Text strong>Email: </Text>{user.email}</Text>
+            {user.emailVerified ? (
+              <Tag color="success">Подтверждён</Tag>
+            ) : (
+              <>
+                <Tag color="warning">Не подтверждён</Tag>
+                <Button size="small" loading={actionLoading === 'verify-email'} onClick={resendVerification}>Отправить письмо</Button>
+              </>
+            )}
+          </Space>
           <Text type="secondary"><Text strong>Дата создания：</Text>{new Date(user.createdAt).toLocaleString('ru-RU')}</Text>
+          <Space style={{ marginTop: 8 }} wrap>
+            <Button icon={<EditOutlined />} onClick={() => { profileForm.setFieldsValue({ username: user.username, email: user.email }); setAccountModal('profile') }}>Изменить профиль</Button>
+            <Button icon={<KeyOutlined />} onClick={() => setAccountModal('password')}>Сменить пароль</Button>
+            <Button icon={<DownloadOutlined />} loading={actionLoading === 'export'} onClick={exportData}>Выгрузить данные</Button>
+            <Button danger icon={<DeleteOutlined />} onClick={() => setAccountModal('delete')}>Удалить аккаунт</Button>
+          </Space>
         </Space>
       </Card>
 
+      <Modal
+        title="Изменить профиль"
+        open={accountModal === 'profile'}
+        onCancel={closeAccountModal}
+        onOk={() => profileForm.submit()}
+        okText="Сохранить"
+        okButtonProps={{ loading: actionLoading === 'profile' }}
+        cancelText="Отмена"
+      >
+        <Form form={profileForm} layout="vertical" onFinish={updateProfile}>
+          <Form.Item name="username" label="Имя пользователя" rules={[{ required: true, min: 3, max: 100, message: 'От 3 до 100 символов' }]}>
+            <Input />
+          </Form.Item>
+          <Form.Item name="email" label="Email" extra="После смены email его нужно подтвердить заново" rules={[{ required: true, type: 'email', message: 'Введите корректный email' }]}>
+            <Input />
+          </Form.Item>
+        </Form>
+      </Modal>
+
+      <Modal
+        title="Сменить пароль"
+        open={accountModal === 'password'}
+        onCancel={closeAccountModal}
+        onOk={() => passwordForm.submit()}
+        okText="Сменить"
+        okButtonProps={{ loading: actionLoading === 'password' }}
+        cancelText="Отмена"
+      >
+        <Paragraph type="secondary">Сессии на других устройствах будут завершены, все API токены отозваны.</Paragraph>
+        <Form form={passwordForm} layout="vertical" onFinish={changePassword}>
+          <Form.Item name="oldPassword" label="Текущий пароль" rules={[{ required: true, message: 'Введите текущий пароль' }]}>
+            <Input.Password autoComplete="current-password" />
+          </Form.Item>
+          <Form.Item name="newPassword" label="Новый пароль" rules={[{ required: true, min: 6, message: 'Минимум 6 символов' }]}>
+            <Input.Password autoComplete="new-password" />
+          </Form.Item>
+          <Form.Item
+            name="confirm"
+            label="Повторите новый пароль"
+            dependencies={['newPassword']}
+            rules={[
+              { required: true, message: 'Повторите пароль' },
+              ({ getFieldValue }) => ({
+                validator: (_, value) => !value || getFieldValue('newPassword') === value ? Promise.resolve() : Promise.reject(new Error('Пароли не совпадают')),
+              }),
+            ]}
+          >
+            <Input.Password autoComplete="new-password" />
+          </Form.Item>
+        </Form>
+      </Modal>
+
+      <Modal
+        title="Удалить аккаунт"
+        open={accountModal === 'delete'}
+        onCancel={closeAccountModal}
+        onOk={() => deleteForm.submit()}
+        okText="Удалить навсегда"
+        okButtonProps={{ danger: true, loading: actionLoading === 'delete' }}
+        cancelText="Отмена"
+      >
+        <Paragraph type="danger">Аккаунт, все публикации и API токены будут удалены. Отменить удаление нельзя. Перед удалением можно выгрузить архив данных.</Paragraph>
+        <Form form={deleteForm} layout="vertical" onFinish={deleteAccount}>
+          <Form.Item name="password" label="Пароль" rules={[{ required: true, message: 'Введите пароль' }]}>
+            <Input.Password autoComplete="current-password" />
+          </Form.Item>
+        </Form>
+      </Modal>
+
+      <Card
+        title={
+          <Space>
+            <SafetyOutlined />
+            <span>Двухфакторная аутентификация</span>
+          </Space>
+        }
+        bordered={false}
+        style={{ marginBottom: 24, borderRadius: 12, boxShadow: '0 2px 16px rgba(0,0,0,0.04)' }}
+      >
+        {recoveryCodes && (
+          <Card size="small" style={{ marginBottom: 16, background: '#fff7e6', border: '1px solid #ffd666' }}>
+            <Paragraph strong>Резервные коды (показываются один раз, каждый код действует однократно):</Paragraph>
+            <Paragraph copyable={{ text: recoveryCodes.join('\n') }}>
+              <pre style={{ margin: 0 }}>{recoveryCodes.join('\n')}</pre>
+            </Paragraph>
+            <Button size="small" onClick={() => setRecoveryCodes(null)}>Я сохранил коды</Button>
+          </Card>
+        )}
+        {twoFactor?.enabled ? (
+          <Space direction="vertical" size="middle">
+            <Space>
+              <Tag color="success">Включена</Tag>
+              <Text type="secondary">Осталось резервных кодов: {twoFactor.recoveryCodesLeft}</Text>
+            </Space>
+            <Form form={twoFactorForm} layout="inline" onFinish={regenerateRecoveryCodes}>
+              <Form.Item name="code" rules={[{ required: true, message: 'Введите код' }]}>
+                <Input placeholder="Код из приложения" autoComplete="one-time-code" />
+              </Form.Item>
+              <Button htmlType="submit" loading={actionLoading === '2fa-codes'}>Новые резервные коды</Button>
+            </Form>
+            <Button danger onClick={() => setDisableModalOpen(true)}>Отключить</Button>
+          </Space>
+        ) : twoFactorSetup ? (
+          <Space align="start" size="large" wrap>
+            <QRCode value={twoFactorSetup.provisioningUri} />
+            <Space direction="vertical">
+              <Text>Отсканируйте QR-код в приложении-аутентификаторе или введите ключ вручную:</Text>
+              <Text code copyable>{twoFactorSetup.secret}</Text>
+              <Form form={twoFactorForm} layout="inline" onFinish={enableTwoFactor}>
+                <Form.Item name="code" rules={[{ required: true, message: 'Введите код' }]}>
+                  <Input placeholder="123456" autoComplete="one-time-code" />
+                </Form.Item>
+                <Button type="primary" htmlType="submit" loading={actionLoading === '2fa-enable'}>Включить</Button>
+              </Form>
+            </Space>
+          </Space>
+        ) : (
+          <Space direction="vertical">
+            <Text type="secondary">Вход в Web-интерфейс будет требовать код из приложения-аутентификатора. API токены плагина продолжат работать без кода.</Text>
+            <Button type="primary" icon={<SafetyOutlined />} onClick={startTwoFactorSetup} loading={actionLoading === '2fa-setup'}>
+              Настроить
+            </Button>
+          </Space>
+        )}
+      </Card>
+
+      <Modal
+        title="Отключить двухфакторную аутентификацию"
+        open={disableModalOpen}
+        onCancel={() => { setDisableModalOpen(false); disableForm.resetFields() }}
+        onOk={() => disableForm.submit()}
+        okText="Отключить"
+        okButtonProps={{ danger: true, loading: actionLoading === '2fa-disable' }}
+        cancelText="Отмена"
+      >
+        <Form form={disableForm} layout="vertical" onFinish={disableTwoFactor}>
+          <Form.Item name="password" label="Пароль" rules={[{ required: true, message: 'Введите пароль' }]}>
+            <Input.Password />
+          </Form.Item>
+          <Form.Item name="code" label="Код из приложения или резервный код" rules={[{ required: true, message: 'Введите код' }]}>
+            <Input autoComplete="one-time-code" />
+          </Form.Item>
+        </Form>
+      </Modal>
+
       <Card
         title={
           <Space>
@@ -250,6 +722,47 @@ function Dashboard() {
         </Paragraph>
       </Card>
 
+      <Card
+        title={
+          <Space>
+            <AuditOutlined />
+            <span>Журнал безопасности</span>
+          </Space>
+        }
+        bordered={false}
+        style={{ marginTop: 24, borderRadius: 12, boxShadow: '0 2px 16px rgba(0,0,0,0.04)' }}
+      >
+        <AuditTable />
+      </Card>
+
+      {user.isAdmin && (
+        <Card
+          title={
+            <Space>
+              <TeamOutlined />
+              <span>Приглашения</span>
+              {registrationMode && <Tag>Режим регистрации: {registrationMode}</Tag>}
+            </Space>
+          }
+          bordered={false}
+          style={{ marginTop: 24, borderRadius: 12, boxShadow: '0 2px 16px rgba(0,0,0,0.04)' }}
+        >
+          <Form form={inviteForm} layout="inline" onFinish={createInvite} style={{ marginBottom: 16 }}>
+            <Form.Item name="note">
+              <Input placeholder="Заметка" />
+            </Form.Item>
+            <Form.Item name="maxUses" initialValue={1} tooltip="0 - без ограничения">
+              <InputNumber min={0} addonBefore="Использований" />
+            </Form.Item>
+            <Form.Item name="expiresInDays" initialValue={7}>
+              <InputNumber min={0} addonBefore="Дней" />
+            </Form.Item>
+            <Button type="primary" htmlType="submit" icon={<PlusOutlined />} loading={actionLoading === 'invite-create'}>Создать</Button>
+          </Form>
+          <Table dataSource={invites} columns={inviteColumns} rowKey="id" pagination={false} size="small" locale={{ emptyText: 'Приглашений пока нет' }} />
+        </Card>
+      )}
+
       <Modal
         title="Создать новый Token"
         open={createModalOpen}
@@ -267,6 +780,31 @@ function Dashboard() {
           >
             <Input placeholder="Например: Плагин на ноутбуке" />
           </Form.Item>
+          <Form.Item
+            name="scopes"
+            label="Права токена"
+            initialValue={defaultScopes}
+            rules={[{ required: true, type: 'array', min: 1, message: 'Выберите хотя бы одно право' }]}
+          >
+            <Checkbox.Group options={scopeOptions.filter(o => o.value !== 'admin' || user?.isAdmin)} style={{ display: 'flex', flexDirection: 'column', gap: 4 }} />
+          </Form.Item>
+          <Form.Item name="expireDays" label="Срок действия" initialValue={0}>
+            <Select options={expireOptions} />
+          </Form.Item>
+          <Form.Item
+            name="allowedCidrs"
+            label="Разрешённые IP / диапазоны (CIDR)"
+            extra="Через запятую или с новой строки, например 10.0.0.0/8. Пусто - без ограничений."
+          >
+            <Input.TextArea rows={2} placeholder="10.0.0.0/8, 192.168.1.0/24" />
+          </Form.Item>
+          <Form.Item
+            name="rotationDays"
+            label="Автоматическая ротация (дней)"
+            extra="Новый токен возвращается в заголовке X-Rotated-Token, прежний действует ещё сутки. 0 - без ротации."
+          >
+            <InputNumber min={0} max={3650} placeholder="0" style={{ width: '100%' }} />
+          </Form.Item>
           <Form.Item>
             <Space>
               <Button type="primary" htmlType="submit" loading={actionLoading === 'create'}>
@@ -1,4 +1,4 @@
-import { ApiOutlined, DashboardOutlined, LockOutlined, LogoutOutlined, MailOutlined, UserOutlined } from '@ant-design/icons'
+import { ApiOutlined, DashboardOutlined, LockOutlined, LoginOutlined, LogoutOutlined, MailOutlined, SafetyOutlined, UserOutlined } from '@ant-design/icons'
 import { Button, Card, Divider, Form, Input, Space, Tabs, Tag, Typography, message } from 'antd'
 import { useEffect, useState } from 'react'
 import api from '../api'
@@ -20,7 +20,23 @@ interface ApiResponse<T = any> {
   data: T
 }
 
-interface LoginResponse { token: string; user: { id: string; username: string; email: string } }
+interface LoginResponse {
+  token: string
+  refreshToken: string
+  user: { id: string; username: string; email: string }
+  // Включена 2FA: вместо токенов приходит challenge для второго шага
+  twoFactorRequired?: boolean
+  challenge?: string
+}
+
+interface AuthMethods {
+  password: boolean
+  oidc: boolean
+  oidcName?: string
+  registration: 'open' | 'closed' | 'invite' | 'domain'
+  // Первый пользователь ещё не создан, нужен токен инициализации
+  setupRequired: boolean
+}
 
 function Home() {
   const [health, setHealth] = useState<HealthData | null>(null)
@@ -28,8 +44,13 @@ function Home() {
   const [activeTab, setActiveTab] = useState('status')
   const [sessionUser, setSessionUser] = useState<{ id: string; username: string; email: string } | null>(null)
   const [loadingAction, setLoadingAction] = useState(false)
+  const [challenge, setChallenge] = useState<string | null>(null)
+  const [forgot, setForgot] = useState(false)
+  const [authMethods, setAuthMethods] = useState<AuthMethods>({ password: true, oidc: false, registration: 'open', setupRequired: false })
   const [loginForm] = Form.useForm()
+  const [twoFactorForm] = Form.useForm()
   const [registerForm] = Form.useForm()
+  const [forgotForm] = Form.useForm()
 
   const loadHealth = async () => {
     setLoading(true)
@@ -53,8 +74,34 @@ function Home() {
     } catch {}
   }
 
+  // Возврат после входа через OIDC: результат передаётся во фрагменте URL
+  const consumeOIDCResult = () => {
+    const params = new URLSearchParams(window.location.hash.slice(1))
+    if (!params.has('token') && !params.has('challenge') && !params.has('oidcError')) return
+    window.history.replaceState(null, '', window.location.pathname + window.location.search)
+    if (params.get('token')) {
+      localStorage.setItem('session_token', params.get('token')!)
+      localStorage.setItem('refresh_token', params.get('refreshToken') || '')
+    } else if (params.get('challenge')) {
+      setChallenge(params.get('challenge'))
+      setActiveTab('login')
+    } else {
+      message.error(params.get('oidcError'))
+      setActiveTab('login')
+    }
+  }
+
+  const loadAuthMethods = async () => {
+    try {
+      const res = await api.get('/api/auth/methods') as ApiResponse<AuthMethods>
+      if (res.code === 0) setAuthMethods(res.data)
+    } catch {}
+  }
+
   useEffect(() => {
+    consumeOIDCResult()
     loadHealth()
+    loadAuthMethods()
     restoreSession()
   }, [])
 
@@ -65,6 +112,7 @@ function Home() {
       if (res.code === 0) {
         message.success('Регистрация успешна! Теперь войдите')
         registerForm.resetFields()
+        loadAuthMethods()
         setActiveTab('login')
       } else {
         message.error(res.msg || 'Ошибка регистрации')
@@ -76,16 +124,25 @@ function Home() {
     }
   }
 
+  const finishLogin = (data: LoginResponse) => {
+    localStorage.setItem('session_token', data.token)
+    localStorage.setItem('refresh_token', data.refreshToken)
+    setSessionUser(data.user)
+    message.success(`С возвращением，${data.user.username}！`)
+    loginForm.resetFields()
+    twoFactorForm.resetFields()
+    setChallenge(null)
+    setActiveTab('status')
+  }
+
   const handleLogin = async (values: any) => {
     setLoadingAction(true)
     try {
       const res = await api.post('/api/auth/login', values) as ApiResponse<LoginResponse>
-      if (res.code === 0) {
-        localStorage.setItem('session_token', res.data.token)
-        setSessionUser(res.data.user)
-        message.success(`С возвращением，${res.data.user.username}！`)
-        loginForm.resetFields()
-        setActiveTab('status')
+      if (res.code === 0 && res.data.twoFactorRequired) {
+        setChallenge(res.data.challenge || null)
+      } else if (res.code === 0) {
+        finishLogin(res.data)
       } else {
         message.error(res.msg || 'Ошибка входа')
       }
@@ -96,8 +153,49 @@ function Home() {
     }
   }
 
-  const handleLogout = () => {
+  const handleTwoFactor = async (values: any) => {
+    setLoadingAction(true)
+    try {
+      // Код из 6 цифр - TOTP, иначе резервный код
+      const code = String(values.code || '').trim()
+      const payload = /^\d{6}$/.test(code) ? { challenge, code } : { challenge, recoveryCode: code }
+      const res = await api.post('/api/auth/login/2fa', payload) as ApiResponse<LoginResponse>
+      if (res.code === 0) {
+        finishLogin(res.data)
+      } else {
+        message.error(res.msg || 'Неверный код')
+      }
+    } catch (e: any) {
+      message.error(e.response?.data?.msg || e.message || 'Неверный код')
+      if (e.response?.status === 401 && /challenge/i.test(e.response?.data?.msg || '')) setChallenge(null)
+    } finally {
+      setLoadingAction(false)
+    }
+  }
+
+  const handleForgot = async (values: any) => {
+    setLoadingAction(true)
+    try {
+      const res = await api.post('/api/auth/forgot', values) as ApiResponse
+      if (res.code === 0) {
+        message.success('Если адрес зарегистрирован, на него отправлено письмо со ссылкой')
+        forgotForm.resetFields()
+        setForgot(false)
+      } else {
+        message.error(res.msg || 'Не удалось отправить письмо')
+      }
+    } catch (e: any) {
+      message.error(e.response?.data?.msg || e.message || 'Не удалось отправить письмо')
+    } finally {
+      setLoadingAction(false)
+    }
+  }
+
+  const handleLogout = async () => {
+    // Отзыв сессии на сервере; локальные токены удаляются в любом случае
+    try { await api.post('/api/auth/logout', {}) } catch {}
     localStorage.removeItem('session_token')
+    localStorage.removeItem('refresh_token')
     setSessionUser(null)
     message.info('Выход выполнен')
   }
@@ -157,6 +255,43 @@ function Home() {
       children: (
         <div style={{ padding: '24px 0', maxWidth: 400, margin: '0 auto' }}>
           <Title level={4} style={{ textAlign: 'center', marginBottom: 24 }}>С возвращением</Title>
+          {challenge ? (
+          <Form form={twoFactorForm} onFinish={handleTwoFactor} layout="vertical" size="large">
+            <Paragraph type="secondary">Введите код из приложения-аутентификатора или один из резервных кодов</Paragraph>
+            <Form.Item name="code" rules={[{ required: true, message: 'Введите код' }]}>
+              <Input prefix={<SafetyOutlined />} placeholder="123456" autoComplete="one-time-code" autoFocus />
+            </Form.Item>
+            <Form.Item>
+              <Button type="primary" htmlType="submit" block loading={loadingAction} size="large">
+                Подтвердить
+              </Button>
+            </Form.Item>
+            <Button type="link" block onClick={() => { setChallenge(null); twoFactorForm.resetFields() }}>Назад</Button>
+          </Form>
+          ) : forgot ? (
+          <Form form={forgotForm} onFinish={handleForgot} layout="vertical" size="large">
+            <Paragraph type="secondary">Введите email аккаунта - мы отправим ссылку для смены пароля</Paragraph>
+            <Form.Item name="email" rules={[{ required: true, message: 'Введите Email' }, { type: 'email', message: 'Emailформатнекорректно' }]}>
+              <Input prefix={<MailOutlined />} placeholder="Email" autoFocus />
+            </Form.Item>
+            <Form.Item>
+              <Button type="primary" htmlType="submit" block loading={loadingAction} size="large">
+                Отправить ссылку
+              </Button>
+            </Form.Item>
+            <Button type="link" block onClick={() => { setForgot(false); forgotForm.resetFields() }}>Назад</Button>
+          </Form>
+          ) : (
+          <>
+          {authMethods.oidc && (
+            <>
+              <Button type="primary" block size="large" icon={<LoginOutlined />} href="/api/auth/oidc/login">
+                Войти через {authMethods.oidcName || 'SSO'}
+              </Button>
+              {authMethods.password && <Divider plain>или</Divider>}
+            </>
+          )}
+          {authMethods.password && (
           <Form form={loginForm} onFinish={handleLogin} layout="vertical" size="large">
             <Form.Item name="username" rules={[{ required: true, message: 'Введите имя пользователя' }]}>
               <Input prefix={<UserOutlined />} placeholder="Имя пользователя" />
@@ -169,10 +304,16 @@ function Home() {
                 Вход
               </Button>
             </Form.Item>
+            <Button type="link" block onClick={() => setForgot(true)}>Забыли пароль?</Button>
           </Form>
+          )}
+          </>
+          )}
+          {authMethods.password && (authMethods.registration !== 'closed' || authMethods.setupRequired) && (
           <Paragraph style={{ textAlign: 'center', marginTop: 16, color: '#8c8c8c' }}>
             Нет аккаунта? <a onClick={() => setActiveTab('register')}>Зарегистрироваться</a>
           </Paragraph>
+          )}
         </div>
       )
     },
@@ -209,6 +350,15 @@ function Home() {
             >
               <Input.Password prefix={<LockOutlined />} placeholder="Подтвердите пароль" />
             </Form.Item>
+            {(authMethods.setupRequired || authMethods.registration === 'invite') && (
+              <Form.Item
+                name="inviteCode"
+                extra={authMethods.setupRequired ? 'Первый пользователь становится администратором. Токен находится в DATA_DIR/bootstrap_token.txt' : undefined}
+                rules={[{ required: true, message: authMethods.setupRequired ? 'Введите токен инициализации' : 'Введите код приглашения' }]}
+              >
+                <Input prefix={<SafetyOutlined />} placeholder={authMethods.setupRequired ? 'Токен инициализации' : 'Код приглашения'} />
+              </Form.Item>
+            )}
             <Form.Item>
               <Button type="primary" htmlType="submit" block loading={loadingAction} size="large">
                 Регистрация
@@ -236,7 +386,7 @@ function Home() {
       </div>
 
       <Card className="home-card" bordered={false}>
-        <Tabs activeKey={activeTab} onChange={setActiveTab} items={sessionUser ? tabItems.filter(item => item.key === 'status') : tabItems} size="large" />
+        <Tabs activeKey={activeTab} onChange={setActiveTab} items={sessionUser ? tabItems.filter(item => item.key === 'status') : tabItems.filter(item => item.key !== 'register' || (authMethods.password && (authMethods.registration !== 'closed' || authMethods.setupRequired)))} size="large" />
       </Card>
 
       <Card className="usage-card" bordered={false} style={{ marginTop: 24 }}>
@@ -0,0 +1,83 @@
+import { LockOutlined } from '@ant-design/icons'
+import { Button, Card, Form, Input, Result, Typography, message } from 'antd'
+import { useEffect, useState } from 'react'
+import api from '../api'
+import './Home.css'
+
+const { Title } = Typography
+
+// Новый пароль по ссылке из письма (токен во фрагменте URL)
+function ResetPassword() {
+  const [token, setToken] = useState<string | null>(null)
+  const [done, setDone] = useState(false)
+  const [loading, setLoading] = useState(false)
+
+  useEffect(() => {
+    setToken(new URLSearchParams(window.location.hash.slice(1)).get('token'))
+    window.history.replaceState(null, '', window.location.pathname)
+  }, [])
+
+  const handleReset = async (values: any) => {
+    setLoading(true)
+    try {
+      const res = await api.post('/api/auth/reset', { token, password: values.password }) as any
+      if (res.code === 0) {
+        // Все сессии завершены сервером
+        localStorage.removeItem('session_token')
+        localStorage.removeItem('refresh_token')
+        setDone(true)
+      } else {
+        message.error(res.msg || 'Не удалось сменить пароль')
+      }
+    } catch (e: any) {
+      message.error(e.response?.data?.msg || e.message || 'Не удалось сменить пароль')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  return (
+    <div className="home-container">
+      <Card className="home-card" bordered={false}>
+        {done ? (
+          <Result status="success" title="Пароль изменён" subTitle="Войдите с новым паролем" extra={<Button type="primary" href="/">Войти</Button>} />
+        ) : !token ? (
+          <Result status="error" title="Ссылка неполная" subTitle="Запросите новое письмо на странице входа" extra={<Button type="primary" href="/">На главную</Button>} />
+        ) : (
+          <div style={{ padding: '24px 0', maxWidth: 400, margin: '0 auto' }}>
+            <Title level={4} style={{ textAlign: 'center', marginBottom: 24 }}>Новый пароль</Title>
+            <Form onFinish={handleReset} layout="vertical" size="large">
+              <Form.Item name="password" rules={[{ required: true, message: 'Введите пароль' }, { min: 6, message: 'Минимум 6 символов' }]}>
+                <Input.Password prefix={<LockOutlined />} placeholder="Новый пароль" autoComplete="new-password" />
+              </Form.Item>
+              <Form.Item
+                name="password2"
+                dependencies={['password']}
+                rules={[
+                  { required: true, message: 'Пожалуйста, подтвердите пароль' },
+                  ({ getFieldValue }) => ({
+                    validator(_, value) {
+                      if (!value || getFieldValue('password') === value) {
+                        return Promise.resolve()
+                      }
+                      return Promise.reject(new Error('Пароли не совпадают'))
+                    }
+                  })
+                ]}
+              >
+                <Input.Password prefix={<LockOutlined />} placeholder="Подтвердите пароль" autoComplete="new-password" />
+              </Form.Item>
+              <Form.Item>
+                <Button type="primary" htmlType="submit" block loading={loading} size="large">
+                  Сменить пароль
+                </Button>
+              </Form.Item>
+            </Form>
+          </div>
+        )}
+      </Card>
+    </div>
+  )
+}
+
+export default ResetPassword
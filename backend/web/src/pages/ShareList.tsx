@@ -1,9 +1,10 @@
-import { ArrowLeftOutlined, CopyOutlined, DeleteOutlined, ReloadOutlined } from '@ant-design/icons'
-import { Button, Card, message, Modal, Space, Table, Tag, Typography } from 'antd'
+import { ArrowLeftOutlined, BarChartOutlined, CopyOutlined, DeleteOutlined, ImportOutlined, ReloadOutlined } from '@ant-design/icons'
+import { Button, Card, message, Modal, Space, Table, Tag, Typography, Upload } from 'antd'
 import type { ColumnsType } from 'antd/es/table'
 import { useEffect, useState } from 'react'
 import { useNavigate } from 'react-router-dom'
-import { deleteShare, listShares, type ShareListItem } from '../api/share'
+import { deleteShare, importShares, listShares, type ImportConflict, type ImportReport, type ShareListItem } from '../api/share'
+import ShareStatsModal from '../components/ShareStatsModal'
 
 const { Title, Text } = Typography
 
@@ -14,6 +15,8 @@ function ShareList() {
   const [page, setPage] = useState(1)
   const [total, setTotal] = useState(0)
   const pageSize = 10
+  const [importing, setImporting] = useState(false)
+  const [statsShare, setStatsShare] = useState<ShareListItem | null>(null)
 
   const loadShares = async (currentPage = 1) => {
     setLoading(true)
@@ -68,6 +71,71 @@ function ShareList() {
     })
   }
 
+  // Импорт из архива экспорта: сначала пробный прогон с отчётом о конфликтах, затем подтверждение
+  const conflictText: Record<ImportConflict['reason'], string> = {
+    id_taken: 'ID занят, будет выдан новый',
+    invalid_id: 'Недопустимый ID, будет выдан новый',
+    doc_exists: 'Документ уже опубликован, пропущено',
+    already_imported: 'Уже импортировано, пропущено',
+    duplicate_doc: 'Документ повторяется в архиве, пропущено',
+  }
+
+  const reportContent = (report: ImportReport) => (
+    <Space direction="vertical" style={{ width: '100%' }}>
+      <Text>Публикаций: {report.imported} (с новым ID: {report.remapped}), пропущено: {report.skipped}, версий: {report.revisions}</Text>
+      {report.conflicts.length > 0 && (
+        <Table
+          size="small"
+          rowKey="shareId"
+          pagination={false}
+          scroll={{ y: 240 }}
+          dataSource={report.conflicts}
+          columns={[
+            { title: 'ID', dataIndex: 'shareId', key: 'shareId', ellipsis: true },
+            { title: 'Конфликт', dataIndex: 'reason', key: 'reason', render: (r: ImportConflict['reason']) => conflictText[r] || r },
+            { title: 'Новый ID', key: 'target', ellipsis: true, render: (_: any, c: ImportConflict) => c.newId || c.existingId || '-' },
+          ]}
+        />
+      )}
+    </Space>
+  )
+
+  const handleImport = async (file: File) => {
+    setImporting(true)
+    try {
+      const check = await importShares(file, true)
+      if (check.code !== 0) {
+        message.error(check.msg || 'Ошибка импорта')
+        return
+      }
+      Modal.confirm({
+        title: 'Импорт публикаций',
+        width: 640,
+        content: reportContent(check.data),
+        okText: 'Импортировать',
+        cancelText: 'Отмена',
+        okButtonProps: { disabled: check.data.imported === 0 },
+        onOk: async () => {
+          try {
+            const res = await importShares(file)
+            if (res.code === 0) {
+              message.success(`Импортировано публикаций: ${res.data.imported}`)
+              loadShares(1)
+            } else {
+              message.error(res.msg || 'Ошибка импорта')
+            }
+          } catch (e: any) {
+            message.error(e.response?.data?.msg || e.message || 'Ошибка импорта')
+          }
+        }
+      })
+    } catch (e: any) {
+      message.error(e.response?.data?.msg || e.message || 'Ошибка импорта')
+    } finally {
+      setImporting(false)
+    }
+  }
+
   const isExpired = (expireAt: string) => {
     return new Date(expireAt) <= new Date()
   }
@@ -129,10 +197,18 @@ function ShareList() {
     {
       title: 'Действия',
       key: 'action',
-      width: 140,
+      width: 230,
       fixed: 'right',
       render: (record: ShareListItem) => (
         <Space size="small">
+          <Button
+            type="link"
+            size="small"
+            icon={<BarChartOutlined />}
+            onClick={() => setStatsShare(record)}
+          >
+            Статистика
+          </Button>
           <Button
             type="link"
             size="small"
@@ -171,14 +247,19 @@ function ShareList() {
                 Управление публикациями
               </Title>
             </div>
-            <Button
-              type="primary"
-              icon={<ReloadOutlined />}
-              onClick={() => loadShares(page)}
-              loading={loading}
-            >
-              Обновить
-            </Button>
+            <Space>
+              <Upload accept=".zip" showUploadList={false} beforeUpload={(file) => { handleImport(file); return false }}>
+                <Button icon={<ImportOutlined />} loading={importing}>Импорт из архива</Button>
+              </Upload>
+              <Button
+                type="primary"
+                icon={<ReloadOutlined />}
+                onClick={() => loadShares(page)}
+                loading={loading}
+              >
+                Обновить
+              </Button>
+            </Space>
           </div>
         </div>
 
@@ -206,6 +287,7 @@ function ShareList() {
           }}
         />
       </Card>
+      <ShareStatsModal share={statsShare} onClose={() => setStatsShare(null)} />
     </div>
   )
 }
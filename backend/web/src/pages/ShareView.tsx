@@ -1,5 +1,5 @@
 import { ExclamationCircleOutlined, EyeOutlined, FileSearchOutlined, HomeOutlined, UpOutlined } from '@ant-design/icons'
-import { Anchor, Button, Drawer, Image, Input, Layout, message, Result, Spin, Typography } from 'antd'
+import { Alert, Anchor, Button, Drawer, Image, Input, Layout, message, Result, Spin, Typography } from 'antd'
 import 'github-markdown-css/github-markdown-light.css'
 import 'highlight.js/styles/github.css'
 import { useEffect, useRef, useState } from 'react'
@@ -9,7 +9,7 @@ import rehypeHighlight from 'rehype-highlight'
 import rehypeRaw from 'rehype-raw'
 import rehypeSlug from 'rehype-slug'
 import remarkGfm from 'remark-gfm'
-import { getShare, ShareData } from '../api/share'
+import { getShare, getShareDiffSummary, ShareData, ShareDiffSummary, unlockShare } from '../api/share'
 import './ShareView.css'
 
 const { Content, Sider } = Layout
@@ -34,6 +34,7 @@ function ShareView() {
   const [tocTree, setTocTree] = useState<TocNode[]>([])
   const [showBackTop, setShowBackTop] = useState(false)
   const [headerShrink, setHeaderShrink] = useState(false)
+  const [changesSinceVisit, setChangesSinceVisit] = useState<ShareDiffSummary | null>(null)
   const contentRef = useRef<HTMLDivElement>(null)
 
   const loadShare = async (pwd?: string) => {
@@ -44,18 +45,26 @@ function ShareView() {
     setPasswordError('')
 
     try {
-      const response = await getShare(shareId, pwd)
+      // Пароль проверяется один раз, дальше используется токен просмотра
+      if (pwd) {
+        await unlockShare(shareId, pwd)
+      }
+      const response = await getShare(shareId)
       
       if (response.code === 0 && response.data) {
         setShare(response.data)
         setRequirePassword(false)
+        checkChangesSinceVisit(response.data)
       } else {
         setError(response.msg || 'Ошибка загрузки')
       }
     } catch (err: any) {
       const errorMsg = err.response?.data?.msg || err.message || 'Ошибка загрузки'
       
-      if (errorMsg.includes('Password required')) {
+      if (err.response?.status === 429) {
+        const retryAfter = err.response?.data?.retryAfter
+        setPasswordError(`Слишком много попыток, повторите через ${retryAfter || 'несколько'} с`)
+      } else if (errorMsg.includes('Password required')) {
         setRequirePassword(true)
       } else if (errorMsg.includes('Invalid password')) {
         setPasswordError('Неверный пароль')
@@ -67,6 +76,26 @@ function ShareView() {
     }
   }
 
+  // Сравнение с ревизией, просмотренной при прошлом визите (хранится в localStorage)
+  const checkChangesSinceVisit = async (data: ShareData) => {
+    const key = `share_seen_revision_${data.id}`
+    let seen = 0
+    try {
+      seen = Number(localStorage.getItem(key)) || 0
+      localStorage.setItem(key, String(data.revision))
+    } catch {}
+    if (!seen || seen >= data.revision) {
+      setChangesSinceVisit(null)
+      return
+    }
+    try {
+      const res = await getShareDiffSummary(data.id, seen)
+      if (res.code === 0 && res.data?.changed) {
+        setChangesSinceVisit(res.data.summary)
+      }
+    } catch {}
+  }
+
   // Извлечение заголовков из DOM, исключая псевдозаголовки внутри блоков кода
   useEffect(() => {
     if (!share?.content) {
@@ -413,6 +442,19 @@ function ShareView() {
               </div>
             </div>
             
+            {changesSinceVisit && (
+              <Alert
+                className="share-changes-banner"
+                type="info"
+                showIcon
+                closable
+                onClose={() => setChangesSinceVisit(null)}
+                message="Документ изменился с вашего последнего визита"
+                description={`Добавлено блоков: ${changesSinceVisit.blocksAdded}, изменено: ${changesSinceVisit.blocksModified}, удалено: ${changesSinceVisit.blocksRemoved}`}
+                style={{ marginBottom: 16 }}
+              />
+            )}
+
             <div ref={contentRef} className="markdown-body share-content">
               <ReactMarkdown
                 remarkPlugins={[remarkGfm]}
@@ -0,0 +1,54 @@
+import { Button, Card, Result, Spin } from 'antd'
+import { useEffect, useState } from 'react'
+import api from '../api'
+import './Home.css'
+
+// Подтверждение email по ссылке из письма (токен во фрагменте URL)
+function VerifyEmail() {
+  const [state, setState] = useState<'loading' | 'success' | 'error'>('loading')
+  const [error, setError] = useState('')
+
+  useEffect(() => {
+    const token = new URLSearchParams(window.location.hash.slice(1)).get('token')
+    window.history.replaceState(null, '', window.location.pathname)
+    if (!token) {
+      setError('Ссылка неполная')
+      setState('error')
+      return
+    }
+    api.post('/api/auth/verify', { token })
+      .then((res: any) => {
+        if (res.code === 0) {
+          setState('success')
+        } else {
+          setError(res.msg || 'Ссылка недействительна')
+          setState('error')
+        }
+      })
+      .catch((e: any) => {
+        setError(e.response?.data?.msg || e.message || 'Ссылка недействительна')
+        setState('error')
+      })
+  }, [])
+
+  return (
+    <div className="home-container">
+      <Card className="home-card" bordered={false}>
+        {state === 'loading' ? (
+          <div style={{ textAlign: 'center', padding: 48 }}><Spin size="large" /></div>
+        ) : state === 'success' ? (
+          <Result status="success" title="Email подтверждён" extra={<Button type="primary" href="/">На главную</Button>} />
+        ) : (
+          <Result
+            status="error"
+            title="Не удалось подтвердить email"
+            subTitle={`${error}. Новое письмо можно запросить в кабинете.`}
+            extra={<Button type="primary" href="/">На главную</Button>}
+          />
+        )}
+      </Card>
+    </div>
+  )
+}
+
+export default VerifyEmail